@@ -11,6 +11,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"gopkg.in/yaml.v3"
 	"jpbot/internal/ai"
+	"jpbot/internal/audio"
 	"jpbot/internal/db"
 	"jpbot/internal/handlers"
 	"jpbot/internal/job"
@@ -19,18 +20,41 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+const (
+	ModeWebhook = "webhook"
+	ModePolling = "polling"
+
+	DefaultTelegramAPIURL = "https://api.telegram.org"
+)
+
+// shutdownTimeout fits in the 30 seconds Kubernetes waits before killing the pod.
+const shutdownTimeout = 25 * time.Second
+
 type Config struct {
-	Host             string `yaml:"host"`
-	Port             int    `yaml:"port"`
-	DBPath           string `yaml:"db_path"`
-	TelegramBotToken string `yaml:"telegram_bot_token"`
-	OpenAIAPIKey     string `yaml:"openai_api_key"`
-	GrokAPIKey       string `yaml:"grok_api_key"`
-	ExternalURL      string `yaml:"external_url"`
-	JWTSecretKey     string `yaml:"jwt_secret_key"`
+	Host             string                    `yaml:"host"`
+	Port             int                       `yaml:"port"`
+	DBPath           string                    `yaml:"db_path"`
+	TelegramBotToken string                    `yaml:"telegram_bot_token"`
+	TelegramAPIURL   string                    `yaml:"telegram_api_url"`
+	Mode             string                    `yaml:"mode" validate:"omitempty,oneof=polling webhook"`
+	OpenAIAPIKey     string                    `yaml:"openai_api_key"`
+	GrokAPIKey       string                    `yaml:"grok_api_key"`
+	ExternalURL      string                    `yaml:"external_url"`
+	JWTSecretKey     string                    `yaml:"jwt_secret_key"`
+	SRSAlgorithm     string                    `yaml:"srs_algorithm" validate:"omitempty,oneof=sm2 fsrs"`
+	LeechThreshold   int                       `yaml:"leech_threshold" validate:"gte=0"`
+	AudioCacheDir    string                    `yaml:"audio_cache_dir"`
+	AI               ai.Config                 `yaml:"ai"`
+	Dispatch         handlers.DispatcherConfig `yaml:"dispatch"`
+	// WordAudioLimit is how many words get pronunciation audio generated on
+	// each start, e.g. word_audio_limit: 500. TTS is paid for every word, so
+	// it is off unless set.
+	WordAudioLimit int `yaml:"word_audio_limit" validate:"gte=0"`
 }
 
 func ReadConfig(filePath string) (*Config, error) {
@@ -107,17 +131,46 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	openaiClient := ai.NewClient(cfg.GrokAPIKey, cfg.OpenAIAPIKey)
+	scheduler, err := db.NewScheduler(cfg.SRSAlgorithm)
+	if err != nil {
+		log.Fatalf("Failed to create scheduler: %v", err)
+	}
+	storage.SetScheduler(scheduler)
+
+	if cfg.LeechThreshold > 0 {
+		storage.SetLeechThreshold(cfg.LeechThreshold)
+	}
+
+	openaiClient, err := ai.NewClient(cfg.AI.WithDefaults(cfg.OpenAIAPIKey, cfg.GrokAPIKey))
+	if err != nil {
+		log.Fatalf("Failed to create AI client: %v", err)
+	}
+
+	audioCacheDir := cfg.AudioCacheDir
+	if audioCacheDir == "" {
+		audioCacheDir = "storage/audio"
+	}
+	audioStore, err := audio.NewFileStore(audioCacheDir)
+	if err != nil {
+		log.Fatalf("Failed to create audio store: %v", err)
+	}
 
-	bot, err := telegram.New(cfg.TelegramBotToken)
+	telegramAPIURL := cfg.TelegramAPIURL
+	if telegramAPIURL == "" {
+		telegramAPIURL = DefaultTelegramAPIURL
+	}
+	bot, err := telegram.New(cfg.TelegramBotToken, telegram.WithServerURL(telegramAPIURL))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	jobber := job.NewJob(storage, bot)
+	jobber := job.NewJob(storage, bot, openaiClient, audioStore)
+	if cfg.WordAudioLimit > 0 {
+		jobber.SetWordAudioLimit(cfg.WordAudioLimit)
+	}
 	go jobber.Run(context.Background())
 
-	handler := handlers.NewHandler(bot, storage, openaiClient, cfg.JWTSecretKey, cfg.TelegramBotToken)
+	handler := handlers.NewHandler(bot, storage, openaiClient, audioStore, cfg.JWTSecretKey, cfg.TelegramBotToken, cfg.Dispatch)
 
 	log.Printf("Authorized on account %d", bot.ID())
 
@@ -127,17 +180,34 @@ func main() {
 
 	middleware.Setup(e, logr)
 
-	webhookURL := fmt.Sprintf("%s/webhook", cfg.ExternalURL)
-	if ok, err := bot.SetWebhook(context.Background(), &telegram.SetWebhookParams{
-		DropPendingUpdates: true,
-		URL:                webhookURL,
-	}); err != nil {
-		log.Fatalf("Failed to set webhook: %v", err)
-	} else if !ok {
-		log.Fatalf("Failed to set webhook: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// polling stops with the context, the webhook with the server
+	polling := make(chan struct{})
+	if cfg.Mode == ModePolling {
+		go func() {
+			defer close(polling)
+			log.Printf("Polling for updates")
+			if err := handler.Poll(ctx, telegramAPIURL); err != nil {
+				log.Fatalf("Failed to poll for updates: %v", err)
+			}
+		}()
+	} else {
+		close(polling)
+		webhookURL := fmt.Sprintf("%s/webhook", cfg.ExternalURL)
+		if ok, err := bot.SetWebhook(context.Background(), &telegram.SetWebhookParams{
+			DropPendingUpdates: true,
+			URL:                webhookURL,
+		}); err != nil {
+			log.Fatalf("Failed to set webhook: %v", err)
+		} else if !ok {
+			log.Fatalf("Failed to set webhook: %v", err)
+		}
+
+		e.POST("/webhook", handler.HandleWebhook)
 	}
 
-	e.POST("/webhook", handler.HandleWebhook)
 	e.POST("/auth/telegram", handler.TelegramAuth)
 
 	v1 := e.Group("/v1")
@@ -145,10 +215,35 @@ func main() {
 
 	v1.Use(echojwt.WithConfig(authCfg))
 	v1.GET("/leaderboard", handler.HandleLeaderboard)
+	v1.GET("/words/:id/history", handler.HandleWordHistory)
+	v1.GET("/words/:id/audio", handler.HandleWordAudio)
+	v1.GET("/leeches", handler.HandleLeeches)
+	v1.POST("/leeches/:id/unsuspend", handler.HandleUnsuspendLeech)
+	v1.GET("/mock", handler.HandleMockHistory)
+	v1.POST("/mock", handler.HandleStartMock)
+	v1.GET("/mock/:id", handler.HandleMockAttempt)
+	v1.POST("/mock/:id/answers", handler.HandleMockAnswer)
+	v1.GET("/mock/:id/questions/:n/audio", handler.HandleMockAudio)
 
 	port := "8080"
-	log.Printf("Starting server on port %s", port)
-	if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	go func() {
+		log.Printf("Starting server on port %s", port)
+		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+
+	// updates accepted before the server stopped are still answered
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	<-polling
+	if err := handler.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to process pending updates: %v", err)
 	}
 }
@@ -0,0 +1,351 @@
+// Package aitest is a fake OpenAI-compatible API for tests. It serves chat
+// completions, including ones with a JSON schema response format, speech
+// synthesis and transcription.
+package aitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"jpbot/internal/ai"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Speech is the audio returned by the speech endpoint unless it is set on the server.
+var Speech = []byte("OggS fake speech")
+
+// Request is a chat completion the server received.
+type Request struct {
+	Model    string
+	Messages []Message
+	// Schema is the name of the JSON schema of the response format, empty
+	// for text replies.
+	Schema string
+}
+
+// Message is a message of a chat completion.
+type Message struct {
+	Role    string
+	Content string
+}
+
+// System is the content of the first system message.
+func (r Request) System() string {
+	for _, m := range r.Messages {
+		if m.Role == "system" {
+			return m.Content
+		}
+	}
+	return ""
+}
+
+// Last is the content of the last message, the one to reply to.
+func (r Request) Last() string {
+	if len(r.Messages) == 0 {
+		return ""
+	}
+	return r.Messages[len(r.Messages)-1].Content
+}
+
+// Rule replies to the chat completions it matches. Empty conditions match
+// everything.
+type Rule struct {
+	// Contains is a substring of the last message.
+	Contains string
+	// Schema is the name of the JSON schema of the response format.
+	Schema string
+	// Reply is the content of the reply, the JSON object for schema requests.
+	Reply string
+	// Status fails the request with the HTTP status instead of replying.
+	Status int
+}
+
+func (r Rule) matches(req Request) bool {
+	return (r.Contains == "" || strings.Contains(req.Last(), r.Contains)) &&
+		(r.Schema == "" || r.Schema == req.Schema)
+}
+
+// Server is the fake API. Set its URL as the base URL of a provider.
+//
+// A chat completion gets the first scripted reply, then the reply of the
+// first matching rule, then a default one: "ok" for text and an object made
+// from the schema otherwise.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	script     []Rule
+	rules      []Rule
+	requests   []Request
+	speech     []string
+	speechData []byte
+	transcript string
+}
+
+// NewServer starts a server, close it when done.
+func NewServer() *Server {
+	s := &Server{speechData: Speech}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /chat/completions", s.handleChat)
+	mux.HandleFunc("POST /audio/speech", s.handleSpeech)
+	mux.HandleFunc("POST /audio/transcriptions", s.handleTranscription)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config routes every task to the server.
+func (s *Server) Config() ai.Config {
+	return ai.Config{
+		Providers: map[string]ai.ProviderConfig{
+			ai.ProviderOpenAI: {APIKey: "test", BaseURL: s.URL},
+		},
+	}.WithDefaults("test", "")
+}
+
+// Enqueue scripts the replies to the next chat completions, in order, ahead
+// of the rules.
+func (s *Server) Enqueue(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, reply := range replies {
+		s.script = append(s.script, Rule{Reply: reply})
+	}
+}
+
+// Fail makes the next chat completion fail with the status.
+func (s *Server) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, Rule{Status: status})
+}
+
+// On adds rules, they are tried in the order they were added.
+func (s *Server) On(rules ...Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rules...)
+}
+
+// SetSpeech sets the audio returned by the speech endpoint.
+func (s *Server) SetSpeech(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.speechData = data
+}
+
+// SetTranscript sets the text returned by the transcription endpoint.
+func (s *Server) SetTranscript(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcript = text
+}
+
+// Requests returns the chat completions received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Speeches returns the texts synthesized so far.
+func (s *Server) Speeches() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.speech...)
+}
+
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	ResponseFormat *struct {
+		Type       string `json:"type"`
+		JSONSchema *struct {
+			Name   string          `json:"name"`
+			Schema json.RawMessage `json:"schema"`
+		} `json:"json_schema"`
+	} `json:"response_format"`
+}
+
+// content is the text of a message, sent either as a string or as parts.
+func content(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var body chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	req := Request{Model: body.Model}
+	for _, m := range body.Messages {
+		req.Messages = append(req.Messages, Message{Role: m.Role, Content: content(m.Content)})
+	}
+	var schema json.RawMessage
+	if f := body.ResponseFormat; f != nil && f.Type == "json_schema" && f.JSONSchema != nil {
+		req.Schema = f.JSONSchema.Name
+		schema = f.JSONSchema.Schema
+	}
+
+	rule, ok := s.reply(req)
+	if !ok {
+		rule.Reply = "ok"
+		if req.Schema != "" {
+			rule.Reply = defaultJSON(schema)
+		}
+	}
+	if rule.Status != 0 {
+		writeError(w, rule.Status, "scripted failure")
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"id":      "chatcmpl-fake",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"finish_reason": "stop",
+			"message": map[string]interface{}{
+				"role":    "assistant",
+				"content": rule.Reply,
+			},
+		}},
+	})
+}
+
+// reply records the request and finds the scripted reply or the rule for it.
+func (s *Server) reply(req Request) (Rule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if len(s.script) > 0 {
+		rule := s.script[0]
+		s.script = s.script[1:]
+		return rule, true
+	}
+	for _, rule := range s.rules {
+		if rule.matches(req) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (s *Server) handleSpeech(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Input string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	s.speech = append(s.speech, body.Input)
+	data := s.speechData
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "audio/ogg")
+	w.Write(data)
+}
+
+func (s *Server) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	// the recording itself is of no interest
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	text := s.transcript
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"text": text})
+}
+
+// defaultJSON makes an object of the schema: the maximum for numbers, "ok"
+// for strings and null where it is allowed.
+func defaultJSON(schema json.RawMessage) string {
+	var s struct {
+		Properties map[string]struct {
+			Type json.RawMessage `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return "{}"
+	}
+
+	object := make(map[string]interface{}, len(s.Properties))
+	for name, p := range s.Properties {
+		var types []string
+		if err := json.Unmarshal(p.Type, &types); err != nil {
+			var t string
+			json.Unmarshal(p.Type, &t)
+			types = []string{t}
+		}
+
+		switch {
+		case contains(types, "null"):
+			object[name] = nil
+		case contains(types, "integer"), contains(types, "number"):
+			object[name] = 100
+		case contains(types, "boolean"):
+			object[name] = true
+		case contains(types, "array"):
+			object[name] = []interface{}{}
+		default:
+			object[name] = "ok"
+		}
+	}
+
+	data, _ := json.Marshal(object)
+	return string(data)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "fake_error"},
+	})
+}
@@ -1,39 +1,89 @@
 package ai
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/invopop/jsonschema"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"io"
+	"jpbot/internal/audio"
 	"jpbot/internal/db"
+	"time"
 )
 
 type Client struct {
-	grokClient   *openai.Client
-	openaiClient *openai.Client
+	providers map[string]*openai.Client
+	routes    map[string]Route
+	timeout   time.Duration
 }
 
 const (
 	ChatGPTModel = "gpt-4.1-mini-2025-04-14"
 )
 
-func NewClient(grokApiKey, openAIApiKey string) *Client {
-	client := openai.NewClient(
-		option.WithAPIKey(grokApiKey),
-		option.WithBaseURL("https://api.x.ai/v1"),
-	)
-
-	openAIClient := openai.NewClient(
-		option.WithAPIKey(openAIApiKey),
-	)
+// NewClient makes a client that sends every task to the provider and model
+// of its route. The config is expected to be completed with WithDefaults.
+func NewClient(cfg Config) (*Client, error) {
+	providers, err := newProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Client{
-		grokClient:   &client,
-		openaiClient: &openAIClient,
+		providers: providers,
+		routes:    cfg.Routes,
+		timeout:   cfg.Timeout,
+	}, nil
+}
+
+// complete sends a chat completion for the task and returns the text of the reply.
+func (c *Client) complete(task string, params openai.ChatCompletionNewParams) (string, error) {
+	return withFailover(c, task, func(ctx context.Context, client *openai.Client, model string) (string, error) {
+		params.Model = model
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return "", fmt.Errorf("GPT request failed: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("GPT response has no choices")
+		}
+		return resp.Choices[0].Message.Content, nil
+	})
+}
+
+// completeJSON sends a chat completion whose reply follows the JSON schema of
+// T. A reply that can't be parsed counts as a failure of the provider.
+func completeJSON[T any](c *Client, task, name string, messages []openai.ChatCompletionMessageParamUnion) (T, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: messages,
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   name,
+					Schema: GenerateSchema[T](),
+					Strict: openai.Bool(true),
+				},
+			},
+		},
 	}
+
+	return withFailover(c, task, func(ctx context.Context, client *openai.Client, model string) (T, error) {
+		var result T
+		params.Model = model
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return result, fmt.Errorf("GPT request failed: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return result, fmt.Errorf("GPT response has no choices")
+		}
+		if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+			return result, fmt.Errorf("failed to parse GPT response: %w", err)
+		}
+		return result, nil
+	})
 }
 
 type ExerciseFeedback struct {
@@ -58,8 +108,6 @@ type WordTranslationEvaluation struct {
 }
 
 func (c *Client) CheckWordTranslation(word, translation, userInput string) (WordTranslationEvaluation, error) {
-	ctx := context.Background()
-
 	systemPrompt := `Ты преподаватель японского языка. Проверь правильность перевода слова с русского на японский. Оцени перевод по 100-балльной шкале. Если перевод неверный или неполный, добавь краткий комментарий (1–2 предложения), объясняющий, в чём ошибка: — например, неверная часть речи, неточность значения, опущена важная часть, используется другое слово. Если перевод корректен — комментарий должен быть 'null'. Формат ответа: - оценка: (целое число от 0 до 100) - комментарий: (строка или 'null')`
 
 	examples := []openai.ChatCompletionMessageParamUnion{
@@ -105,38 +153,89 @@ func (c *Client) CheckWordTranslation(word, translation, userInput string) (Word
 
 	messages = append(messages, openai.UserMessage(userPrompt))
 
-	schema := GenerateSchema[WordTranslationEvaluation]()
+	return c.evaluateWord(messages)
+}
 
-	resp, err := c.openaiClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:    ChatGPTModel,
-		Messages: messages,
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
-				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
-					Name:   "translation_evaluation",
-					Schema: schema,
-					Strict: openai.Bool(true),
-				},
-			},
-		},
-	})
+// CheckWordRecognition checks a translation of a Japanese word into Russian.
+func (c *Client) CheckWordRecognition(word, reading, translation, userInput string) (WordTranslationEvaluation, error) {
+	systemPrompt := `Ты преподаватель японского языка. Проверь, правильно ли ученик перевёл японское слово на русский. Оцени перевод по 100-балльной шкале. Синонимы и близкие по смыслу переводы считаются правильными. Если перевод неверный или неполный, добавь краткий комментарий (1–2 предложения), объясняющий, в чём ошибка. Если перевод корректен — комментарий должен быть 'null'. Формат ответа: - оценка: (целое число от 0 до 100) - комментарий: (строка или 'null')`
 
-	if err != nil {
-		return WordTranslationEvaluation{}, fmt.Errorf("GPT request failed: %w", err)
+	examples := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage(`Японское слово: 「食べ物」(たべもの)
+Пользовательский перевод: "пища"
+Правильный перевод: "еда"`),
+		openai.AssistantMessage(`{
+  "score": 100,
+  "comment": null
+}`),
+
+		openai.UserMessage(`Японское слово: 「働く」(はたらく)
+Пользовательский перевод: "работа"
+Правильный перевод: "работать"`),
+		openai.AssistantMessage(`{
+  "score": 60,
+  "comment": "「働く」 — это глагол 'работать', а 'работа' как существительное — 「仕事」."
+}`),
 	}
 
-	var explanation WordTranslationEvaluation
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &explanation); err != nil {
-		return explanation, fmt.Errorf("failed to parse GPT response: %w", err)
+	userPrompt := fmt.Sprintf(`Японское слово: 「%s」(%s)
+Пользовательский перевод: "%s"
+Правильный перевод: "%s"`,
+		word,
+		reading,
+		userInput,
+		translation,
+	)
+
+	messages := append(
+		[]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemPrompt),
+		},
+		examples...,
+	)
+	messages = append(messages, openai.UserMessage(userPrompt))
+
+	return c.evaluateWord(messages)
+}
+
+// CheckWordReading checks the kana reading of a word written with kanji.
+func (c *Client) CheckWordReading(word, reading, userInput string) (WordTranslationEvaluation, error) {
+	systemPrompt := `Ты преподаватель японского языка. Проверь, правильно ли ученик написал чтение слова каной. Оцени ответ по 100-балльной шкале: 100 — чтение полностью верное (хирагана и катакана считаются одинаковыми), ниже — если неверная долгота гласных, пропущена っ, перепутаны звонкие и глухие слоги или чтение другое. Если чтение неверное, добавь краткий комментарий (1–2 предложения), объясняющий ошибку. Если чтение корректно — комментарий должен быть 'null'. Формат ответа: - оценка: (целое число от 0 до 100) - комментарий: (строка или 'null')`
+
+	examples := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage(`Слово: 「学校」
+Чтение ученика: 「がこう」
+Правильное чтение: 「がっこう」`),
+		openai.AssistantMessage(`{
+  "score": 50,
+  "comment": "Пропущена маленькая っ: правильно がっこう."
+}`),
 	}
 
-	return explanation, nil
+	userPrompt := fmt.Sprintf(`Слово: 「%s」
+Чтение ученика: 「%s」
+Правильное чтение: 「%s」`,
+		word,
+		userInput,
+		reading,
+	)
+
+	messages := append(
+		[]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemPrompt),
+		},
+		examples...,
+	)
+	messages = append(messages, openai.UserMessage(userPrompt))
+
+	return c.evaluateWord(messages)
 }
 
-func (c *Client) CheckExercise(submission db.Submission) (ExerciseFeedback, error) {
-	ctx := context.Background()
-	schema := GenerateSchema[ExerciseFeedback]()
+func (c *Client) evaluateWord(messages []openai.ChatCompletionMessageParamUnion) (WordTranslationEvaluation, error) {
+	return completeJSON[WordTranslationEvaluation](c, TaskChecker, "translation_evaluation", messages)
+}
 
+func (c *Client) CheckExercise(submission db.Submission) (ExerciseFeedback, error) {
 	var systemPrompt, userPrompt string
 	var messages []openai.ChatCompletionMessageParamUnion
 
@@ -198,6 +297,26 @@ func (c *Client) CheckExercise(submission db.Submission) (ExerciseFeedback, erro
 			openai.UserMessage(userPrompt),
 		)
 
+	case db.ExerciseTypeShadowing:
+		content, _ := db.ContentAs[db.ShadowingContent](submission.Exercise.Content)
+
+		systemPrompt = `Ты преподаватель японского языка. Ученик повторил предложение за диктором, его запись распознана автоматически. Сравни распознанный текст с образцом по чтению каной, а не по записи кандзи. Укажи:
+1.	score: оценка произношения от 0 до 100
+2.	comment: перечисли слова или моры, которые отличаются от образца, в формате «ожидалось → услышано»; если отличий нет — похвали коротко
+3.	suggestion: образец каной с выделенными в【】 местами, где были ошибки, если они есть; иначе — null`
+
+		userPrompt = fmt.Sprintf(`Образец: 「%s」
+Чтение: 「%s」
+Распознано: 「%s」`,
+			content.Text,
+			content.Reading,
+			submission.UserInput,
+		)
+		messages = append(messages,
+			openai.SystemMessage(systemPrompt),
+			openai.UserMessage(userPrompt),
+		)
+
 	case db.ExerciseTypeGrammar:
 		systemPrompt = `Ты — преподаватель японского языка. Проверь, правильно ли ученик использовал грамматическую конструкцию в предложении. Укажи:
 	1.	score: оценка от 0 до 100
@@ -238,51 +357,85 @@ func (c *Client) CheckExercise(submission db.Submission) (ExerciseFeedback, erro
 		return ExerciseFeedback{}, fmt.Errorf("unknown exercise type: %s", submission.Exercise.Type)
 	}
 
-	resp, err := c.openaiClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:    ChatGPTModel,
-		Messages: messages,
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
-				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
-					Name:   "feedback",
-					Schema: schema,
-					Strict: openai.Bool(true),
-				},
-			},
-		},
-	})
-
-	if err != nil {
-		return ExerciseFeedback{}, fmt.Errorf("GPT request failed: %w", err)
-	}
+	return completeJSON[ExerciseFeedback](c, TaskChecker, "feedback", messages)
+}
 
-	var feedback ExerciseFeedback
+const (
+	speechVoice        = string(openai.AudioSpeechNewParamsVoiceOnyx)
+	speechSpeed        = 0.75
+	speechInstructions = "話し方: 自然で親しみやすく、ゆっくりしすぎず、早すぎない普通の会話のスピードで話してください。\n\n声: 普通の日本人の若い女性の声。感情は穏やかで自然なトーンで、日常会話のように聞こえるようにしてください。\n\nイントネーションと発音: 標準的な日本語のイントネーションを使い、教科書的ではなく自然な言い回しで話してください。"
+)
 
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &feedback); err != nil {
-		return feedback, fmt.Errorf("failed to parse GPT response: %w", err)
+// SpeechParams returns the parameters GenerateAudio uses for the text by
+// default, with the model of the tts route.
+func (c *Client) SpeechParams(text string) audio.Params {
+	return audio.Params{
+		Model:        c.routes[TaskTTS].Model,
+		Text:         text,
+		Voice:        speechVoice,
+		Speed:        speechSpeed,
+		Instructions: speechInstructions,
 	}
+}
 
-	return feedback, nil
+type speech struct {
+	audio  io.ReadCloser
+	params audio.Params
 }
 
-func (c *Client) GenerateAudio(text string) (io.ReadCloser, error) {
-	resp, err := c.openaiClient.Audio.Speech.New(context.TODO(), openai.AudioSpeechNewParams{
-		Input:          text,
-		Model:          openai.SpeechModelGPT4oMiniTTS,
-		Voice:          openai.AudioSpeechNewParamsVoiceOnyx,
-		Instructions:   openai.String("話し方: 自然で親しみやすく、ゆっくりしすぎず、早すぎない普通の会話のスピードで話してください。\n\n声: 普通の日本人の若い女性の声。感情は穏やかで自然なトーンで、日常会話のように聞こえるようにしてください。\n\nイントネーションと発音: 標準的な日本語のイントネーションを使い、教科書的ではなく自然な言い回しで話してください。"),
-		ResponseFormat: openai.AudioSpeechNewParamsResponseFormatOpus,
-		Speed:          openai.Float(0.75),
+// GenerateAudio synthesizes speech with the model of the tts route, whatever
+// the model of the params. It returns the params with the model that made the
+// audio, the fallback one if the route failed over, so that the audio is
+// stored under their key. The audio is read whole before it is returned, so
+// that a provider failing midway can be replaced by the fallback.
+func (c *Client) GenerateAudio(params audio.Params) (io.ReadCloser, audio.Params, error) {
+	s, err := withFailover(c, TaskTTS, func(ctx context.Context, client *openai.Client, model string) (speech, error) {
+		resp, err := client.Audio.Speech.New(ctx, openai.AudioSpeechNewParams{
+			Input:          params.Text,
+			Model:          openai.SpeechModel(model),
+			Voice:          openai.AudioSpeechNewParamsVoice(params.Voice),
+			Instructions:   openai.String(params.Instructions),
+			ResponseFormat: openai.AudioSpeechNewParamsResponseFormatOpus,
+			Speed:          openai.Float(params.Speed),
+		})
+		if err != nil {
+			return speech{}, fmt.Errorf("failed to generate audio: %w", err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return speech{}, fmt.Errorf("failed to read audio: %w", err)
+		}
+		used := params
+		used.Model = model
+		return speech{audio: io.NopCloser(bytes.NewReader(data)), params: used}, nil
 	})
+	return s.audio, s.params, err
+}
+
+// TranscribeAudio converts a Japanese voice recording to text.
+func (c *Client) TranscribeAudio(r io.Reader, filename string) (string, error) {
+	// the recording is read again by the fallback
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate audio: %w", err)
+		return "", fmt.Errorf("failed to read audio: %w", err)
 	}
-	return resp.Body, nil
+
+	return withFailover(c, TaskTranscriber, func(ctx context.Context, client *openai.Client, model string) (string, error) {
+		resp, err := client.Audio.Transcriptions.New(ctx, openai.AudioTranscriptionNewParams{
+			File:     openai.File(bytes.NewReader(data), filename, "audio/ogg"),
+			Model:    openai.AudioModel(model),
+			Language: openai.String("ja"),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to transcribe audio: %w", err)
+		}
+		return resp.Text, nil
+	})
 }
 
 func (c *Client) ExplainSentence(sentence string) (string, error) {
-	ctx := context.Background()
-
 	systemPrompt := `Объясни японское предложение для ученика уровня N5. Добавь фуригану только к трудным словам (как в учебниках). Переведи на русский, затем кратко разберёшь по частям: слово — значение. Сохраняй простой и понятный стиль. Не добавляй дополнительную разбивку на фразы, объясняй только по словам, как в примерах.`
 
 	examples := []openai.ChatCompletionMessageParamUnion{
@@ -299,16 +452,64 @@ func (c *Client) ExplainSentence(sentence string) (string, error) {
 	}, examples...)
 	messages = append(messages, userPrompt)
 
-	resp, err := c.openaiClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:    ChatGPTModel,
+	return c.complete(TaskExplainer, openai.ChatCompletionNewParams{
 		Messages: messages,
 		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
 			OfText: &openai.ResponseFormatTextParam{},
 		},
 	})
-	if err != nil {
-		return "", fmt.Errorf("GPT request failed: %w", err)
+}
+
+// ExplainCloze explains why the answer the user put into the blank is wrong.
+func (c *Client) ExplainCloze(sentence, answer, userInput string) (string, error) {
+	systemPrompt := `Ты преподаватель японского языка. Ученик заполнял пропуск ＿＿ в японском предложении и ошибся. Кратко (2–3 предложения) объясни на русском, почему здесь нужно правильное слово или частица и чем ответ ученика не подходит. Если ответ ученика тоже допустим, так и скажи. Не повторяй задание.`
+
+	userPrompt := fmt.Sprintf(`Предложение: %s
+Правильный ответ: %s
+Ответ ученика: %s`,
+		sentence,
+		answer,
+		userInput,
+	)
+
+	return c.complete(TaskExplainer, openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemPrompt),
+			openai.UserMessage(userPrompt),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfText: &openai.ResponseFormatTextParam{},
+		},
+	})
+}
+
+func (c *Client) GenerateMnemonic(word, reading, translation string) (string, error) {
+	systemPrompt := `Ты преподаватель японского языка. Ученик много раз забывал это слово. Придумай короткую яркую мнемонику на русском (1–2 предложения), которая связывает звучание и/или кандзи слова с его значением. Не добавляй ничего, кроме самой мнемоники.`
+
+	examples := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage(`Слово: 山
+Чтение: やま
+Перевод: гора`),
+		openai.AssistantMessage("Кандзи 山 похож на три горных пика, а «яма» — это то, что остаётся у подножия горы (やま)."),
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	userPrompt := fmt.Sprintf(`Слово: %s
+Чтение: %s
+Перевод: %s`,
+		word,
+		reading,
+		translation,
+	)
+
+	messages := append([]openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemPrompt),
+	}, examples...)
+	messages = append(messages, openai.UserMessage(userPrompt))
+
+	return c.complete(TaskExplainer, openai.ChatCompletionNewParams{
+		Messages: messages,
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfText: &openai.ResponseFormatTextParam{},
+		},
+	})
 }
@@ -0,0 +1,171 @@
+package ai_test
+
+import (
+	"encoding/json"
+	"io"
+	"jpbot/internal/ai"
+	"jpbot/internal/ai/aitest"
+	"jpbot/internal/db"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newClient(t *testing.T, cfg ai.Config) *ai.Client {
+	t.Helper()
+	client, err := ai.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func translation(t *testing.T) db.Submission {
+	t.Helper()
+	content, err := json.Marshal(db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.Submission{
+		UserInput: "魚を食べる",
+		Exercise:  db.Exercise{Type: db.ExerciseTypeTranslation, Content: content},
+	}
+}
+
+func TestCheckExercise(t *testing.T) {
+	fake := aitest.NewServer()
+	defer fake.Close()
+	fake.On(aitest.Rule{
+		Schema: "feedback",
+		Reply:  `{"score": 70, "feedback": "Слишком просто", "suggestion": "魚を食べます。"}`,
+	})
+
+	feedback, err := newClient(t, fake.Config()).CheckExercise(translation(t))
+	if err != nil {
+		t.Fatalf("CheckExercise: %v", err)
+	}
+	if feedback.Score != 70 || feedback.Comment != "Слишком просто" || feedback.Suggestion != "魚を食べます。" {
+		t.Errorf("got %+v", feedback)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if r := requests[0]; r.Model != ai.ChatGPTModel || !strings.Contains(r.Last(), "Я ем рыбу.") || !strings.Contains(r.Last(), "魚を食べる") {
+		t.Errorf("unexpected request: %+v", r)
+	}
+}
+
+func TestCheckExerciseDefaultReply(t *testing.T) {
+	fake := aitest.NewServer()
+	defer fake.Close()
+
+	feedback, err := newClient(t, fake.Config()).CheckExercise(translation(t))
+	if err != nil {
+		t.Fatalf("CheckExercise: %v", err)
+	}
+	if feedback.Score != 100 || feedback.Comment != "ok" {
+		t.Errorf("got %+v, want the default reply made from the schema", feedback)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	primary := aitest.NewServer()
+	defer primary.Close()
+	fallback := aitest.NewServer()
+	defer fallback.Close()
+
+	primary.Fail(http.StatusBadRequest)
+	fallback.Enqueue("объяснение")
+
+	cfg := ai.Config{
+		Providers: map[string]ai.ProviderConfig{
+			"primary":  {BaseURL: primary.URL},
+			"fallback": {BaseURL: fallback.URL},
+		},
+		Routes: map[string]ai.Route{
+			ai.TaskExplainer: {Provider: "primary", Model: "big", Fallback: "fallback", FallbackModel: "small"},
+		},
+	}.WithDefaults("test", "")
+
+	explanation, err := newClient(t, cfg).ExplainSentence("明日は雨が降ります。")
+	if err != nil {
+		t.Fatalf("ExplainSentence: %v", err)
+	}
+	if explanation != "объяснение" {
+		t.Errorf("got %q, want the reply of the fallback", explanation)
+	}
+
+	requests := fallback.Requests()
+	if len(requests) != 1 || requests[0].Model != "small" {
+		t.Errorf("fallback got %+v, want one request to the fallback model", requests)
+	}
+}
+
+func TestGenerateAudio(t *testing.T) {
+	fake := aitest.NewServer()
+	defer fake.Close()
+
+	client := newClient(t, fake.Config())
+	params := client.SpeechParams("こんにちは")
+	r, used, err := client.GenerateAudio(params)
+	if err != nil {
+		t.Fatalf("GenerateAudio: %v", err)
+	}
+	if used.Key() != params.Key() {
+		t.Errorf("audio is made with %+v, want the params asked for", used)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(aitest.Speech) {
+		t.Errorf("got audio %q", data)
+	}
+	if speeches := fake.Speeches(); len(speeches) != 1 || speeches[0] != "こんにちは" {
+		t.Errorf("got speeches %q", speeches)
+	}
+}
+
+func TestGenerateAudioFailover(t *testing.T) {
+	primary := aitest.NewServer()
+	primary.Close()
+	fallback := aitest.NewServer()
+	defer fallback.Close()
+
+	cfg := ai.Config{
+		Providers: map[string]ai.ProviderConfig{
+			"primary":  {BaseURL: primary.URL},
+			"fallback": {BaseURL: fallback.URL},
+		},
+		Routes: map[string]ai.Route{
+			ai.TaskTTS: {Provider: "primary", Model: "hd", Fallback: "fallback", FallbackModel: "mini"},
+		},
+	}.WithDefaults("test", "")
+	client := newClient(t, cfg)
+
+	params := client.SpeechParams("こんにちは")
+	r, used, err := client.GenerateAudio(params)
+	if err != nil {
+		t.Fatalf("GenerateAudio: %v", err)
+	}
+	r.Close()
+
+	// the audio of the fallback model must not be cached as the primary's
+	if params.Model != "hd" || used.Model != "mini" || used.Key() == params.Key() {
+		t.Errorf("asked for %+v, got audio made with %+v", params, used)
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	cfg := ai.Config{
+		Routes: map[string]ai.Route{"checkr": {Provider: ai.ProviderOpenAI}},
+	}.WithDefaults("test", "")
+
+	if _, err := ai.NewClient(cfg); err == nil || !strings.Contains(err.Error(), "checkr") {
+		t.Errorf("NewClient with a misspelled route: got %v, want an error naming it", err)
+	}
+}
@@ -0,0 +1,200 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"log"
+	"time"
+)
+
+// Tasks routed to a provider and a model.
+const (
+	TaskChecker     = "checker"     // grading answers
+	TaskExplainer   = "explainer"   // explanations and mnemonics
+	TaskTTS         = "tts"         // speech synthesis
+	TaskTranscriber = "transcriber" // speech recognition
+)
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderGrok   = "grok"
+
+	GrokBaseURL = "https://api.x.ai/v1"
+	GrokModel   = "grok-3-mini"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// ProviderConfig is an OpenAI-compatible API. BaseURL is empty for OpenAI
+// itself and points to the server otherwise, a local one needs no key.
+type ProviderConfig struct {
+	APIKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// Route sends a task to a model of a provider. If the request fails or times
+// out, it is repeated with the fallback provider, using FallbackModel or, if
+// it is empty, the same model.
+type Route struct {
+	Provider      string `yaml:"provider"`
+	Model         string `yaml:"model"`
+	Fallback      string `yaml:"fallback"`
+	FallbackModel string `yaml:"fallback_model"`
+}
+
+// Config is the ai section of config.yml:
+//
+//	ai:
+//	  timeout: 30s
+//	  providers:
+//	    openai:
+//	      api_key: sk-...
+//	    local:
+//	      base_url: http://localhost:11434/v1
+//	  routes:
+//	    checker:
+//	      provider: openai
+//	      model: gpt-4.1-mini-2025-04-14
+//	      fallback: grok
+//	      fallback_model: grok-3-mini
+//	    explainer:
+//	      provider: local
+//	      model: qwen2.5:14b
+//	      fallback: openai
+//	      fallback_model: gpt-4.1-mini-2025-04-14
+//	    tts:
+//	      provider: openai
+//	      model: gpt-4o-mini-tts
+//
+// Everything is optional, see WithDefaults.
+type Config struct {
+	Timeout   time.Duration             `yaml:"timeout"`
+	Providers map[string]ProviderConfig `yaml:"providers"`
+	Routes    map[string]Route          `yaml:"routes"`
+}
+
+var defaultModels = map[string]string{
+	TaskChecker:     ChatGPTModel,
+	TaskExplainer:   ChatGPTModel,
+	TaskTTS:         string(openai.SpeechModelGPT4oMiniTTS),
+	TaskTranscriber: string(openai.AudioModelGPT4oMiniTranscribe),
+}
+
+// WithDefaults completes the config with the openai and grok providers made
+// from the top-level keys and routes every task missing from it to OpenAI.
+// Text tasks fail over to Grok when it has a key. A route without a model
+// uses the default model of its task. Routes of unknown tasks are kept for
+// NewClient to reject.
+func (c Config) WithDefaults(openAIAPIKey, grokAPIKey string) Config {
+	out := Config{
+		Timeout:   c.Timeout,
+		Providers: make(map[string]ProviderConfig),
+		Routes:    make(map[string]Route),
+	}
+	if out.Timeout <= 0 {
+		out.Timeout = defaultTimeout
+	}
+
+	out.Providers[ProviderOpenAI] = ProviderConfig{APIKey: openAIAPIKey}
+	if grokAPIKey != "" {
+		out.Providers[ProviderGrok] = ProviderConfig{APIKey: grokAPIKey, BaseURL: GrokBaseURL}
+	}
+	for name, p := range c.Providers {
+		if name == ProviderGrok && p.BaseURL == "" {
+			p.BaseURL = GrokBaseURL
+		}
+		out.Providers[name] = p
+	}
+
+	for task, model := range defaultModels {
+		route, ok := c.Routes[task]
+		if !ok {
+			route = Route{Provider: ProviderOpenAI}
+			if _, hasGrok := out.Providers[ProviderGrok]; hasGrok && (task == TaskChecker || task == TaskExplainer) {
+				route.Fallback = ProviderGrok
+				route.FallbackModel = GrokModel
+			}
+		}
+		if route.Model == "" {
+			route.Model = model
+		}
+		out.Routes[task] = route
+	}
+	for task, route := range c.Routes {
+		if _, ok := defaultModels[task]; !ok {
+			out.Routes[task] = route
+		}
+	}
+	return out
+}
+
+// target is a provider and a model to send a request to.
+type target struct {
+	provider string
+	model    string
+}
+
+// newProviders makes a client for every provider and checks that the routes
+// are of known tasks and use only those providers.
+func newProviders(cfg Config) (map[string]*openai.Client, error) {
+	clients := make(map[string]*openai.Client, len(cfg.Providers))
+	for name, p := range cfg.Providers {
+		opts := []option.RequestOption{option.WithAPIKey(p.APIKey)}
+		if p.BaseURL != "" {
+			opts = append(opts, option.WithBaseURL(p.BaseURL))
+		}
+		client := openai.NewClient(opts...)
+		clients[name] = &client
+	}
+
+	for task, route := range cfg.Routes {
+		if _, ok := defaultModels[task]; !ok {
+			return nil, fmt.Errorf("route of unknown task %q", task)
+		}
+		if _, ok := clients[route.Provider]; !ok {
+			return nil, fmt.Errorf("route %s uses unknown provider %q", task, route.Provider)
+		}
+		if _, ok := clients[route.Fallback]; route.Fallback != "" && !ok {
+			return nil, fmt.Errorf("route %s falls back to unknown provider %q", task, route.Fallback)
+		}
+	}
+	return clients, nil
+}
+
+func (c *Client) targets(task string) []target {
+	route := c.routes[task]
+	targets := []target{{route.Provider, route.Model}}
+	if route.Fallback != "" {
+		model := route.FallbackModel
+		if model == "" {
+			model = route.Model
+		}
+		targets = append(targets, target{route.Fallback, model})
+	}
+	return targets
+}
+
+// withFailover runs the request of the task on its provider and, if it
+// fails or times out, on the fallback one. do must finish reading the
+// response before it returns, the context is canceled after that.
+func withFailover[T any](c *Client, task string, do func(ctx context.Context, client *openai.Client, model string) (T, error)) (T, error) {
+	var result T
+	var errs []error
+	for i, t := range c.targets(task) {
+		if i > 0 {
+			log.Printf("Falling back to %s for %s: %v", t.provider, task, errs[len(errs)-1])
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		r, err := do(ctx, c.providers[t.provider], t.model)
+		cancel()
+		if err == nil {
+			return r, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", t.provider, err))
+	}
+	return result, errors.Join(errs...)
+}
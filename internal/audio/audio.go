@@ -0,0 +1,95 @@
+// Package audio caches synthesized speech so the same text is generated only once.
+package audio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var ErrNotFound = errors.New("audio not found")
+
+// Params describe a speech synthesis request. Equal params produce the same audio.
+type Params struct {
+	Model        string
+	Text         string
+	Voice        string
+	Speed        float64
+	Instructions string
+}
+
+// Key returns the content address of the audio generated with the params.
+func (p Params) Key() string {
+	h := sha256.New()
+	for _, field := range []string{p.Model, p.Text, p.Voice, strconv.FormatFloat(p.Speed, 'f', -1, 64), p.Instructions} {
+		// length prefixes keep field boundaries unambiguous
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Store keeps audio by key.
+type Store interface {
+	// Get returns the audio stored under the key or ErrNotFound.
+	Get(key string) (io.ReadCloser, error)
+	Put(key string, r io.Reader) error
+}
+
+// FileStore stores audio files in a local directory.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating audio directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	// spread files over subdirectories to keep directories small
+	return filepath.Join(s.dir, key[:2], key+".ogg")
+}
+
+func (s *FileStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %w", err)
+	}
+	return f, nil
+}
+
+// Put writes the audio to a temporary file first so a reader never sees a partial file.
+func (s *FileStore) Put(key string, r io.Reader) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating audio directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating audio file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing audio file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing audio file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving audio file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package conjugate
+
+import "strings"
+
+// godanRuVerbs are the verbs ending in いる or える that are godan, spelled
+// as in the vocabulary.
+var godanRuVerbs = map[string]bool{
+	"帰る": true, "入る": true, "走る": true, "知る": true, "切る": true, "要る": true,
+	"限る": true, "減る": true, "参る": true, "喋る": true, "滑る": true, "握る": true,
+	"蹴る": true, "焦る": true, "混じる": true, "交じる": true, "照る": true, "散る": true,
+	"茂る": true, "湿る": true, "陥る": true, "遮る": true, "罵る": true, "捻る": true,
+	"翻る": true, "蘇る": true, "嘲る": true, "覆る": true, "練る": true, "煎る": true,
+	"しゃべる": true, "すべる": true, "にぎる": true, "ける": true, "あせる": true, "いじる": true,
+	"はいる": true, "はしる": true, "しる": true,
+}
+
+// irow and erow are the kana that make a final る ichidan.
+const (
+	irow = "いきぎしじちぢにひびぴみりゐ"
+	erow = "えけげせぜてでねへべぺめれゑ"
+)
+
+// ClassifyVerb returns the class of a verb from its spelling and reading,
+// ok is false if it isn't in the dictionary form.
+func ClassifyVerb(word, reading string) (Class, bool) {
+	switch {
+	case reading == "くる" || strings.HasSuffix(word, "来る") && strings.HasSuffix(reading, "くる"):
+		return ClassKuru, true
+	case strings.HasSuffix(reading, "する"):
+		return ClassSuru, true
+	}
+
+	runes := []rune(reading)
+	if len(runes) == 0 {
+		return "", false
+	}
+	last := runes[len(runes)-1]
+	if _, ok := godanRows[last]; !ok {
+		return "", false
+	}
+
+	if last == 'る' && len(runes) > 1 && strings.ContainsRune(irow+erow, runes[len(runes)-2]) && !godanRuVerbs[word] {
+		return ClassIchidan, true
+	}
+	return ClassGodan, true
+}
+
+// naAdjectivesInI are na-adjectives that end in い.
+var naAdjectivesInI = map[string]bool{
+	"綺麗": true, "きれい": true, "嫌い": true, "きらい": true,
+}
+
+// ClassifyAdjective returns the class of an adjective from its spelling.
+func ClassifyAdjective(word string) Class {
+	if strings.HasSuffix(word, "い") && !naAdjectivesInI[word] {
+		return ClassIAdjective
+	}
+	return ClassNaAdjective
+}
@@ -0,0 +1,414 @@
+// Package conjugate inflects Japanese verbs and adjectives. Words are given
+// in the dictionary form, written either with kanji or in kana.
+package conjugate
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Class is the conjugation class of a word.
+type Class string
+
+const (
+	ClassIchidan     Class = "ichidan"
+	ClassGodan       Class = "godan"
+	ClassSuru        Class = "suru" // する and nouns with する
+	ClassKuru        Class = "kuru"
+	ClassIAdjective  Class = "i_adjective"
+	ClassNaAdjective Class = "na_adjective"
+)
+
+func (c Class) IsVerb() bool {
+	return c == ClassIchidan || c == ClassGodan || c == ClassSuru || c == ClassKuru
+}
+
+func (c Class) IsAdjective() bool {
+	return c == ClassIAdjective || c == ClassNaAdjective
+}
+
+func IsValidClass(class string) bool {
+	return Class(class).IsVerb() || Class(class).IsAdjective()
+}
+
+// Form is an inflected form.
+type Form string
+
+const (
+	FormTe         Form = "te"
+	FormTa         Form = "ta"
+	FormNegative   Form = "negative"
+	FormPolite     Form = "polite"
+	FormPotential  Form = "potential"
+	FormPassive    Form = "passive"
+	FormCausative  Form = "causative"
+	FormVolitional Form = "volitional"
+	FormHonorific  Form = "honorific"
+	FormHumble     Form = "humble"
+)
+
+var (
+	VerbForms      = []Form{FormTe, FormTa, FormNegative, FormPolite, FormPotential, FormPassive, FormCausative, FormVolitional, FormHonorific, FormHumble}
+	AdjectiveForms = []Form{FormTe, FormTa, FormNegative, FormPolite}
+)
+
+// stativeForms are the forms that stative verbs don't have: they describe a
+// state rather than an action, so there is nothing to be able to do, to make
+// someone do or to do humbly. Some keep an honorific like おありになる,
+// the others have no keigo at all.
+var (
+	stativeForms   = []Form{FormPotential, FormPassive, FormCausative, FormVolitional, FormHumble}
+	stativeNoKeigo = []Form{FormPotential, FormPassive, FormCausative, FormVolitional, FormHonorific, FormHumble}
+)
+
+// missingForms are the forms a verb doesn't have, by spelling.
+var missingForms = map[string][]Form{
+	"ある": stativeForms, "有る": stativeForms, "在る": stativeForms,
+	"出来る": stativeForms, "できる": stativeForms,
+	"分かる": stativeForms, "わかる": stativeForms, "判る": stativeForms,
+	"要る":  stativeNoKeigo,
+	"見える": stativeNoKeigo, "みえる": stativeNoKeigo,
+	"聞こえる": stativeNoKeigo, "きこえる": stativeNoKeigo,
+}
+
+// Forms returns the forms a word of the class has.
+func Forms(word string, class Class) []Form {
+	if class.IsAdjective() {
+		return AdjectiveForms
+	}
+	if !class.IsVerb() {
+		return nil
+	}
+
+	var forms []Form
+	for _, f := range VerbForms {
+		if !slices.Contains(missingForms[word], f) {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+var (
+	ErrUnknownClass  = errors.New("unknown conjugation class")
+	ErrUnsupported   = errors.New("form is not supported by the class")
+	ErrNotDictionary = errors.New("word is not in the dictionary form")
+)
+
+// godanRows maps the final kana of a godan verb to its a, i, e and o rows.
+var godanRows = map[rune][4]string{
+	'う': {"わ", "い", "え", "お"},
+	'く': {"か", "き", "け", "こ"},
+	'ぐ': {"が", "ぎ", "げ", "ご"},
+	'す': {"さ", "し", "せ", "そ"},
+	'つ': {"た", "ち", "て", "と"},
+	'ぬ': {"な", "に", "ね", "の"},
+	'ぶ': {"ば", "び", "べ", "ぼ"},
+	'む': {"ま", "み", "め", "も"},
+	'る': {"ら", "り", "れ", "ろ"},
+}
+
+const (
+	rowA = iota
+	rowI
+	rowE
+	rowO
+)
+
+// honorificVerbs and humbleVerbs are the verbs with their own keigo words
+// instead of the お～になる and お～する patterns.
+var (
+	honorificVerbs = map[string]string{
+		"行く": "いらっしゃる", "いく": "いらっしゃる",
+		"来る": "いらっしゃる", "くる": "いらっしゃる",
+		"いる": "いらっしゃる", "居る": "いらっしゃる",
+		"言う": "おっしゃる", "いう": "おっしゃる",
+		"食べる": "召し上がる", "たべる": "召し上がる",
+		"飲む": "召し上がる", "のむ": "召し上がる",
+		"見る": "ご覧になる", "みる": "ご覧になる",
+		"する":  "なさる",
+		"くれる": "くださる",
+		"寝る":  "お休みになる", "ねる": "お休みになる",
+		"知る": "ご存じだ", "しる": "ご存じだ",
+	}
+	humbleVerbs = map[string]string{
+		"行く": "参る", "いく": "参る",
+		"来る": "参る", "くる": "参る",
+		"いる": "おる", "居る": "おる",
+		"言う": "申す", "いう": "申す",
+		"食べる": "いただく", "たべる": "いただく",
+		"飲む": "いただく", "のむ": "いただく",
+		"もらう": "いただく", "貰う": "いただく",
+		"見る": "拝見する", "みる": "拝見する",
+		"する": "いたす",
+		"知る": "存じる", "しる": "存じる",
+		"会う": "お目にかかる", "あう": "お目にかかる",
+		"あげる": "差し上げる", "上げる": "差し上げる",
+	}
+)
+
+// godanIrregularStems are the polite verbs whose i-row is い instead of り.
+var godanIrregularStems = []string{"いらっしゃる", "おっしゃる", "くださる", "下さる", "なさる", "ござる"}
+
+// Conjugate returns the form of a word of the class.
+func Conjugate(word string, class Class, form Form) (string, error) {
+	if slices.Contains(missingForms[word], form) {
+		return "", ErrUnsupported
+	}
+
+	switch class {
+	case ClassIchidan:
+		return ichidan(word, form)
+	case ClassGodan:
+		return godan(word, form)
+	case ClassSuru:
+		return suru(word, form)
+	case ClassKuru:
+		return kuru(word, form)
+	case ClassIAdjective:
+		return iAdjective(word, form)
+	case ClassNaAdjective:
+		return naAdjective(word, form)
+	default:
+		return "", ErrUnknownClass
+	}
+}
+
+func keigo(word string, form Form, stem string) (string, bool) {
+	switch form {
+	case FormHonorific:
+		if special, ok := honorificVerbs[word]; ok {
+			return special, true
+		}
+		return "お" + stem + "になる", true
+	case FormHumble:
+		if special, ok := humbleVerbs[word]; ok {
+			return special, true
+		}
+		return "お" + stem + "する", true
+	}
+	return "", false
+}
+
+func ichidan(word string, form Form) (string, error) {
+	stem, ok := strings.CutSuffix(word, "る")
+	if !ok {
+		return "", ErrNotDictionary
+	}
+
+	switch form {
+	case FormTe:
+		return stem + "て", nil
+	case FormTa:
+		return stem + "た", nil
+	case FormNegative:
+		return stem + "ない", nil
+	case FormPolite:
+		return stem + "ます", nil
+	case FormPotential, FormPassive:
+		return stem + "られる", nil
+	case FormCausative:
+		return stem + "させる", nil
+	case FormVolitional:
+		return stem + "よう", nil
+	}
+	if s, ok := keigo(word, form, stem); ok {
+		return s, nil
+	}
+	return "", ErrUnsupported
+}
+
+func godan(word string, form Form) (string, error) {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return "", ErrNotDictionary
+	}
+	last := runes[len(runes)-1]
+	rows, ok := godanRows[last]
+	if !ok {
+		return "", ErrNotDictionary
+	}
+	base := string(runes[:len(runes)-1])
+
+	iStem := base + rows[rowI]
+	for _, v := range godanIrregularStems {
+		if word == v {
+			iStem = base + "い"
+		}
+	}
+
+	switch form {
+	case FormTe, FormTa:
+		return base + godanTeEnding(word, last, form == FormTa), nil
+	case FormNegative:
+		if word == "ある" || word == "有る" || word == "在る" {
+			return "ない", nil
+		}
+		return base + rows[rowA] + "ない", nil
+	case FormPolite:
+		return iStem + "ます", nil
+	case FormPotential:
+		return base + rows[rowE] + "る", nil
+	case FormPassive:
+		return base + rows[rowA] + "れる", nil
+	case FormCausative:
+		return base + rows[rowA] + "せる", nil
+	case FormVolitional:
+		return base + rows[rowO] + "う", nil
+	}
+	if s, ok := keigo(word, form, iStem); ok {
+		return s, nil
+	}
+	return "", ErrUnsupported
+}
+
+// godanTeEnding is the ending that replaces the last kana in the te and ta
+// forms, the sound changes of 音便.
+func godanTeEnding(word string, last rune, past bool) string {
+	var ending string
+	switch last {
+	case 'う', 'つ', 'る':
+		ending = "って"
+	case 'む', 'ぶ', 'ぬ':
+		ending = "んで"
+	case 'く':
+		ending = "いて"
+		if word == "いく" || strings.HasSuffix(word, "行く") {
+			ending = "って"
+		}
+	case 'ぐ':
+		ending = "いで"
+	case 'す':
+		ending = "して"
+	}
+
+	if past {
+		ending = strings.NewReplacer("て", "た", "で", "だ").Replace(ending)
+	}
+	return ending
+}
+
+func suru(word string, form Form) (string, error) {
+	prefix, ok := strings.CutSuffix(word, "する")
+	if !ok {
+		return "", ErrNotDictionary
+	}
+
+	switch form {
+	case FormTe:
+		return prefix + "して", nil
+	case FormTa:
+		return prefix + "した", nil
+	case FormNegative:
+		return prefix + "しない", nil
+	case FormPolite:
+		return prefix + "します", nil
+	case FormPotential:
+		return prefix + "できる", nil
+	case FormPassive:
+		return prefix + "される", nil
+	case FormCausative:
+		return prefix + "させる", nil
+	case FormVolitional:
+		return prefix + "しよう", nil
+	case FormHonorific:
+		return prefix + "なさる", nil
+	case FormHumble:
+		return prefix + "いたす", nil
+	}
+	return "", ErrUnsupported
+}
+
+func kuru(word string, form Form) (string, error) {
+	// the kanji 来 is kept, only the okurigana changes
+	prefix, ok := strings.CutSuffix(word, "来る")
+	ki, ko := "来", "来"
+	if !ok {
+		if prefix, ok = strings.CutSuffix(word, "くる"); !ok {
+			return "", ErrNotDictionary
+		}
+		ki, ko = "き", "こ"
+	}
+
+	// compounds like 持って来る keep their first part: 持っていらっしゃる
+	if s, ok := keigo("くる", form, ""); ok {
+		return prefix + s, nil
+	}
+
+	switch form {
+	case FormTe:
+		return prefix + ki + "て", nil
+	case FormTa:
+		return prefix + ki + "た", nil
+	case FormNegative:
+		return prefix + ko + "ない", nil
+	case FormPolite:
+		return prefix + ki + "ます", nil
+	case FormPotential, FormPassive:
+		return prefix + ko + "られる", nil
+	case FormCausative:
+		return prefix + ko + "させる", nil
+	case FormVolitional:
+		return prefix + ko + "よう", nil
+	}
+	return "", ErrUnsupported
+}
+
+func iAdjective(word string, form Form) (string, error) {
+	stem, ok := strings.CutSuffix(word, "い")
+	if !ok {
+		return "", ErrNotDictionary
+	}
+	// いい conjugates from its older form よい
+	switch word {
+	case "いい":
+		stem = "よ"
+	case "良い":
+		stem = "良"
+	}
+
+	switch form {
+	case FormTe:
+		return stem + "くて", nil
+	case FormTa:
+		return stem + "かった", nil
+	case FormNegative:
+		return stem + "くない", nil
+	case FormPolite:
+		return word + "です", nil
+	}
+	return "", ErrUnsupported
+}
+
+func naAdjective(word string, form Form) (string, error) {
+	word = strings.TrimSuffix(word, "な")
+	if word == "" {
+		return "", ErrNotDictionary
+	}
+
+	switch form {
+	case FormTe:
+		return word + "で", nil
+	case FormTa:
+		return word + "だった", nil
+	case FormNegative:
+		return word + "じゃない", nil
+	case FormPolite:
+		return word + "です", nil
+	}
+	return "", ErrUnsupported
+}
+
+// Check reports whether the answer is the form of a word written as word or
+// as reading. The formal ではない is accepted for じゃない.
+func Check(word, reading string, class Class, form Form, answer string) bool {
+	answer = strings.Trim(answer, " 　.,!?。、！？")
+	answer = strings.Replace(answer, "ではない", "じゃない", 1)
+	for _, w := range []string{word, reading} {
+		if expected, err := Conjugate(w, class, form); err == nil && answer == expected {
+			return true
+		}
+	}
+	return false
+}
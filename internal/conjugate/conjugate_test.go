@@ -0,0 +1,158 @@
+package conjugate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConjugate(t *testing.T) {
+	tests := []struct {
+		word  string
+		class Class
+		form  Form
+		want  string
+	}{
+		{"食べる", ClassIchidan, FormTe, "食べて"},
+		{"食べる", ClassIchidan, FormPotential, "食べられる"},
+		{"見る", ClassIchidan, FormCausative, "見させる"},
+		{"見る", ClassIchidan, FormHonorific, "ご覧になる"},
+		{"教える", ClassIchidan, FormHonorific, "お教えになる"},
+		{"書く", ClassGodan, FormTe, "書いて"},
+		{"書く", ClassGodan, FormHumble, "お書きする"},
+		{"行く", ClassGodan, FormTa, "行った"},
+		{"いく", ClassGodan, FormTe, "いって"},
+		{"泳ぐ", ClassGodan, FormTa, "泳いだ"},
+		{"話す", ClassGodan, FormTe, "話して"},
+		{"待つ", ClassGodan, FormPotential, "待てる"},
+		{"死ぬ", ClassGodan, FormTa, "死んだ"},
+		{"遊ぶ", ClassGodan, FormVolitional, "遊ぼう"},
+		{"読む", ClassGodan, FormPassive, "読まれる"},
+		{"買う", ClassGodan, FormNegative, "買わない"},
+		{"帰る", ClassGodan, FormPolite, "帰ります"},
+		{"ある", ClassGodan, FormNegative, "ない"},
+		{"いらっしゃる", ClassGodan, FormPolite, "いらっしゃいます"},
+		{"する", ClassSuru, FormPotential, "できる"},
+		{"勉強する", ClassSuru, FormCausative, "勉強させる"},
+		{"勉強する", ClassSuru, FormHumble, "勉強いたす"},
+		{"来る", ClassKuru, FormNegative, "来ない"},
+		{"くる", ClassKuru, FormNegative, "こない"},
+		{"くる", ClassKuru, FormTe, "きて"},
+		{"くる", ClassKuru, FormHonorific, "いらっしゃる"},
+		{"持って来る", ClassKuru, FormHonorific, "持っていらっしゃる"},
+		{"持って来る", ClassKuru, FormHumble, "持って参る"},
+		{"持って来る", ClassKuru, FormTe, "持って来て"},
+		{"出来る", ClassIchidan, FormNegative, "出来ない"},
+		{"分かる", ClassGodan, FormHonorific, "お分かりになる"},
+		{"高い", ClassIAdjective, FormTa, "高かった"},
+		{"いい", ClassIAdjective, FormNegative, "よくない"},
+		{"いい", ClassIAdjective, FormPolite, "いいです"},
+		{"静か", ClassNaAdjective, FormTe, "静かで"},
+		{"静か", ClassNaAdjective, FormNegative, "静かじゃない"},
+	}
+
+	for _, tt := range tests {
+		got, err := Conjugate(tt.word, tt.class, tt.form)
+		if err != nil {
+			t.Errorf("Conjugate(%s, %s, %s): %v", tt.word, tt.class, tt.form, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Conjugate(%s, %s, %s) = %s, want %s", tt.word, tt.class, tt.form, got, tt.want)
+		}
+	}
+}
+
+func TestConjugateUnsupported(t *testing.T) {
+	if _, err := Conjugate("高い", ClassIAdjective, FormPassive); err != ErrUnsupported {
+		t.Errorf("passive of an adjective: got %v, want ErrUnsupported", err)
+	}
+	// stative verbs have no potential, causative or humble forms
+	for _, tt := range []struct {
+		word  string
+		class Class
+		form  Form
+	}{
+		{"出来る", ClassIchidan, FormPotential},
+		{"出来る", ClassIchidan, FormCausative},
+		{"出来る", ClassIchidan, FormHumble},
+		{"ある", ClassGodan, FormPotential},
+		{"ある", ClassGodan, FormHumble},
+		{"分かる", ClassGodan, FormPotential},
+		{"要る", ClassGodan, FormHonorific},
+		{"見える", ClassIchidan, FormPassive},
+		{"聞こえる", ClassIchidan, FormHumble},
+	} {
+		if got, err := Conjugate(tt.word, tt.class, tt.form); err != ErrUnsupported {
+			t.Errorf("Conjugate(%s, %s, %s) = %s, %v, want ErrUnsupported", tt.word, tt.class, tt.form, got, err)
+		}
+	}
+	if _, err := Conjugate("高い", ClassGodan, FormTe); err != ErrNotDictionary {
+		t.Errorf("godan verb ending in い: got %v, want ErrNotDictionary", err)
+	}
+}
+
+func TestClassifyVerb(t *testing.T) {
+	tests := []struct {
+		word, reading string
+		want          Class
+	}{
+		{"食べる", "たべる", ClassIchidan},
+		{"見る", "みる", ClassIchidan},
+		{"帰る", "かえる", ClassGodan},
+		{"変える", "かえる", ClassIchidan},
+		{"書く", "かく", ClassGodan},
+		{"勉強する", "べんきょうする", ClassSuru},
+		{"来る", "くる", ClassKuru},
+		{"作る", "つくる", ClassGodan},
+	}
+
+	for _, tt := range tests {
+		if got, ok := ClassifyVerb(tt.word, tt.reading); !ok || got != tt.want {
+			t.Errorf("ClassifyVerb(%s, %s) = %s, %v, want %s", tt.word, tt.reading, got, ok, tt.want)
+		}
+	}
+}
+
+func TestForms(t *testing.T) {
+	if forms := Forms("書く", ClassGodan); len(forms) != len(VerbForms) {
+		t.Errorf("Forms(書く) = %v, want all verb forms", forms)
+	}
+	forms := Forms("ある", ClassGodan)
+	for _, f := range []Form{FormPotential, FormHumble} {
+		if slices.Contains(forms, f) {
+			t.Errorf("Forms(ある) = %v, has %s", forms, f)
+		}
+	}
+	if !slices.Contains(forms, FormNegative) {
+		t.Errorf("Forms(ある) = %v, lacks the negative", forms)
+	}
+}
+
+func TestClassifyAdjective(t *testing.T) {
+	for word, want := range map[string]Class{"高い": ClassIAdjective, "綺麗": ClassNaAdjective, "有名": ClassNaAdjective} {
+		if got := ClassifyAdjective(word); got != want {
+			t.Errorf("ClassifyAdjective(%s) = %s, want %s", word, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"食べて", true},
+		{"たべて", true},
+		{" 食べて。", true},
+		{"食べって", false},
+	}
+	for _, tt := range tests {
+		if got := Check("食べる", "たべる", ClassIchidan, FormTe, tt.answer); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+
+	if !Check("静か", "しずか", ClassNaAdjective, FormNegative, "静かではない") {
+		t.Error("ではない should be accepted for じゃない")
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetAudioFileID returns the Telegram file_id of audio uploaded before under the cache key.
+func (s *storage) GetAudioFileID(key string) (string, error) {
+	var fileID string
+	err := s.db.QueryRow(`SELECT telegram_file_id FROM audio_files WHERE key = ?`, key).Scan(&fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error getting audio file id: %w", err)
+	}
+	return fileID, nil
+}
+
+func (s *storage) SaveAudioFileID(key, fileID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO audio_files (key, telegram_file_id) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET telegram_file_id = excluded.telegram_file_id`,
+		key, fileID,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving audio file id: %w", err)
+	}
+	return nil
+}
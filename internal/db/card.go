@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Card directions. Each word is reviewed separately in every direction.
+const (
+	// CardDirectionProduction shows the translation, the answer is the word in Japanese.
+	CardDirectionProduction = "ru_jp"
+	// CardDirectionRecognition shows the word, the answer is the translation.
+	CardDirectionRecognition = "jp_ru"
+	// CardDirectionReading shows the kanji, the answer is the reading in kana.
+	CardDirectionReading = "reading"
+)
+
+var CardDirections = []string{CardDirectionProduction, CardDirectionRecognition, CardDirectionReading}
+
+func IsValidCardDirection(direction string) bool {
+	switch direction {
+	case CardDirectionProduction, CardDirectionRecognition, CardDirectionReading:
+		return true
+	default:
+		return false
+	}
+}
+
+type Card struct {
+	Word      Word   `json:"word"`
+	Direction string `json:"direction"`
+}
+
+// GetNextCardForUser returns the most overdue card, or a random unseen one,
+// within the user's daily limits of reviews and new cards. Only the given
+// directions are considered, reading cards only for words written with kanji.
+// Suspended leeches are skipped.
+func (s *storage) GetNextCardForUser(userID int64, level string, directions []string) (Card, error) {
+	if len(directions) == 0 {
+		return Card{}, ErrNotFound
+	}
+
+	newWordsPerDay, reviewsPerDay, err := s.getUserLimits(userID)
+	if err != nil {
+		return Card{}, err
+	}
+
+	newWords, reviews, err := s.countTodayReviews(userID)
+	if err != nil {
+		return Card{}, err
+	}
+
+	values := make([]string, len(directions))
+	var args []interface{}
+	for i, d := range directions {
+		values[i] = "(?)"
+		args = append(args, d)
+	}
+	args = append(args, userID, level, reviews, reviewsPerDay, newWords, newWordsPerDay)
+
+	query := fmt.Sprintf(`
+		WITH directions(direction) AS (VALUES %s)
+		SELECT w.id, w.kanji, w.kana, w.translation, w.examples_json, w.level, w.audio_url, COALESCE(w.part_of_speech, ''), COALESCE(w.verb_class, ''), w.created_at, d.direction
+			FROM words w
+			CROSS JOIN directions d
+			LEFT JOIN word_reviews wr ON w.id = wr.word_id AND wr.user_id = ? AND wr.direction = d.direction
+			WHERE w.level = ?
+			AND (d.direction != 'reading' OR COALESCE(w.kanji, '') != '')
+			AND (
+				(wr.next_review IS NOT NULL AND wr.next_review <= DATETIME('now', 'localtime') AND NOT wr.suspended AND ? < ?) OR
+				(wr.word_id IS NULL AND ? < ?)
+			)
+			ORDER BY 
+				CASE WHEN wr.next_review IS NOT NULL AND wr.next_review <= DATETIME('now', 'localtime') THEN 0 ELSE 1 END,
+				wr.next_review ASC,
+				RANDOM()
+        LIMIT 1
+	`, strings.Join(values, ","))
+
+	var card Card
+	var examplesJSON sql.NullString
+	err = s.db.QueryRow(query, args...).Scan(
+		&card.Word.ID,
+		&card.Word.Kanji,
+		&card.Word.Kana,
+		&card.Word.Translation,
+		&examplesJSON,
+		&card.Word.Level,
+		&card.Word.AudioURL,
+		&card.Word.PartOfSpeech,
+		&card.Word.VerbClass,
+		&card.Word.CreatedAt,
+		&card.Direction,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Card{}, ErrNotFound
+		}
+		return Card{}, fmt.Errorf("error getting next card: %w", err)
+	}
+	if examplesJSON.Valid {
+		examples, err := UnmarshalJSONToStruct[[]Example](examplesJSON.String)
+		if err != nil {
+			return Card{}, fmt.Errorf("error unmarshalling examples JSON: %w", err)
+		}
+		card.Word.Examples = examples
+	}
+	return card, nil
+}
+
+// UpdateUserCardDirections sets which card directions a user studies.
+func (s *storage) UpdateUserCardDirections(userID int64, directions []string) error {
+	for _, d := range directions {
+		if !IsValidCardDirection(d) {
+			return fmt.Errorf("invalid card direction: %s", d)
+		}
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE users SET card_directions = ? WHERE telegram_id = ?`,
+		strings.Join(directions, ","), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating card directions: %w", err)
+	}
+	return nil
+}
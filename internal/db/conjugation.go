@@ -0,0 +1,38 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetRandomConjugationWord returns a random verb or adjective of the level.
+func (s *storage) GetRandomConjugationWord(level string) (Word, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		SELECT id FROM words
+		WHERE level = ? AND COALESCE(verb_class, '') != ''
+		ORDER BY RANDOM()
+		LIMIT 1`, level).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Word{}, ErrNotFound
+		}
+		return Word{}, fmt.Errorf("error getting conjugation word: %w", err)
+	}
+	return s.GetWordByID(id)
+}
+
+// MarkConjugationSent makes the word and form the user's current conjugation drill.
+func (s *storage) MarkConjugationSent(userID, wordID int64, form string) error {
+	_, err := s.db.Exec(
+		`UPDATE users
+		SET current_word_id = ?, current_conjugation_form = ?, current_mode = 'conjugate'
+		WHERE telegram_id = ?`,
+		wordID, form, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating current conjugation: %w", err)
+	}
+	return nil
+}
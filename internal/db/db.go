@@ -73,13 +73,39 @@ func MarshalStructToJSON[T any](src T) ([]byte, error) {
 	return data, nil
 }
 
+// wordReviewsTable is kept separately from the schema because migrations rebuild the table.
+const wordReviewsTable = `
+		CREATE TABLE IF NOT EXISTS word_reviews (
+			id INTEGER PRIMARY KEY,
+			word_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			direction TEXT NOT NULL DEFAULT 'ru_jp',
+			next_review TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			repetition INTEGER DEFAULT 0,
+			last_reviewed TIMESTAMP,
+			ease REAL DEFAULT 2.5,
+			stability REAL,
+			difficulty REAL,
+			lapses INTEGER DEFAULT 0,
+			last_grade INTEGER,
+			previous_state TEXT,
+			leech BOOLEAN DEFAULT 0,
+			suspended BOOLEAN DEFAULT 0,
+			mnemonic TEXT,
+			FOREIGN KEY (word_id) REFERENCES words(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			UNIQUE (word_id, user_id, direction)
+		);`
+
 var (
 	ErrNotFound      = errors.New("not found")
 	ErrAlreadyExists = errors.New("already exists")
 )
 
 type storage struct {
-	db *sql.DB
+	db             *sql.DB
+	scheduler      Scheduler
+	leechThreshold int
 }
 
 func init() {
@@ -131,6 +157,18 @@ func ConnectDB(dbPath string) (*storage, error) {
 		   current_exercise_id INTEGER,
 		   current_word_id INTEGER,
 		   current_mode TEXT DEFAULT 'exercise',
+		   current_word_sent_at TIMESTAMP,
+		   current_word_reviewed BOOLEAN DEFAULT 0,
+		   new_words_per_day INTEGER DEFAULT 20,
+		   reviews_per_day INTEGER DEFAULT 200,
+		   current_card_direction TEXT DEFAULT 'ru_jp',
+		   card_directions TEXT DEFAULT 'ru_jp,jp_ru,reading',
+		   show_romaji BOOLEAN DEFAULT 0,
+		   furigana_mode TEXT DEFAULT 'unknown',
+		   current_conjugation_form TEXT,
+		   current_kanji TEXT,
+		   current_reading_question INTEGER DEFAULT 0,
+		   current_reading_correct INTEGER DEFAULT 0,
 		   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		   username TEXT,
@@ -164,19 +202,56 @@ func ConnectDB(dbPath string) (*storage, error) {
 			examples_json TEXT,
 			level TEXT,
 			audio_url TEXT,
+			part_of_speech TEXT,
+			verb_class TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);		
-		CREATE TABLE IF NOT EXISTS word_reviews (
-    		id INTEGER PRIMARY KEY,
+		` + wordReviewsTable + `
+		CREATE TABLE IF NOT EXISTS review_log (
+			id INTEGER PRIMARY KEY,
 			word_id INTEGER NOT NULL,
 			user_id INTEGER NOT NULL,
-			next_review TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			repetition INTEGER DEFAULT 0,
-			last_reviewed TIMESTAMP,
+			direction TEXT NOT NULL DEFAULT 'ru_jp',
+			kind TEXT NOT NULL DEFAULT 'review',
+			user_input TEXT,
+			score INTEGER,
+			grade INTEGER NOT NULL,
+			elapsed_seconds INTEGER,
+			latency_ms INTEGER,
+			old_interval_seconds INTEGER,
+			new_interval_seconds INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (word_id) REFERENCES words(id),
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			UNIQUE (word_id, user_id)
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_review_log_user_word ON review_log (user_id, word_id);
+		CREATE TABLE IF NOT EXISTS audio_files (
+			key TEXT PRIMARY KEY,
+			telegram_file_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS kanji (
+			character TEXT PRIMARY KEY,
+			on_readings TEXT NOT NULL,
+			kun_readings TEXT NOT NULL,
+			meanings TEXT NOT NULL,
+			level TEXT,
+			stroke_count INTEGER,
+			radicals TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS mock_attempts (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			level TEXT NOT NULL,
+			questions TEXT NOT NULL,
+			answers TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			deadline TIMESTAMP NOT NULL,
+			finished_at TIMESTAMP,
+			result TEXT,
+			FOREIGN KEY (user_id) REFERENCES users(id)
 		);
+		CREATE INDEX IF NOT EXISTS idx_mock_attempts_user ON mock_attempts (user_id);
 		CREATE TABLE IF NOT EXISTS user_rankings (
 			id INTEGER PRIMARY KEY,
 			user_id INTEGER NOT NULL,
@@ -194,24 +269,170 @@ func ConnectDB(dbPath string) (*storage, error) {
 		return nil, err
 	}
 	// Ensure blocked_at column exists for users table
-	if _, err := db.Exec("ALTER TABLE users ADD COLUMN blocked_at TIMESTAMP"); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+	if err := addColumn(db, "users", "blocked_at TIMESTAMP"); err != nil {
+		return nil, err
+	}
+
+	userColumns := []string{
+		"current_word_sent_at TIMESTAMP",
+		"current_word_reviewed BOOLEAN DEFAULT 0",
+		"new_words_per_day INTEGER DEFAULT 20",
+		"reviews_per_day INTEGER DEFAULT 200",
+		"current_card_direction TEXT DEFAULT 'ru_jp'",
+		"card_directions TEXT DEFAULT 'ru_jp,jp_ru,reading'",
+		"show_romaji BOOLEAN DEFAULT 0",
+		"furigana_mode TEXT DEFAULT 'unknown'",
+		"current_conjugation_form TEXT",
+		"current_kanji TEXT",
+		"current_reading_question INTEGER DEFAULT 0",
+		"current_reading_correct INTEGER DEFAULT 0",
+	}
+	for _, column := range userColumns {
+		if err := addColumn(db, "users", column); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := migrateWordReviews(db); err != nil {
+		return nil, err
+	}
+
+	if err := addColumn(db, "words", "part_of_speech TEXT"); err != nil {
+		return nil, err
+	}
+	if err := classifyPartsOfSpeech(db); err != nil {
+		return nil, err
+	}
+
+	if err := addColumn(db, "words", "verb_class TEXT"); err != nil {
+		return nil, err
+	}
+	if err := classifyVerbClasses(db); err != nil {
+		return nil, err
+	}
+
+	if err := addColumn(db, "review_log", "direction TEXT NOT NULL DEFAULT 'ru_jp'"); err != nil {
+		return nil, err
+	}
+	if err := backfillReviewLog(db); err != nil {
 		return nil, err
 	}
 
-	return &storage{db: db}, nil
+	return &storage{db: db, scheduler: NewSM2Scheduler(), leechThreshold: DefaultLeechThreshold}, nil
+}
+
+// addColumn adds a column to an existing table, ignoring the error if it already exists.
+func addColumn(db *sql.DB, table, definition string) error {
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition)
+	if _, err := db.Exec(query); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+	return nil
+}
+
+// migrateWordReviews adds the scheduler state columns to word_reviews and
+// seeds rows created by the old fixed-interval scheduler: the current interval
+// becomes the FSRS stability and the SM-2 ease starts from its default.
+func migrateWordReviews(db *sql.DB) error {
+	columns := []string{
+		"ease REAL DEFAULT 2.5",
+		"stability REAL",
+		"difficulty REAL",
+		"lapses INTEGER DEFAULT 0",
+		"last_grade INTEGER",
+		"previous_state TEXT",
+		"leech BOOLEAN DEFAULT 0",
+		"suspended BOOLEAN DEFAULT 0",
+		"mnemonic TEXT",
+		"direction TEXT NOT NULL DEFAULT 'ru_jp'",
+	}
+	for _, column := range columns {
+		if err := addColumn(db, "word_reviews", column); err != nil {
+			return err
+		}
+	}
+
+	if err := rebuildWordReviews(db); err != nil {
+		return fmt.Errorf("error rebuilding word reviews: %w", err)
+	}
+
+	_, err := db.Exec(`
+		UPDATE word_reviews
+		SET stability  = MAX(COALESCE(julianday(next_review) - julianday(last_reviewed), 0), 0.1),
+		    difficulty = 5.0
+		WHERE stability IS NULL AND last_reviewed IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("error migrating word reviews: %w", err)
+	}
+
+	return nil
+}
+
+// rebuildWordReviews recreates word_reviews if it still has the old unique
+// key on (word_id, user_id), which SQLite can not alter in place. Existing
+// reviews become production (ru_jp) cards.
+func rebuildWordReviews(db *sql.DB) error {
+	var tableSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'word_reviews'`).Scan(&tableSQL); err != nil {
+		return err
+	}
+
+	if !strings.Contains(tableSQL, "UNIQUE (word_id, user_id)") {
+		return nil
+	}
+
+	columns := `id, word_id, user_id, direction, next_review, repetition, last_reviewed, ease, stability,
+		difficulty, lapses, last_grade, previous_state, leech, suspended, mnemonic`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`ALTER TABLE word_reviews RENAME TO word_reviews_old`,
+		wordReviewsTable,
+		fmt.Sprintf(`INSERT INTO word_reviews (%s) SELECT %s FROM word_reviews_old`, columns, columns),
+		`DROP TABLE word_reviews_old`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func NewStorage(db *sql.DB) *storage {
 	return &storage{
-		db: db,
+		db:             db,
+		scheduler:      NewSM2Scheduler(),
+		leechThreshold: DefaultLeechThreshold,
 	}
 }
 
+// SetScheduler sets the spaced repetition algorithm used by SaveWordReview.
+func (s *storage) SetScheduler(scheduler Scheduler) {
+	s.scheduler = scheduler
+}
+
 var (
 	ExerciseTypeTranslation = "translation"
 	ExerciseTypeQuestion    = "question"
 	ExerciseTypeAudio       = "audio"
 	ExerciseTypeGrammar     = "grammar"
+	ExerciseTypeShadowing   = "shadowing"
+	// ExerciseTypeMultipleChoice is answered with a button and checked without AI.
+	ExerciseTypeMultipleChoice = "multiple_choice"
+	// ExerciseTypeCloze is a sentence with a blank, checked without AI.
+	ExerciseTypeCloze = "cloze"
+	// ExerciseTypeReorder is a sentence assembled from shuffled chunks with buttons.
+	ExerciseTypeReorder = "reorder"
+	// ExerciseTypeReading is a passage with several questions asked one by one.
+	ExerciseTypeReading = "reading"
 )
 
 func (s *storage) Health() (HealthStats, error) {
@@ -46,6 +46,55 @@ type AudioContent struct {
 	Question string `json:"question"`
 }
 
+// ShadowingContent is a sentence the user repeats after the recording.
+type ShadowingContent struct {
+	Text    string `json:"text"`
+	Reading string `json:"reading"` // the text in kana as it should be pronounced
+}
+
+// MultipleChoiceContent is a question with options, Correct is the index of
+// the right option.
+type MultipleChoiceContent struct {
+	Stem    string   `json:"stem"`
+	Options []string `json:"options"`
+	Correct int      `json:"correct"`
+}
+
+// ClozeBlank replaces the removed fragment in a cloze sentence.
+const ClozeBlank = "＿＿"
+
+// ClozeHintParticle is the hint of cloze exercises with a particle blanked out.
+const ClozeHintParticle = "частица"
+
+// ClozeContent is an example sentence with one fragment replaced by
+// ClozeBlank. Answers are the removed fragment and its reading.
+type ClozeContent struct {
+	Sentence    []Sentence `json:"sentence"`
+	Translation string     `json:"translation"`
+	Hint        string     `json:"hint"`
+	Answers     []string   `json:"answers"`
+}
+
+// ReorderContent is a sentence split into chunks in the right order.
+type ReorderContent struct {
+	Chunks      []string `json:"chunks"`
+	Translation string   `json:"translation"`
+}
+
+// ReadingQuestion is a question about a reading passage, Correct is the index
+// of the right option.
+type ReadingQuestion struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Correct  int      `json:"correct"`
+}
+
+// ReadingContent is a passage with questions about it.
+type ReadingContent struct {
+	Passage   string            `json:"passage"`
+	Questions []ReadingQuestion `json:"questions"`
+}
+
 type SentenceContent struct {
 	Japanese string `json:"japanese"`
 	Russian  string `json:"russian"`
@@ -178,7 +227,17 @@ func (s *storage) GetExercisesByLevel(level string) ([]Exercise, error) {
 
 func (s *storage) GetExercisesByLevelAndType(level, exType string) ([]Exercise, error) {
 	query := `SELECT id, level, content, type, created_at FROM exercises WHERE level = ? AND type = ?`
-	rows, err := s.db.Query(query, level, exType)
+	return s.queryExercises(query, level, exType)
+}
+
+// GetRandomExercises returns up to limit random exercises of a level and type.
+func (s *storage) GetRandomExercises(level, exType string, limit int) ([]Exercise, error) {
+	query := `SELECT id, level, content, type, created_at FROM exercises WHERE level = ? AND type = ? ORDER BY RANDOM() LIMIT ?`
+	return s.queryExercises(query, level, exType, limit)
+}
+
+func (s *storage) queryExercises(query string, args ...interface{}) ([]Exercise, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying exercises: %w", err)
 	}
@@ -213,6 +272,10 @@ func (s *storage) GetExercisesByLevelAndType(level, exType string) ([]Exercise,
 
 	return out, nil
 }
+
+// GetNextExerciseForUser returns a random exercise the user hasn't solved. The
+// type is picked first so that types generated from the vocabulary, which have
+// thousands of exercises, don't crowd out the hand-written ones.
 func (s *storage) GetNextExerciseForUser(userID int64, level string, exTypes []string) (Exercise, error) {
 	placeholders := make([]string, len(exTypes))
 	args := []interface{}{userID, level}
@@ -222,20 +285,25 @@ func (s *storage) GetNextExerciseForUser(userID int64, level string, exTypes []s
 	}
 
 	query := fmt.Sprintf(`
-		SELECT e.id, e.level, e.content, e.type, e.created_at
-		FROM exercises e
-		LEFT JOIN (
-			SELECT exercise_id, COUNT(*) as times_shown
-			FROM user_submissions
-			WHERE user_id = ?
-			GROUP BY exercise_id
-		) ue ON e.id = ue.exercise_id
-		WHERE e.level = ? AND e.type IN (%s)
-		AND (
-			ue.exercise_id IS NULL
-			OR (e.type = 'grammar' AND ue.times_shown < 2)
-			OR (e.type != 'grammar' AND ue.exercise_id IS NULL)
+		WITH candidates AS (
+			SELECT e.id, e.level, e.content, e.type, e.created_at
+			FROM exercises e
+			LEFT JOIN (
+				SELECT exercise_id, COUNT(*) as times_shown
+				FROM user_submissions
+				WHERE user_id = ?
+				GROUP BY exercise_id
+			) ue ON e.id = ue.exercise_id
+			WHERE e.level = ? AND e.type IN (%s)
+			AND (
+				ue.exercise_id IS NULL
+				OR (e.type = 'grammar' AND ue.times_shown < 2)
+				OR (e.type != 'grammar' AND ue.exercise_id IS NULL)
+			)
 		)
+		SELECT id, level, content, type, created_at
+		FROM candidates
+		WHERE type = (SELECT type FROM candidates GROUP BY type ORDER BY RANDOM() LIMIT 1)
 		ORDER BY RANDOM()
 		LIMIT 1
 	`, strings.Join(placeholders, ","))
@@ -268,7 +336,7 @@ func (s *storage) GetNextExerciseForUser(userID int64, level string, exTypes []s
 func (s *storage) MarkExerciseSent(userID, exerciseID int64) error {
 	updateQuery := `
 		UPDATE users	
-		SET current_exercise_id = ?, current_mode = 'exercise'
+		SET current_exercise_id = ?, current_mode = 'exercise', current_reading_question = 0, current_reading_correct = 0
 		WHERE id = ?
 	`
 
@@ -280,6 +348,21 @@ func (s *storage) MarkExerciseSent(userID, exerciseID int64) error {
 	return nil
 }
 
+// MarkReadingAnswered moves the user to the next question of the current
+// reading passage.
+func (s *storage) MarkReadingAnswered(userID int64, question, correct int) error {
+	_, err := s.db.Exec(
+		`UPDATE users
+		SET current_reading_question = ?, current_reading_correct = ?
+		WHERE telegram_id = ?`,
+		question, correct, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating reading question: %w", err)
+	}
+	return nil
+}
+
 func (s *storage) ClearUserExercise(userID int64) error {
 	updateQuery := `
 		UPDATE users
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"unicode"
+)
+
+const (
+	FuriganaModeAlways  = "always"
+	FuriganaModeUnknown = "unknown"
+	FuriganaModeNever   = "never"
+)
+
+var FuriganaModes = []string{FuriganaModeAlways, FuriganaModeUnknown, FuriganaModeNever}
+
+func IsValidFuriganaMode(mode string) bool {
+	switch mode {
+	case FuriganaModeAlways, FuriganaModeUnknown, FuriganaModeNever:
+		return true
+	default:
+		return false
+	}
+}
+
+// knownWordRepetitions is the number of successful reviews in a row after
+// which the kanji of a word count as known.
+const knownWordRepetitions = 2
+
+// GetKnownKanji returns the kanji of the words the user has recalled
+// successfully several times in a row in any direction.
+func (s *storage) GetKnownKanji(userID int64) (map[rune]bool, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT w.kanji
+		FROM word_reviews wr
+		JOIN words w ON w.id = wr.word_id
+		WHERE wr.user_id = ? AND wr.repetition >= ? AND w.kanji IS NOT NULL`,
+		userID, knownWordRepetitions,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting known kanji: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[rune]bool)
+	for rows.Next() {
+		var kanji string
+		if err := rows.Scan(&kanji); err != nil {
+			return nil, fmt.Errorf("error scanning known kanji: %w", err)
+		}
+		for _, r := range kanji {
+			if unicode.Is(unicode.Han, r) {
+				known[r] = true
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating known kanji rows: %w", err)
+	}
+
+	return known, nil
+}
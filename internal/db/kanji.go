@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type Kanji struct {
+	Character   string   `db:"character" json:"character"`
+	OnReadings  []string `db:"on_readings" json:"on_readings"`   // in katakana
+	KunReadings []string `db:"kun_readings" json:"kun_readings"` // okurigana after a dot, as in KANJIDIC
+	Meanings    []string `db:"meanings" json:"meanings"`
+	Level       string   `db:"level" json:"level"` // JLPT level, empty if the kanji is not on the lists
+	StrokeCount int      `db:"stroke_count" json:"stroke_count"`
+	Radicals    []int    `db:"radicals" json:"radicals"` // Kangxi radical numbers
+}
+
+// Readings returns the on and kun readings of the kanji.
+func (k Kanji) Readings() []string {
+	return append(append([]string{}, k.OnReadings...), k.KunReadings...)
+}
+
+// SaveKanjiBatch inserts kanji, replacing the ones already stored.
+func (s *storage) SaveKanjiBatch(kanji []Kanji) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO kanji (character, on_readings, kun_readings, meanings, level, stroke_count, radicals)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(character) DO UPDATE SET
+			on_readings = excluded.on_readings,
+			kun_readings = excluded.kun_readings,
+			meanings = excluded.meanings,
+			level = excluded.level,
+			stroke_count = excluded.stroke_count,
+			radicals = excluded.radicals
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, k := range kanji {
+		var values []interface{}
+		for _, list := range []interface{}{k.OnReadings, k.KunReadings, k.Meanings, k.Radicals} {
+			listJSON, err := json.Marshal(list)
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("error marshalling kanji %s: %w", k.Character, err)
+			}
+			values = append(values, string(listJSON))
+		}
+
+		if _, err := stmt.Exec(k.Character, values[0], values[1], values[2], k.Level, k.StrokeCount, values[3]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error saving kanji %s: %w", k.Character, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *storage) IsKanjiInitialized() (bool, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM kanji`).Scan(&count); err != nil {
+		return false, fmt.Errorf("error checking if kanji are initialized: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *storage) GetKanji(character string) (Kanji, error) {
+	var k Kanji
+	var onJSON, kunJSON, meaningsJSON, radicalsJSON string
+	err := s.db.QueryRow(`
+		SELECT character, on_readings, kun_readings, meanings, COALESCE(level, ''), stroke_count, radicals
+		FROM kanji WHERE character = ?`, character,
+	).Scan(&k.Character, &onJSON, &kunJSON, &meaningsJSON, &k.Level, &k.StrokeCount, &radicalsJSON)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Kanji{}, ErrNotFound
+		}
+		return Kanji{}, fmt.Errorf("error getting kanji: %w", err)
+	}
+
+	for _, f := range []struct {
+		raw string
+		dst interface{}
+	}{
+		{onJSON, &k.OnReadings},
+		{kunJSON, &k.KunReadings},
+		{meaningsJSON, &k.Meanings},
+		{radicalsJSON, &k.Radicals},
+	} {
+		if err := json.Unmarshal([]byte(f.raw), f.dst); err != nil {
+			return Kanji{}, fmt.Errorf("error unmarshalling kanji %s: %w", character, err)
+		}
+	}
+
+	return k, nil
+}
+
+// GetWordsWithKanji returns up to limit words written with the kanji, the
+// easiest levels first.
+func (s *storage) GetWordsWithKanji(character string, limit int) ([]Word, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kanji, kana, translation, examples_json, level, COALESCE(part_of_speech, ''), COALESCE(verb_class, ''), created_at
+		FROM words
+		WHERE instr(kanji, ?) > 0
+		ORDER BY level DESC, id
+		LIMIT ?`, character, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting words with kanji: %w", err)
+	}
+	return scanWords(rows)
+}
+
+// GetKnownKanjiWords returns up to limit random words with kanji that the
+// user has recalled several times in a row.
+func (s *storage) GetKnownKanjiWords(userID int64, limit int) ([]Word, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kanji, kana, translation, examples_json, level, COALESCE(part_of_speech, ''), COALESCE(verb_class, ''), created_at
+		FROM words
+		WHERE COALESCE(kanji, '') != '' AND id IN (
+			SELECT word_id FROM word_reviews WHERE user_id = ? AND repetition >= ?
+		)
+		ORDER BY RANDOM()
+		LIMIT ?`, userID, knownWordRepetitions, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting known kanji words: %w", err)
+	}
+	return scanWords(rows)
+}
+
+// MarkKanjiSent makes a kanji of the word the user's current drill. mode is
+// ModeKanjiReading or ModeKanjiWriting.
+func (s *storage) MarkKanjiSent(userID, wordID int64, character, mode string) error {
+	_, err := s.db.Exec(
+		`UPDATE users
+		SET current_word_id = ?, current_kanji = ?, current_mode = ?
+		WHERE telegram_id = ?`,
+		wordID, character, mode, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating current kanji: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const DefaultLeechThreshold = 8
+
+// SetLeechThreshold sets the number of lapses after which a word is
+// considered a leech and suspended.
+func (s *storage) SetLeechThreshold(threshold int) {
+	s.leechThreshold = threshold
+}
+
+// isLeechLapse reports whether going from prevLapses to lapses should
+// suspend the word. A word is suspended when it reaches the threshold and
+// again every half threshold after that, so an unsuspended leech that keeps
+// failing goes back to the list.
+func (s *storage) isLeechLapse(prevLapses, lapses int) bool {
+	if s.leechThreshold <= 0 || lapses <= prevLapses || lapses < s.leechThreshold {
+		return false
+	}
+	step := max(s.leechThreshold/2, 1)
+	return (lapses-s.leechThreshold)%step == 0
+}
+
+type Leech struct {
+	Word         Word      `json:"word"`
+	Direction    string    `json:"direction"`
+	Lapses       int       `json:"lapses"`
+	Suspended    bool      `json:"suspended"`
+	LastReviewed time.Time `json:"last_reviewed"`
+	Mnemonic     *string   `json:"mnemonic"`
+}
+
+// GetLeeches returns all words flagged as leeches for a user, suspended first.
+func (s *storage) GetLeeches(userID int64) ([]Leech, error) {
+	rows, err := s.db.Query(`
+		SELECT w.id, w.kanji, w.kana, w.translation, w.examples_json, w.level, w.audio_url, COALESCE(w.part_of_speech, ''), COALESCE(w.verb_class, ''), w.created_at,
+		       wr.direction, wr.lapses, wr.suspended, wr.last_reviewed, wr.mnemonic
+		FROM word_reviews wr
+		JOIN words w ON w.id = wr.word_id
+		WHERE wr.user_id = ? AND wr.leech
+		ORDER BY wr.suspended DESC, wr.lapses DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting leeches: %w", err)
+	}
+	defer rows.Close()
+
+	leeches := make([]Leech, 0)
+	for rows.Next() {
+		var l Leech
+		var examplesJSON sql.NullString
+		if err := rows.Scan(
+			&l.Word.ID,
+			&l.Word.Kanji,
+			&l.Word.Kana,
+			&l.Word.Translation,
+			&examplesJSON,
+			&l.Word.Level,
+			&l.Word.AudioURL,
+			&l.Word.PartOfSpeech,
+			&l.Word.VerbClass,
+			&l.Word.CreatedAt,
+			&l.Direction,
+			&l.Lapses,
+			&l.Suspended,
+			&l.LastReviewed,
+			&l.Mnemonic,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning leech: %w", err)
+		}
+		if examplesJSON.Valid {
+			examples, err := UnmarshalJSONToStruct[[]Example](examplesJSON.String)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling examples JSON: %w", err)
+			}
+			l.Word.Examples = examples
+		}
+		leeches = append(leeches, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating leech rows: %w", err)
+	}
+
+	return leeches, nil
+}
+
+// UnsuspendWord returns a suspended leech to the review queue. The word stays
+// flagged as a leech so it is presented with a mnemonic.
+func (s *storage) UnsuspendWord(userID, wordID int64, direction string) error {
+	res, err := s.db.Exec(`
+		UPDATE word_reviews
+		SET suspended = 0, next_review = ?
+		WHERE user_id = ? AND word_id = ? AND direction = ? AND suspended`,
+		time.Now(), userID, wordID, direction,
+	)
+	if err != nil {
+		return fmt.Errorf("error unsuspending word: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error unsuspending word: %w", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveWordMnemonic stores a mnemonic for a word in all card directions.
+func (s *storage) SaveWordMnemonic(userID, wordID int64, mnemonic string) error {
+	_, err := s.db.Exec(
+		`UPDATE word_reviews SET mnemonic = ? WHERE user_id = ? AND word_id = ?`,
+		mnemonic, userID, wordID,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving mnemonic: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultNewWordsPerDay = 20
+	DefaultReviewsPerDay  = 200
+)
+
+// DailyProgress is the number of words a user studied today against their daily limits.
+// Days are counted in UTC.
+type DailyProgress struct {
+	NewWords      int       `json:"new_words"`
+	NewWordsLimit int       `json:"new_words_limit"`
+	Reviews       int       `json:"reviews"`
+	ReviewsLimit  int       `json:"reviews_limit"`
+	DueReviews    int       `json:"due_reviews"`
+	ResetAt       time.Time `json:"reset_at"`
+}
+
+func (p DailyProgress) NewWordsLeft() int {
+	return max(p.NewWordsLimit-p.NewWords, 0)
+}
+
+func (p DailyProgress) ReviewsLeft() int {
+	return max(p.ReviewsLimit-p.Reviews, 0)
+}
+
+// countTodayReviews returns the number of cards the user saw for the first
+// time today and the number of other cards reviewed today.
+func (s *storage) countTodayReviews(userID int64) (newWords, reviews int, err error) {
+	query := `
+		WITH today AS (
+			SELECT rl.word_id || ':' || rl.direction AS card,
+			       NOT EXISTS (
+			           SELECT 1 FROM review_log p
+			           WHERE p.user_id = rl.user_id AND p.word_id = rl.word_id AND p.direction = rl.direction AND p.id < rl.id
+			       ) AS is_first
+			FROM review_log rl
+			WHERE rl.user_id = ? AND rl.kind = 'review' AND rl.created_at >= DATE('now')
+		)
+		SELECT COUNT(DISTINCT CASE WHEN is_first THEN card END),
+		       COUNT(DISTINCT card)
+		FROM today
+	`
+
+	var total int
+	if err := s.db.QueryRow(query, userID).Scan(&newWords, &total); err != nil {
+		return 0, 0, fmt.Errorf("error counting today's reviews: %w", err)
+	}
+
+	return newWords, total - newWords, nil
+}
+
+func (s *storage) getUserLimits(userID int64) (newWordsPerDay, reviewsPerDay int, err error) {
+	err = s.db.QueryRow(`
+		SELECT COALESCE(new_words_per_day, ?), COALESCE(reviews_per_day, ?)
+		FROM users WHERE id = ?`,
+		DefaultNewWordsPerDay, DefaultReviewsPerDay, userID,
+	).Scan(&newWordsPerDay, &reviewsPerDay)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting user limits: %w", err)
+	}
+	return newWordsPerDay, reviewsPerDay, nil
+}
+
+func (s *storage) GetDailyProgress(userID int64, level string) (DailyProgress, error) {
+	var p DailyProgress
+	var err error
+
+	if p.NewWordsLimit, p.ReviewsLimit, err = s.getUserLimits(userID); err != nil {
+		return p, err
+	}
+
+	if p.NewWords, p.Reviews, err = s.countTodayReviews(userID); err != nil {
+		return p, err
+	}
+
+	err = s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM word_reviews wr
+		JOIN words w ON w.id = wr.word_id
+		WHERE wr.user_id = ? AND w.level = ? AND wr.next_review <= DATETIME('now', 'localtime') AND NOT wr.suspended`,
+		userID, level,
+	).Scan(&p.DueReviews)
+	if err != nil {
+		return p, fmt.Errorf("error counting due reviews: %w", err)
+	}
+
+	now := time.Now().UTC()
+	p.ResetAt = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	return p, nil
+}
+
+// UpdateUserLimits sets the daily number of new words and reviews for a user.
+func (s *storage) UpdateUserLimits(userID int64, newWordsPerDay, reviewsPerDay int) error {
+	_, err := s.db.Exec(
+		`UPDATE users SET new_words_per_day = ?, reviews_per_day = ? WHERE telegram_id = ?`,
+		newWordsPerDay, reviewsPerDay, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating user limits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MockQuestion is a multiple-choice item of a mock test, Correct is the index
+// of the right option. Audio is the text read aloud for listening items.
+type MockQuestion struct {
+	Section    string   `json:"section"`
+	ExerciseID int64    `json:"exercise_id"`
+	Stem       string   `json:"stem"`
+	Audio      string   `json:"audio,omitempty"`
+	Options    []string `json:"options"`
+	Correct    int      `json:"correct"`
+}
+
+// MockSectionScore is the scaled score of a scored section of the JLPT.
+type MockSectionScore struct {
+	Section string `json:"section"`
+	Correct int    `json:"correct"`
+	Total   int    `json:"total"`
+	Score   int    `json:"score"`
+	Max     int    `json:"max"`
+	Min     int    `json:"min"` // the sectional pass mark
+}
+
+type MockResult struct {
+	Sections []MockSectionScore `json:"sections"`
+	Total    int                `json:"total"`
+	Max      int                `json:"max"`
+	PassMark int                `json:"pass_mark"`
+	Passed   bool               `json:"passed"`
+}
+
+// MockAttempt is a timed mock test. Answers holds the picked option of every
+// answered question in order, -1 for the ones left when the time ran out.
+type MockAttempt struct {
+	ID         int64          `db:"id" json:"id"`
+	UserID     int64          `db:"user_id" json:"user_id"`
+	Level      string         `db:"level" json:"level"`
+	Questions  []MockQuestion `db:"questions" json:"questions"`
+	Answers    []int          `db:"answers" json:"answers"`
+	StartedAt  time.Time      `db:"started_at" json:"started_at"`
+	Deadline   time.Time      `db:"deadline" json:"deadline"`
+	FinishedAt *time.Time     `db:"finished_at" json:"finished_at"`
+	Result     *MockResult    `db:"result" json:"result"`
+}
+
+func (s *storage) CreateMockAttempt(attempt *MockAttempt) error {
+	questionsJSON, err := json.Marshal(attempt.Questions)
+	if err != nil {
+		return fmt.Errorf("error marshalling mock questions: %w", err)
+	}
+	if attempt.Answers == nil {
+		attempt.Answers = []int{}
+	}
+	answersJSON, err := json.Marshal(attempt.Answers)
+	if err != nil {
+		return fmt.Errorf("error marshalling mock answers: %w", err)
+	}
+
+	res, err := s.db.Exec(`
+		INSERT INTO mock_attempts (user_id, level, questions, answers, started_at, deadline)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		attempt.UserID, attempt.Level, string(questionsJSON), string(answersJSON), attempt.StartedAt, attempt.Deadline,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating mock attempt: %w", err)
+	}
+
+	attempt.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting mock attempt id: %w", err)
+	}
+	return nil
+}
+
+// UpdateMockAttempt saves the answers of the attempt and its result once it
+// is finished.
+func (s *storage) UpdateMockAttempt(attempt MockAttempt) error {
+	answersJSON, err := json.Marshal(attempt.Answers)
+	if err != nil {
+		return fmt.Errorf("error marshalling mock answers: %w", err)
+	}
+
+	var resultJSON *string
+	if attempt.Result != nil {
+		b, err := json.Marshal(attempt.Result)
+		if err != nil {
+			return fmt.Errorf("error marshalling mock result: %w", err)
+		}
+		result := string(b)
+		resultJSON = &result
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE mock_attempts
+		SET answers = ?, finished_at = ?, result = ?
+		WHERE id = ?`,
+		string(answersJSON), attempt.FinishedAt, resultJSON, attempt.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating mock attempt: %w", err)
+	}
+	return nil
+}
+
+const mockAttemptColumns = `id, user_id, level, questions, answers, started_at, deadline, finished_at, result`
+
+func scanMockAttempt(row interface{ Scan(...interface{}) error }) (MockAttempt, error) {
+	var a MockAttempt
+	var questionsJSON, answersJSON string
+	var finishedAt sql.NullTime
+	var resultJSON sql.NullString
+	if err := row.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.Level,
+		&questionsJSON,
+		&answersJSON,
+		&a.StartedAt,
+		&a.Deadline,
+		&finishedAt,
+		&resultJSON,
+	); err != nil {
+		return a, err
+	}
+
+	if err := json.Unmarshal([]byte(questionsJSON), &a.Questions); err != nil {
+		return a, fmt.Errorf("error unmarshalling mock questions: %w", err)
+	}
+	if err := json.Unmarshal([]byte(answersJSON), &a.Answers); err != nil {
+		return a, fmt.Errorf("error unmarshalling mock answers: %w", err)
+	}
+	if finishedAt.Valid {
+		a.FinishedAt = &finishedAt.Time
+	}
+	if resultJSON.Valid {
+		var result MockResult
+		if err := json.Unmarshal([]byte(resultJSON.String), &result); err != nil {
+			return a, fmt.Errorf("error unmarshalling mock result: %w", err)
+		}
+		a.Result = &result
+	}
+	return a, nil
+}
+
+func (s *storage) GetMockAttempt(userID, attemptID int64) (MockAttempt, error) {
+	row := s.db.QueryRow(`SELECT `+mockAttemptColumns+` FROM mock_attempts WHERE id = ? AND user_id = ?`, attemptID, userID)
+	a, err := scanMockAttempt(row)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	} else if err != nil {
+		return a, fmt.Errorf("error getting mock attempt: %w", err)
+	}
+	return a, nil
+}
+
+// GetActiveMockAttempt returns the last attempt of the user that is not
+// finished yet. Its deadline may have passed.
+func (s *storage) GetActiveMockAttempt(userID int64) (MockAttempt, error) {
+	row := s.db.QueryRow(`
+		SELECT `+mockAttemptColumns+` FROM mock_attempts
+		WHERE user_id = ? AND finished_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1`, userID)
+	a, err := scanMockAttempt(row)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	} else if err != nil {
+		return a, fmt.Errorf("error getting active mock attempt: %w", err)
+	}
+	return a, nil
+}
+
+// GetMockAttempts returns up to limit finished attempts of the user, newest first.
+func (s *storage) GetMockAttempts(userID int64, limit int) ([]MockAttempt, error) {
+	rows, err := s.db.Query(`
+		SELECT `+mockAttemptColumns+` FROM mock_attempts
+		WHERE user_id = ? AND finished_at IS NOT NULL
+		ORDER BY id DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting mock attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]MockAttempt, 0)
+	for rows.Next() {
+		a, err := scanMockAttempt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning mock attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return attempts, nil
+}
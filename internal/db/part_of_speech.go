@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	PartOfSpeechNoun      = "noun"
+	PartOfSpeechVerb      = "verb"
+	PartOfSpeechAdjective = "adjective"
+	PartOfSpeechOther     = "other"
+)
+
+var (
+	verbEndings      = []string{"ть", "ться", "ти", "тись", "чь", "чься"}
+	adjectiveEndings = []string{"ый", "ий", "ой", "ая", "яя", "ое", "ее"}
+)
+
+// counterReadings are the native numbers counted with つ, they end like
+// godan verbs and are translated with "пять" or "шесть".
+var counterReadings = map[string]bool{
+	"ひとつ": true, "ふたつ": true, "みっつ": true, "よっつ": true, "いつつ": true,
+	"むっつ": true, "ななつ": true, "やっつ": true, "ここのつ": true, "いくつ": true,
+}
+
+// ClassifyPartOfSpeech guesses the part of speech of a word from the first
+// sense of its Russian translation, the materials don't carry it.
+func ClassifyPartOfSpeech(word Word) string {
+	kana := word.Kana
+	if kana == "" {
+		return PartOfSpeechOther
+	}
+
+	sense := firstSense(word.Translation)
+	// greetings, questions and interjections
+	if sense == "" || strings.ContainsAny(sense, "!?…") {
+		return PartOfSpeechOther
+	}
+
+	// "быть свободным" is a verb, "красный цвет" a noun
+	fields := strings.Fields(sense)
+	head := fields[0]
+	last := []rune(kana)[len([]rune(kana))-1]
+	// "необходимость" ends like a verb too
+	if hasAnySuffix(head, verbEndings) && !strings.HasSuffix(head, "ость") && hasVerbEnding(word) {
+		return PartOfSpeechVerb
+	}
+	if isAdjectiveSense(sense) && (last == 'い' || last == 'な') {
+		return PartOfSpeechAdjective
+	}
+	return PartOfSpeechNoun
+}
+
+// hasVerbEnding reports whether a word is spelled with the kana ending of a
+// verb in the dictionary form: 待つ is, 九 "く" and the counter 五つ are not.
+func hasVerbEnding(word Word) bool {
+	spelling, reading := word.DictionaryForm()
+	if counterReadings[reading] {
+		return false
+	}
+	spelled := []rune(spelling)
+	read := []rune(reading)
+	if len(spelled) == 0 || len(read) == 0 {
+		return false
+	}
+	last := spelled[len(spelled)-1]
+	return last == read[len(read)-1] && strings.ContainsRune("うくぐすつぬぶむる", last)
+}
+
+// firstSense is the first meaning of a translation without explanations
+// like "медицина (наука)".
+func firstSense(translation string) string {
+	sense := strings.ToLower(strings.TrimSpace(strings.Split(translation, ",")[0]))
+	if i := strings.Index(sense, "("); i > 0 {
+		sense = strings.TrimSpace(sense[:i])
+	}
+	return sense
+}
+
+// isAdjectiveSense reports whether a sense is a single Russian adjective.
+func isAdjectiveSense(sense string) bool {
+	fields := strings.Fields(sense)
+	return len(fields) == 1 && hasAnySuffix(fields[0], adjectiveEndings)
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyPartsOfSpeech classifies the saved words again, so words saved
+// before the part of speech was stored or by older heuristics are updated.
+func classifyPartsOfSpeech(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, kanji, kana, translation, COALESCE(part_of_speech, '') FROM words`)
+	if err != nil {
+		return fmt.Errorf("error getting words: %w", err)
+	}
+
+	var words []Word
+	for rows.Next() {
+		var w Word
+		if err := rows.Scan(&w.ID, &w.Kanji, &w.Kana, &w.Translation, &w.PartOfSpeech); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning word: %w", err)
+		}
+		words = append(words, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating word rows: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, w := range words {
+		partOfSpeech := ClassifyPartOfSpeech(w)
+		if partOfSpeech == w.PartOfSpeech {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE words SET part_of_speech = ? WHERE id = ?`, partOfSpeech, w.ID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating part of speech: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	ReviewLogKindReview   = "review"
+	ReviewLogKindOverride = "override"
+	// ReviewLogKindMigrated stands for the reviews made before the review log
+	// existed, so those cards don't count as new.
+	ReviewLogKindMigrated = "migrated"
+)
+
+// ReviewLogEntry is a single answer to a word. Entries are never updated, a
+// grade override is recorded as a new entry of kind "override".
+type ReviewLogEntry struct {
+	ID                 int64     `db:"id" json:"id"`
+	WordID             int64     `db:"word_id" json:"word_id"`
+	UserID             int64     `db:"user_id" json:"user_id"`
+	Direction          string    `db:"direction" json:"direction"`
+	Kind               string    `db:"kind" json:"kind"`
+	UserInput          *string   `db:"user_input" json:"user_input"`
+	Score              *int      `db:"score" json:"score"`
+	Grade              Grade     `db:"grade" json:"grade"`
+	ElapsedSeconds     int64     `db:"elapsed_seconds" json:"elapsed_seconds"`
+	LatencyMS          *int64    `db:"latency_ms" json:"latency_ms"`
+	OldIntervalSeconds int64     `db:"old_interval_seconds" json:"old_interval_seconds"`
+	NewIntervalSeconds int64     `db:"new_interval_seconds" json:"new_interval_seconds"`
+	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+}
+
+func insertReviewLog(tx *sql.Tx, entry ReviewLogEntry) error {
+	_, err := tx.Exec(`
+		INSERT INTO review_log (word_id, user_id, direction, kind, user_input, score, grade, elapsed_seconds, latency_ms, old_interval_seconds, new_interval_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.WordID,
+		entry.UserID,
+		entry.Direction,
+		entry.Kind,
+		entry.UserInput,
+		entry.Score,
+		entry.Grade,
+		entry.ElapsedSeconds,
+		entry.LatencyMS,
+		entry.OldIntervalSeconds,
+		entry.NewIntervalSeconds,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving review log: %w", err)
+	}
+
+	return nil
+}
+
+// backfillReviewLog records the last review of cards reviewed before the
+// review log existed, otherwise their next review counts as a new word.
+func backfillReviewLog(db *sql.DB) error {
+	_, err := db.Exec(`
+		INSERT INTO review_log (word_id, user_id, direction, kind, grade, new_interval_seconds, created_at)
+		SELECT wr.word_id, wr.user_id, wr.direction, ?, COALESCE(wr.last_grade, ?),
+		       CAST(MAX(COALESCE(julianday(wr.next_review) - julianday(wr.last_reviewed), 0), 0) * 86400 AS INTEGER),
+		       wr.last_reviewed
+		FROM word_reviews wr
+		WHERE wr.last_reviewed IS NOT NULL AND NOT EXISTS (
+		    SELECT 1 FROM review_log rl
+		    WHERE rl.user_id = wr.user_id AND rl.word_id = wr.word_id AND rl.direction = wr.direction
+		)`,
+		ReviewLogKindMigrated, GradeGood,
+	)
+	if err != nil {
+		return fmt.Errorf("error backfilling review log: %w", err)
+	}
+	return nil
+}
+
+// GetReviewLog returns the review history of a word in all directions for a user, newest first.
+func (s *storage) GetReviewLog(userID, wordID int64, limit int) ([]ReviewLogEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, word_id, user_id, direction, kind, user_input, score, grade,
+		       COALESCE(elapsed_seconds, 0), latency_ms, COALESCE(old_interval_seconds, 0),
+		       new_interval_seconds, created_at
+		FROM review_log
+		WHERE user_id = ? AND word_id = ?
+		ORDER BY id DESC
+		LIMIT ?`,
+		userID, wordID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting review log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]ReviewLogEntry, 0)
+	for rows.Next() {
+		var e ReviewLogEntry
+		if err := rows.Scan(
+			&e.ID,
+			&e.WordID,
+			&e.UserID,
+			&e.Direction,
+			&e.Kind,
+			&e.UserInput,
+			&e.Score,
+			&e.Grade,
+			&e.ElapsedSeconds,
+			&e.LatencyMS,
+			&e.OldIntervalSeconds,
+			&e.NewIntervalSeconds,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning review log entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating review log rows: %w", err)
+	}
+
+	return entries, nil
+}
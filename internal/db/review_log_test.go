@@ -0,0 +1,52 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReviewsBeforeReviewLogAreNotNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	storage, err := ConnectDB(path)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	if err := storage.SaveUser(&User{TelegramID: 1, Level: LevelN5}); err != nil {
+		t.Fatalf("failed to save user: %v", err)
+	}
+	if err := storage.SaveWordsBatch([]Word{word("水", "みず", "вода"), word("火", "ひ", "огонь")}); err != nil {
+		t.Fatalf("failed to save words: %v", err)
+	}
+
+	// a card reviewed before the review log existed
+	reviewed := time.Now().Add(-48 * time.Hour)
+	if _, err := storage.db.Exec(`
+		INSERT INTO word_reviews (word_id, user_id, direction, next_review, repetition, last_reviewed)
+		VALUES (1, 1, ?, ?, 1, ?)`,
+		CardDirectionProduction, reviewed.Add(24*time.Hour), reviewed,
+	); err != nil {
+		t.Fatalf("failed to save word review: %v", err)
+	}
+	storage.Close()
+
+	if storage, err = ConnectDB(path); err != nil {
+		t.Fatalf("failed to reconnect to database: %v", err)
+	}
+	defer storage.Close()
+
+	for _, wordID := range []int64{1, 2} {
+		submission := TranslationSubmission{WordID: wordID, UserID: 1, Direction: CardDirectionProduction, Grade: GradeGood}
+		if err := storage.SaveWordReview(submission); err != nil {
+			t.Fatalf("failed to save word review: %v", err)
+		}
+	}
+
+	newWords, reviews, err := storage.countTodayReviews(1)
+	if err != nil {
+		t.Fatalf("failed to count reviews: %v", err)
+	}
+	if newWords != 1 || reviews != 1 {
+		t.Errorf("got %d new words and %d reviews, want 1 and 1", newWords, reviews)
+	}
+}
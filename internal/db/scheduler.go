@@ -0,0 +1,262 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Grade is the recall quality of a single review, from Again (forgotten) to Easy.
+type Grade int
+
+const (
+	GradeAgain Grade = 1
+	GradeHard  Grade = 2
+	GradeGood  Grade = 3
+	GradeEasy  Grade = 4
+)
+
+func (g Grade) IsValid() bool {
+	return g >= GradeAgain && g <= GradeEasy
+}
+
+const (
+	easyRecallLatency = 15 * time.Second
+	slowRecallLatency = 60 * time.Second
+)
+
+// GradeRecall maps an evaluated answer to a grade. The score is the 0–100
+// evaluation of the answer, latency is the time since the word was sent
+// (zero if unknown) and revealed is set when the user asked for the answer.
+func GradeRecall(score int, latency time.Duration, revealed bool) Grade {
+	switch {
+	case revealed || score < 80:
+		return GradeAgain
+	case score < 90 || latency > slowRecallLatency:
+		return GradeHard
+	case score >= 95 && latency > 0 && latency <= easyRecallLatency:
+		return GradeEasy
+	default:
+		return GradeGood
+	}
+}
+
+const (
+	SchedulerSM2  = "sm2"
+	SchedulerFSRS = "fsrs"
+)
+
+const (
+	minReviewInterval = 4 * time.Hour
+	maxReviewInterval = 365 * 24 * time.Hour
+	day               = 24 * time.Hour
+)
+
+// ReviewState is the scheduling state of a word for a user.
+type ReviewState struct {
+	Repetition   int           `json:"repetition"`
+	Ease         float64       `json:"ease"`
+	Stability    float64       `json:"stability"` // days
+	Difficulty   float64       `json:"difficulty"`
+	Lapses       int           `json:"lapses"`
+	Interval     time.Duration `json:"interval"`
+	LastReviewed time.Time     `json:"last_reviewed"`
+	NextReview   time.Time     `json:"next_review"`
+}
+
+// IsNew reports whether the word has never been reviewed.
+func (s ReviewState) IsNew() bool {
+	return s.LastReviewed.IsZero()
+}
+
+// Scheduler computes the next review state of a word from its current state and a grade.
+type Scheduler interface {
+	Next(state ReviewState, grade Grade, now time.Time) ReviewState
+}
+
+func NewScheduler(algorithm string) (Scheduler, error) {
+	switch algorithm {
+	case SchedulerSM2, "":
+		return NewSM2Scheduler(), nil
+	case SchedulerFSRS:
+		return NewFSRSScheduler(), nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler: %s", algorithm)
+	}
+}
+
+func clampInterval(d time.Duration) time.Duration {
+	if d < minReviewInterval {
+		return minReviewInterval
+	}
+	if d > maxReviewInterval {
+		return maxReviewInterval
+	}
+	return d
+}
+
+func days(d float64) time.Duration {
+	return time.Duration(d * float64(day))
+}
+
+// SM2Scheduler implements the SuperMemo-2 algorithm with an ease factor per word.
+// Unlike the original algorithm a lapse only shrinks the interval instead of
+// resetting it, so a single mistake on a well-known word does not send it back
+// to the start.
+type SM2Scheduler struct {
+	InitialEase float64
+	MinEase     float64
+	LapseFactor float64
+}
+
+func NewSM2Scheduler() *SM2Scheduler {
+	return &SM2Scheduler{
+		InitialEase: 2.5,
+		MinEase:     1.3,
+		LapseFactor: 0.5,
+	}
+}
+
+func (s *SM2Scheduler) Next(state ReviewState, grade Grade, now time.Time) ReviewState {
+	next := state
+	if next.Ease == 0 {
+		next.Ease = s.InitialEase
+	}
+
+	// SM-2 quality: Again=2, Hard=3, Good=4, Easy=5
+	q := float64(grade) + 1
+	next.Ease += 0.1 - (5-q)*(0.08+(5-q)*0.02)
+	if next.Ease < s.MinEase {
+		next.Ease = s.MinEase
+	}
+
+	if grade == GradeAgain {
+		if !state.IsNew() {
+			next.Lapses++
+		}
+		next.Repetition = 0
+		next.Interval = clampInterval(time.Duration(float64(state.Interval) * s.LapseFactor))
+	} else {
+		// after a lapse the shrunken interval is kept as the starting point
+		switch next.Repetition {
+		case 0:
+			next.Interval = max(day, state.Interval)
+		case 1:
+			next.Interval = max(6*day, state.Interval)
+		default:
+			factor := next.Ease
+			switch grade {
+			case GradeHard:
+				factor = 1.2
+			case GradeEasy:
+				factor = next.Ease * 1.3
+			}
+			next.Interval = time.Duration(float64(state.Interval) * factor)
+		}
+		if grade == GradeEasy && next.Repetition < 2 {
+			next.Interval *= 2
+		}
+		next.Repetition++
+		next.Interval = clampInterval(next.Interval)
+	}
+
+	next.LastReviewed = now
+	next.NextReview = now.Add(next.Interval)
+	return next
+}
+
+// FSRSScheduler implements the Free Spaced Repetition Scheduler (FSRS-4.5),
+// which models each word with a memory stability and difficulty.
+type FSRSScheduler struct {
+	Weights          [17]float64
+	RequestRetention float64
+}
+
+var defaultFSRSWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+func NewFSRSScheduler() *FSRSScheduler {
+	return &FSRSScheduler{
+		Weights:          defaultFSRSWeights,
+		RequestRetention: 0.9,
+	}
+}
+
+func (f *FSRSScheduler) retrievability(elapsedDays, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*elapsedDays/stability, fsrsDecay)
+}
+
+func (f *FSRSScheduler) initialDifficulty(grade Grade) float64 {
+	return clampDifficulty(f.Weights[4] - float64(grade-3)*f.Weights[5])
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, 1), 10)
+}
+
+func (f *FSRSScheduler) nextDifficulty(d float64, grade Grade) float64 {
+	next := d - f.Weights[6]*float64(grade-3)
+	// mean reversion towards the initial difficulty of a "Good" answer
+	next = f.Weights[7]*f.initialDifficulty(GradeGood) + (1-f.Weights[7])*next
+	return clampDifficulty(next)
+}
+
+func (f *FSRSScheduler) recallStability(d, s, r float64, grade Grade) float64 {
+	w := f.Weights
+	hardPenalty, easyBonus := 1.0, 1.0
+	if grade == GradeHard {
+		hardPenalty = w[15]
+	}
+	if grade == GradeEasy {
+		easyBonus = w[16]
+	}
+	return s * (math.Exp(w[8])*(11-d)*math.Pow(s, -w[9])*(math.Exp(w[10]*(1-r))-1)*hardPenalty*easyBonus + 1)
+}
+
+func (f *FSRSScheduler) forgetStability(d, s, r float64) float64 {
+	w := f.Weights
+	return w[11] * math.Pow(d, -w[12]) * (math.Pow(s+1, w[13]) - 1) * math.Exp(w[14]*(1-r))
+}
+
+func (f *FSRSScheduler) Next(state ReviewState, grade Grade, now time.Time) ReviewState {
+	next := state
+
+	if state.IsNew() || state.Stability <= 0 {
+		next.Stability = f.Weights[grade-1]
+		next.Difficulty = f.initialDifficulty(grade)
+	} else {
+		elapsed := now.Sub(state.LastReviewed).Hours() / 24
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		r := f.retrievability(elapsed, state.Stability)
+		next.Difficulty = f.nextDifficulty(state.Difficulty, grade)
+		if grade == GradeAgain {
+			next.Stability = math.Min(f.forgetStability(state.Difficulty, state.Stability, r), state.Stability)
+		} else {
+			next.Stability = f.recallStability(state.Difficulty, state.Stability, r, grade)
+		}
+	}
+
+	if grade == GradeAgain {
+		if !state.IsNew() {
+			next.Lapses++
+		}
+		next.Repetition = 0
+	} else {
+		next.Repetition++
+	}
+
+	intervalDays := next.Stability / fsrsFactor * (math.Pow(f.RequestRetention, 1/fsrsDecay) - 1)
+	next.Interval = clampInterval(days(intervalDays))
+	next.LastReviewed = now
+	next.NextReview = now.Add(next.Interval)
+	return next
+}
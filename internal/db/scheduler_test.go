@@ -0,0 +1,149 @@
+package db
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSM2SchedulerNext(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	reviewed := func(repetition int, ease float64, interval time.Duration, lapses int) ReviewState {
+		return ReviewState{Repetition: repetition, Ease: ease, Interval: interval, Lapses: lapses, LastReviewed: now.Add(-interval)}
+	}
+
+	tests := []struct {
+		name           string
+		state          ReviewState
+		grade          Grade
+		wantInterval   time.Duration
+		wantEase       float64
+		wantRepetition int
+		wantLapses     int
+	}{
+		{"new again", ReviewState{}, GradeAgain, minReviewInterval, 2.18, 0, 0},
+		{"new hard", ReviewState{}, GradeHard, day, 2.36, 1, 0},
+		{"new good", ReviewState{}, GradeGood, day, 2.5, 1, 0},
+		{"new easy", ReviewState{}, GradeEasy, 2 * day, 2.6, 1, 0},
+		{"second good", reviewed(1, 2.5, day, 0), GradeGood, 6 * day, 2.5, 2, 0},
+		{"second easy", reviewed(1, 2.5, day, 0), GradeEasy, 12 * day, 2.6, 2, 0},
+		{"hard", reviewed(2, 2.5, 6*day, 0), GradeHard, days(7.2), 2.36, 3, 0},
+		{"good", reviewed(2, 2.5, 6*day, 0), GradeGood, 15 * day, 2.5, 3, 0},
+		{"easy", reviewed(2, 2.5, 6*day, 0), GradeEasy, days(6 * 2.6 * 1.3), 2.6, 3, 0},
+		{"lapse halves the interval", reviewed(3, 2.5, 10*day, 2), GradeAgain, 5 * day, 2.18, 0, 3},
+		{"lapse keeps the minimum interval", reviewed(3, 2.5, 6*time.Hour, 0), GradeAgain, minReviewInterval, 2.18, 0, 1},
+		{"good after a lapse keeps the interval", reviewed(0, 2.18, 5*day, 3), GradeGood, 5 * day, 2.18, 1, 3},
+		{"ease stays above the minimum", reviewed(3, 1.4, 10*day, 0), GradeAgain, 5 * day, 1.3, 0, 1},
+		{"interval stays below the maximum", reviewed(5, 2.5, 200*day, 0), GradeGood, maxReviewInterval, 2.5, 6, 0},
+	}
+
+	scheduler := NewSM2Scheduler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := scheduler.Next(tt.state, tt.grade, now)
+			if diff := next.Interval - tt.wantInterval; diff < -time.Second || diff > time.Second {
+				t.Errorf("interval = %v, want %v", next.Interval, tt.wantInterval)
+			}
+			if math.Abs(next.Ease-tt.wantEase) > 1e-9 {
+				t.Errorf("ease = %v, want %v", next.Ease, tt.wantEase)
+			}
+			if next.Repetition != tt.wantRepetition {
+				t.Errorf("repetition = %d, want %d", next.Repetition, tt.wantRepetition)
+			}
+			if next.Lapses != tt.wantLapses {
+				t.Errorf("lapses = %d, want %d", next.Lapses, tt.wantLapses)
+			}
+			if !next.LastReviewed.Equal(now) || !next.NextReview.Equal(now.Add(next.Interval)) {
+				t.Errorf("reviewed at %v, next review at %v", next.LastReviewed, next.NextReview)
+			}
+		})
+	}
+}
+
+func TestFSRSSchedulerNext(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	scheduler := NewFSRSScheduler()
+
+	var previous time.Duration
+	for _, grade := range []Grade{GradeAgain, GradeHard, GradeGood, GradeEasy} {
+		next := scheduler.Next(ReviewState{}, grade, now)
+		if next.Stability != scheduler.Weights[grade-1] {
+			t.Errorf("grade %d: stability = %v, want %v", grade, next.Stability, scheduler.Weights[grade-1])
+		}
+		if next.Lapses != 0 {
+			t.Errorf("grade %d: a new word lapses", grade)
+		}
+		if next.Interval < previous {
+			t.Errorf("grade %d: interval %v is shorter than %v of a lower grade", grade, next.Interval, previous)
+		}
+		previous = next.Interval
+	}
+
+	state := ReviewState{Repetition: 3, Stability: 20, Difficulty: 5, Interval: 20 * day, LastReviewed: now.Add(-20 * day)}
+	good := scheduler.Next(state, GradeGood, now)
+	if good.Stability <= state.Stability || good.Interval <= state.Interval || good.Repetition != 4 {
+		t.Errorf("good: stability %v, interval %v, repetition %d", good.Stability, good.Interval, good.Repetition)
+	}
+	again := scheduler.Next(state, GradeAgain, now)
+	if again.Stability > state.Stability || again.Interval >= state.Interval || again.Lapses != 1 || again.Repetition != 0 {
+		t.Errorf("again: stability %v, interval %v, lapses %d, repetition %d", again.Stability, again.Interval, again.Lapses, again.Repetition)
+	}
+}
+
+func TestIsLeechLapse(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  int
+		prevLapses int
+		lapses     int
+		want       bool
+	}{
+		{"below the threshold", 8, 6, 7, false},
+		{"reaches the threshold", 8, 7, 8, true},
+		{"no new lapse", 8, 8, 8, false},
+		{"between the steps", 8, 8, 9, false},
+		{"half a threshold later", 8, 11, 12, true},
+		{"a threshold later", 8, 15, 16, true},
+		{"threshold of one", 1, 4, 5, true},
+		{"disabled", 0, 7, 8, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &storage{leechThreshold: tt.threshold}
+			if got := s.isLeechLapse(tt.prevLapses, tt.lapses); got != tt.want {
+				t.Errorf("isLeechLapse(%d, %d) with threshold %d = %v, want %v", tt.prevLapses, tt.lapses, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveWordReviewSuspendsLeeches(t *testing.T) {
+	storage, err := ConnectDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	defer storage.Close()
+	storage.SetLeechThreshold(2)
+	if err := storage.SaveUser(&User{TelegramID: 1, Level: LevelN5}); err != nil {
+		t.Fatalf("failed to save user: %v", err)
+	}
+	if err := storage.SaveWordsBatch([]Word{word("水", "みず", "вода")}); err != nil {
+		t.Fatalf("failed to save words: %v", err)
+	}
+
+	for i, grade := range []Grade{GradeGood, GradeAgain, GradeAgain} {
+		submission := TranslationSubmission{WordID: 1, UserID: 1, Direction: CardDirectionProduction, Grade: grade}
+		if err := storage.SaveWordReview(submission); err != nil {
+			t.Fatalf("failed to save word review: %v", err)
+		}
+		review, err := storage.GetWordReview(1, 1, CardDirectionProduction)
+		if err != nil {
+			t.Fatalf("failed to get word review: %v", err)
+		}
+		if want := i == 2; review.Suspended != want || review.Leech != want {
+			t.Errorf("after review %d: leech %v, suspended %v, want %v", i+1, review.Leech, review.Suspended, want)
+		}
+	}
+}
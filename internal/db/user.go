@@ -4,35 +4,70 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
 type User struct {
-	ID                int64      `db:"id" json:"id"`
-	TelegramID        int64      `db:"telegram_id" json:"telegram_id"`
-	Level             string     `db:"level" json:"level"`
-	Points            float64    `db:"points" json:"points"`
-	ExercisesDone     int        `db:"exercises_done" json:"exercises_done"`
-	CurrentExerciseID *int64     `db:"current_exercise_id" json:"current_exercise_id"`
-	CurrentWordID     *int64     `db:"current_word_id" json:"current_word_id"`
-	CurrentMode       string     `db:"current_mode" json:"current_mode"`
-	LastName          *string    `db:"last_name" json:"last_name"`
-	FirstName         *string    `db:"first_name" json:"first_name"`
-	Username          *string    `db:"username" json:"username"`
-	AvatarURL         *string    `db:"avatar_url" json:"avatar_url"`
-	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
-	BlockedAt         *time.Time `db:"blocked_at" json:"blocked_at"`
+	ID                   int64      `db:"id" json:"id"`
+	TelegramID           int64      `db:"telegram_id" json:"telegram_id"`
+	Level                string     `db:"level" json:"level"`
+	Points               float64    `db:"points" json:"points"`
+	ExercisesDone        int        `db:"exercises_done" json:"exercises_done"`
+	CurrentExerciseID    *int64     `db:"current_exercise_id" json:"current_exercise_id"`
+	CurrentWordID        *int64     `db:"current_word_id" json:"current_word_id"`
+	CurrentMode          string     `db:"current_mode" json:"current_mode"`
+	CurrentWordSentAt    *time.Time `db:"current_word_sent_at" json:"current_word_sent_at"`
+	CurrentWordReviewed  bool       `db:"current_word_reviewed" json:"current_word_reviewed"`
+	CurrentCardDirection string     `db:"current_card_direction" json:"current_card_direction"`
+	// CurrentConjugationForm is the conjugate.Form asked in the conjugation drill.
+	CurrentConjugationForm string `db:"current_conjugation_form" json:"current_conjugation_form"`
+	// CurrentKanji is the kanji of the current word asked in the kanji drill.
+	CurrentKanji string `db:"current_kanji" json:"current_kanji"`
+	// CurrentReadingQuestion is the index of the question of the current
+	// reading passage, CurrentReadingCorrect counts the right answers so far.
+	CurrentReadingQuestion int        `db:"current_reading_question" json:"current_reading_question"`
+	CurrentReadingCorrect  int        `db:"current_reading_correct" json:"current_reading_correct"`
+	CardDirections         string     `db:"card_directions" json:"card_directions"`
+	ShowRomaji             bool       `db:"show_romaji" json:"show_romaji"`
+	FuriganaMode           string     `db:"furigana_mode" json:"furigana_mode"`
+	LastName               *string    `db:"last_name" json:"last_name"`
+	FirstName              *string    `db:"first_name" json:"first_name"`
+	Username               *string    `db:"username" json:"username"`
+	AvatarURL              *string    `db:"avatar_url" json:"avatar_url"`
+	CreatedAt              time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt              time.Time  `db:"updated_at" json:"updated_at"`
+	BlockedAt              *time.Time `db:"blocked_at" json:"blocked_at"`
 }
 
 const (
 	ModeExercise = "exercise"
 	ModeVocab    = "vocab"
+	// ModeConjugate is the /conjugate drill, the current word is conjugated.
+	ModeConjugate = "conjugate"
+	// ModeKanjiReading and ModeKanjiWriting are the /kanji drills, asking the
+	// reading of a kanji in the current word or the kanji by its reading.
+	ModeKanjiReading = "kanji_reading"
+	ModeKanjiWriting = "kanji_writing"
 )
 
+// Directions returns the card directions the user studies.
+func (u *User) Directions() []string {
+	var directions []string
+	for _, d := range strings.Split(u.CardDirections, ",") {
+		if IsValidCardDirection(d) {
+			directions = append(directions, d)
+		}
+	}
+	if len(directions) == 0 {
+		return []string{CardDirectionProduction}
+	}
+	return directions
+}
+
 func (s *storage) GetUser(telegramID int64) (*User, error) {
 	var user User
-	query := `SELECT id, telegram_id, username, avatar_url, first_name, last_name, level, points, exercises_done, current_exercise_id, current_word_id, current_mode, created_at, updated_at FROM users WHERE telegram_id = ?`
+	query := `SELECT id, telegram_id, username, avatar_url, first_name, last_name, level, points, exercises_done, current_exercise_id, current_word_id, current_mode, current_word_sent_at, current_word_reviewed, COALESCE(current_card_direction, 'ru_jp'), COALESCE(card_directions, ''), show_romaji, COALESCE(furigana_mode, 'unknown'), COALESCE(current_conjugation_form, ''), COALESCE(current_kanji, ''), COALESCE(current_reading_question, 0), COALESCE(current_reading_correct, 0), created_at, updated_at FROM users WHERE telegram_id = ?`
 	err := s.db.QueryRow(query, telegramID).Scan(
 		&user.ID,
 		&user.TelegramID,
@@ -46,6 +81,16 @@ func (s *storage) GetUser(telegramID int64) (*User, error) {
 		&user.CurrentExerciseID,
 		&user.CurrentWordID,
 		&user.CurrentMode,
+		&user.CurrentWordSentAt,
+		&user.CurrentWordReviewed,
+		&user.CurrentCardDirection,
+		&user.CardDirections,
+		&user.ShowRomaji,
+		&user.FuriganaMode,
+		&user.CurrentConjugationForm,
+		&user.CurrentKanji,
+		&user.CurrentReadingQuestion,
+		&user.CurrentReadingCorrect,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -114,7 +159,7 @@ func (s *storage) UpdateUser(user *User) error {
 
 // GetUsersPaginated returns users ordered by creation time with pagination.
 func (s *storage) GetUsersPaginated(limit, offset int) ([]User, error) {
-	query := `SELECT id, telegram_id, username, avatar_url, first_name, last_name, level, points, exercises_done, current_exercise_id, current_word_id, current_mode, created_at, updated_at, blocked_at FROM users ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	query := `SELECT id, telegram_id, username, avatar_url, first_name, last_name, level, points, exercises_done, current_exercise_id, current_word_id, current_mode, current_word_sent_at, current_word_reviewed, COALESCE(current_card_direction, 'ru_jp'), COALESCE(card_directions, ''), show_romaji, COALESCE(furigana_mode, 'unknown'), COALESCE(current_conjugation_form, ''), COALESCE(current_kanji, ''), COALESCE(current_reading_question, 0), COALESCE(current_reading_correct, 0), created_at, updated_at, blocked_at FROM users ORDER BY created_at DESC LIMIT ? OFFSET ?`
 	rows, err := s.db.Query(query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("error getting paginated users: %w", err)
@@ -124,7 +169,7 @@ func (s *storage) GetUsersPaginated(limit, offset int) ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.AvatarURL, &u.FirstName, &u.LastName, &u.Level, &u.Points, &u.ExercisesDone, &u.CurrentExerciseID, &u.CurrentWordID, &u.CurrentMode, &u.CreatedAt, &u.UpdatedAt, &u.BlockedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.AvatarURL, &u.FirstName, &u.LastName, &u.Level, &u.Points, &u.ExercisesDone, &u.CurrentExerciseID, &u.CurrentWordID, &u.CurrentMode, &u.CurrentWordSentAt, &u.CurrentWordReviewed, &u.CurrentCardDirection, &u.CardDirections, &u.ShowRomaji, &u.FuriganaMode, &u.CurrentConjugationForm, &u.CurrentKanji, &u.CurrentReadingQuestion, &u.CurrentReadingCorrect, &u.CreatedAt, &u.UpdatedAt, &u.BlockedAt); err != nil {
 			return nil, fmt.Errorf("error scanning user row: %w", err)
 		}
 		users = append(users, u)
@@ -135,6 +180,26 @@ func (s *storage) GetUsersPaginated(limit, offset int) ([]User, error) {
 	return users, nil
 }
 
+func (s *storage) UpdateUserShowRomaji(telegramID int64, show bool) error {
+	_, err := s.db.Exec(`UPDATE users SET show_romaji = ? WHERE telegram_id = ?`, show, telegramID)
+	if err != nil {
+		return fmt.Errorf("error updating romaji setting: %w", err)
+	}
+	return nil
+}
+
+func (s *storage) UpdateUserFuriganaMode(telegramID int64, mode string) error {
+	if !IsValidFuriganaMode(mode) {
+		return fmt.Errorf("invalid furigana mode: %s", mode)
+	}
+
+	_, err := s.db.Exec(`UPDATE users SET furigana_mode = ? WHERE telegram_id = ?`, mode, telegramID)
+	if err != nil {
+		return fmt.Errorf("error updating furigana mode: %w", err)
+	}
+	return nil
+}
+
 // SetUserBlocked updates the blocked_at timestamp for a user.
 func (s *storage) SetUserBlocked(userID int64, blocked bool) error {
 	var query string
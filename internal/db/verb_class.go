@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"jpbot/internal/conjugate"
+	"strings"
+)
+
+// suruMarkers mark nouns that become verbs with する, as in "挨拶（する）".
+var suruMarkers = []string{"（する）", "(する)"}
+
+// DictionaryForm returns the word and its reading as they are conjugated,
+// nouns marked with （する） get the する attached.
+func (w Word) DictionaryForm() (word, reading string) {
+	word = w.Kana
+	if w.Kanji != nil && *w.Kanji != "" {
+		word = *w.Kanji
+	}
+	for _, marker := range suruMarkers {
+		if stem, ok := strings.CutSuffix(word, marker); ok {
+			return stem + "する", strings.TrimSuffix(w.Kana, "する") + "する"
+		}
+	}
+	return word, w.Kana
+}
+
+// adjectiveLikeNouns are nouns translated with a Russian adjective used as
+// a noun, like 左 "левый".
+var adjectiveLikeNouns = map[string]bool{
+	"左": true, "右": true, "外": true, "隣": true, "隣り": true, "次": true, "次ぎ": true,
+	"上": true, "一番": true, "倍": true, "毎...": true, "旧": true, "昨": true, "小": true,
+	"来": true, "地下": true, "国際": true, "自動": true, "中古": true, "唯一": true,
+}
+
+var (
+	// personSuffixes make nouns for people: 警官, 大人, 学者, 公務員.
+	personSuffixes = []string{"官", "人", "者", "員"}
+	// nounSenseEndings are the feminine and neuter endings of adjectives used
+	// as nouns, "столовая" or "животное", adjectives are glossed masculine.
+	nounSenseEndings = []string{"ая", "яя", "ое", "ее"}
+	// prenominals only come before a noun and don't conjugate.
+	prenominals = map[string]bool{"大した": true, "或": true, "或る": true}
+)
+
+// ClassifyVerbClass guesses the conjugation class of a word, empty if the
+// word doesn't conjugate or any of its forms can't be built.
+func ClassifyVerbClass(word Word) string {
+	spelling, reading := word.DictionaryForm()
+	// notes like 一般（に） aren't part of the word
+	if strings.ContainsAny(spelling, "（）()") {
+		return ""
+	}
+
+	partOfSpeech := word.PartOfSpeech
+	if partOfSpeech == "" {
+		partOfSpeech = ClassifyPartOfSpeech(word)
+	}
+
+	var class conjugate.Class
+	switch {
+	case isSuruNoun(word):
+		class = conjugate.ClassSuru
+	case partOfSpeech == PartOfSpeechVerb:
+		class, _ = conjugate.ClassifyVerb(spelling, reading)
+	case isAdjectiveSense(firstSense(word.Translation)) && !isNounOrPrenominal(spelling, word.Translation):
+		class = conjugate.ClassifyAdjective(spelling)
+	}
+	if class == "" || !conjugates(spelling, class) {
+		return ""
+	}
+	return string(class)
+}
+
+// isSuruNoun reports whether a word is a noun marked with （する）.
+func isSuruNoun(word Word) bool {
+	for _, marker := range suruMarkers {
+		if word.Kanji != nil && strings.HasSuffix(*word.Kanji, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNounOrPrenominal reports whether a word with an adjective gloss is a
+// noun like 警官 "полицейский" or a pre-nominal like こんな "такой".
+func isNounOrPrenominal(spelling, translation string) bool {
+	if adjectiveLikeNouns[spelling] || prenominals[spelling] {
+		return true
+	}
+	if hasAnySuffix(spelling, personSuffixes) || hasAnySuffix(firstSense(translation), nounSenseEndings) {
+		return true
+	}
+	// こんな, 空の, 単なる and 役に立つ end in kana an adjective doesn't
+	return strings.ContainsRune("なのるつ", []rune(spelling)[len([]rune(spelling))-1])
+}
+
+// conjugates reports whether all forms of the class can be built from the
+// word, so a drill never hits a word the classification got wrong.
+func conjugates(word string, class conjugate.Class) bool {
+	for _, form := range conjugate.Forms(word, class) {
+		if _, err := conjugate.Conjugate(word, class, form); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// classifyVerbClasses classifies the saved words again, so words saved
+// before the verb class was stored or by older heuristics are updated.
+// Words that don't conjugate keep a NULL class.
+func classifyVerbClasses(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT id, kanji, kana, translation, COALESCE(part_of_speech, ''), COALESCE(verb_class, '')
+		FROM words`)
+	if err != nil {
+		return fmt.Errorf("error getting words: %w", err)
+	}
+
+	var words []Word
+	for rows.Next() {
+		var w Word
+		if err := rows.Scan(&w.ID, &w.Kanji, &w.Kana, &w.Translation, &w.PartOfSpeech, &w.VerbClass); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning word: %w", err)
+		}
+		words = append(words, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating word rows: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, w := range words {
+		class := ClassifyVerbClass(w)
+		if class == w.VerbClass {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE words SET verb_class = ? WHERE id = ?`, nullString(class), w.ID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating verb class: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,72 @@
+package db
+
+import "testing"
+
+func word(kanji, kana, translation string) Word {
+	w := Word{Kana: kana, Translation: translation}
+	if kanji != "" {
+		w.Kanji = &kanji
+	}
+	return w
+}
+
+func TestClassifyPartOfSpeech(t *testing.T) {
+	tests := []struct {
+		word Word
+		want string
+	}{
+		{word("待つ", "まつ", "ждать"), PartOfSpeechVerb},
+		{word("食べる", "たべる", "есть, кушать"), PartOfSpeechVerb},
+		{word("五つ", "いつつ", "пять"), PartOfSpeechNoun},
+		{word("九", "く", "девять"), PartOfSpeechNoun},
+		{word("夜", "よる", "ночь, вечер"), PartOfSpeechNoun},
+		{word("必要", "ひつよう", "необходимость"), PartOfSpeechNoun},
+		{word("", "ああ", "ах, ой, да"), PartOfSpeechNoun},
+		{word("", "こんにちは", "здравствуйте!"), PartOfSpeechOther},
+		{word("赤い", "あかい", "красный"), PartOfSpeechAdjective},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word.GetKanji(), func(t *testing.T) {
+			if got := ClassifyPartOfSpeech(tt.word); got != tt.want {
+				t.Errorf("ClassifyPartOfSpeech(%q) = %q, want %q", tt.word.GetKanji(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyVerbClass(t *testing.T) {
+	tests := []struct {
+		word Word
+		want string
+	}{
+		{word("待つ", "まつ", "ждать"), "godan"},
+		{word("食べる", "たべる", "есть, кушать"), "ichidan"},
+		{word("挨拶（する）", "あいさつ", "приветствие"), "suru"},
+		{word("赤い", "あかい", "красный"), "i_adjective"},
+		{word("静か", "しずか", "тихий, спокойный"), "na_adjective"},
+		{word("有名", "ゆうめい", "известный, знаменитый"), "na_adjective"},
+		// numerals, counters and nouns with a verb-like gloss
+		{word("五つ", "いつつ", "пять"), ""},
+		{word("九", "きゅう", "девять"), ""},
+		{word("夜", "よる", "ночь, вечер"), ""},
+		// nouns and pre-nominals with an adjective gloss
+		{word("警官", "けいかん", "полицейский"), ""},
+		{word("食堂", "しょくどう", "столовая"), ""},
+		{word("動物", "どうぶつ", "животное"), ""},
+		{word("大人", "おとな", "взрослый"), ""},
+		{word("左", "ひだり", "левый, слева, налево"), ""},
+		{word("隣り", "となり", "соседний, по соседству"), ""},
+		{word("", "こんな", "такой, подобный"), ""},
+		{word("大きな", "おおきな", "большой"), ""},
+		{word("一般（に）", "いっぱん", "общий, обычный, в общем"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word.GetKanji(), func(t *testing.T) {
+			if got := ClassifyVerbClass(tt.word); got != tt.want {
+				t.Errorf("ClassifyVerbClass(%q) = %q, want %q", tt.word.GetKanji(), got, tt.want)
+			}
+		})
+	}
+}
@@ -9,14 +9,18 @@ import (
 )
 
 type Word struct {
-	ID          int64     `db:"id"`
-	Kanji       *string   `db:"kanji"`
-	Kana        string    `db:"kana"`
-	Translation string    `db:"translation"`
-	Examples    []Example `db:"examples_json"`
-	Level       string    `db:"level"`
-	AudioURL    string    `db:"audio_url"`
-	CreatedAt   time.Time `db:"created_at"`
+	ID          int64     `db:"id" json:"id"`
+	Kanji       *string   `db:"kanji" json:"kanji"`
+	Kana        string    `db:"kana" json:"kana"`
+	Translation string    `db:"translation" json:"translation"`
+	Examples    []Example `db:"examples_json" json:"examples"`
+	Level       string    `db:"level" json:"level"`
+	AudioURL    string    `db:"audio_url" json:"audio_url"`
+	// PartOfSpeech is one of the PartOfSpeech constants.
+	PartOfSpeech string `db:"part_of_speech" json:"part_of_speech"`
+	// VerbClass is the conjugate.Class of verbs and adjectives, empty for other words.
+	VerbClass string    `db:"verb_class" json:"verb_class"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 func (w *Word) GetKanji() string {
@@ -37,17 +41,14 @@ type Example struct {
 	Translation string     `json:"translation"`
 }
 
-// Spaced repetition intervals (in hours)
-var intervals = []int{4, 8, 24, 48, 168, 336, 720}
-
 func (s *storage) SaveWordsBatch(words []Word) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	stmt, err := tx.Prepare(`
-		INSERT INTO words (kanji, kana, level, translation, examples_json,  audio_url)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO words (kanji, kana, level, translation, examples_json,  audio_url, part_of_speech, verb_class)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 
 	if err != nil {
@@ -63,6 +64,15 @@ func (s *storage) SaveWordsBatch(words []Word) error {
 			return fmt.Errorf("error marshalling examples to JSON: %w", err)
 		}
 
+		partOfSpeech := word.PartOfSpeech
+		if partOfSpeech == "" {
+			partOfSpeech = ClassifyPartOfSpeech(word)
+		}
+		verbClass := word.VerbClass
+		if verbClass == "" {
+			verbClass = ClassifyVerbClass(word)
+		}
+
 		if _, err := stmt.Exec(
 			word.Kanji,
 			word.Kana,
@@ -70,6 +80,8 @@ func (s *storage) SaveWordsBatch(words []Word) error {
 			word.Translation,
 			examplesJSON,
 			word.AudioURL,
+			partOfSpeech,
+			nullString(verbClass),
 		); err != nil {
 			tx.Rollback()
 			return err
@@ -78,55 +90,11 @@ func (s *storage) SaveWordsBatch(words []Word) error {
 	return tx.Commit()
 }
 
-func (s *storage) GetNextWordForUser(userID int64, level string) (Word, error) {
-	var word Word
-	query := `
-		SELECT w.id, w.kanji, w.kana, w.translation, w.examples_json, w.level, w.audio_url, w.created_at
-			FROM words w
-			LEFT JOIN word_reviews wr ON w.id = wr.word_id AND wr.user_id = ?
-			WHERE w.level = ? AND (
-				(wr.next_review IS NOT NULL AND wr.next_review <= DATETIME('now', 'localtime')) OR
-				(wr.word_id IS NULL)
-			)
-			ORDER BY 
-				CASE WHEN wr.next_review IS NOT NULL AND wr.next_review <= DATETIME('now', 'localtime') THEN 0 ELSE 1 END,
-				wr.next_review ASC,
-				RANDOM()
-        LIMIT 1
-	`
-
-	var examplesJSON sql.NullString
-	err := s.db.QueryRow(query, userID, level).Scan(
-		&word.ID,
-		&word.Kanji,
-		&word.Kana,
-		&word.Translation,
-		&examplesJSON,
-		&word.Level,
-		&word.AudioURL,
-		&word.CreatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Word{}, ErrNotFound
-		}
-		return Word{}, fmt.Errorf("error getting next word: %w", err)
-	}
-	if examplesJSON.Valid {
-		examples, err := UnmarshalJSONToStruct[[]Example](examplesJSON.String)
-		if err != nil {
-			return Word{}, fmt.Errorf("error unmarshalling examples JSON: %w", err)
-		}
-		word.Examples = examples
-	}
-	return word, nil
-}
-
 // GetWordByID fetches a word by its ID.
 func (s *storage) GetWordByID(wordID int64) (Word, error) {
 	var word Word
 	query := `
-       SELECT id, kanji, kana, translation, examples_json, level, audio_url, created_at
+       SELECT id, kanji, kana, translation, examples_json, level, audio_url, COALESCE(part_of_speech, ''), COALESCE(verb_class, ''), created_at
        FROM words WHERE id = ?
    `
 	var examplesJSON sql.NullString
@@ -138,6 +106,8 @@ func (s *storage) GetWordByID(wordID int64) (Word, error) {
 		&examplesJSON,
 		&word.Level,
 		&word.AudioURL,
+		&word.PartOfSpeech,
+		&word.VerbClass,
 		&word.CreatedAt,
 	)
 	if err != nil {
@@ -156,81 +126,276 @@ func (s *storage) GetWordByID(wordID int64) (Word, error) {
 	return word, nil
 }
 
-func calculateRepetition(currentRep int, isCorrect bool) int {
-	if !isCorrect {
-		return 0 // Reset on incorrect answer
+// GetSpellingReadings returns the readings of all words written with the
+// given kanji spelling, e.g. both あした and あす for 明日.
+func (s *storage) GetSpellingReadings(kanji string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT kana FROM words WHERE kanji = ?`, kanji)
+	if err != nil {
+		return nil, fmt.Errorf("error getting spelling readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []string
+	for rows.Next() {
+		var reading string
+		if err := rows.Scan(&reading); err != nil {
+			return nil, fmt.Errorf("error scanning spelling reading: %w", err)
+		}
+		readings = append(readings, reading)
 	}
-	return currentRep + 1
+	return readings, rows.Err()
 }
 
-func calculateNextReview(currentRep int, isCorrect bool) time.Time {
-	if !isCorrect {
-		return time.Now().Add(4 * time.Hour) // Quick retry for incorrect
+// GetWordsWithoutAudio returns up to limit words that have no pronunciation audio yet.
+func (s *storage) GetWordsWithoutAudio(limit int) ([]Word, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kanji, kana, translation, examples_json, level, COALESCE(part_of_speech, ''), COALESCE(verb_class, ''), created_at
+		FROM words
+		WHERE audio_url IS NULL OR audio_url = ''
+		ORDER BY id
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting words without audio: %w", err)
 	}
+	return scanWords(rows)
+}
 
-	intervalIndex := currentRep
-	if intervalIndex >= len(intervals) {
-		intervalIndex = len(intervals) - 1
+func (s *storage) GetWordsByLevel(level string) ([]Word, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kanji, kana, translation, examples_json, level, COALESCE(part_of_speech, ''), COALESCE(verb_class, ''), created_at
+		FROM words
+		WHERE level = ?
+		ORDER BY id`, level)
+	if err != nil {
+		return nil, fmt.Errorf("error getting words by level: %w", err)
 	}
+	return scanWords(rows)
+}
+
+// scanWords reads words selected without the audio url and closes the rows.
+func scanWords(rows *sql.Rows) ([]Word, error) {
+	defer rows.Close()
 
-	return time.Now().Add(time.Duration(intervals[intervalIndex]) * time.Hour)
+	var words []Word
+	for rows.Next() {
+		var word Word
+		var examplesJSON sql.NullString
+		if err := rows.Scan(
+			&word.ID,
+			&word.Kanji,
+			&word.Kana,
+			&word.Translation,
+			&examplesJSON,
+			&word.Level,
+			&word.PartOfSpeech,
+			&word.VerbClass,
+			&word.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning word: %w", err)
+		}
+		if examplesJSON.Valid {
+			examples, err := UnmarshalJSONToStruct[[]Example](examplesJSON.String)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling word examples: %w", err)
+			}
+			word.Examples = examples
+		}
+		words = append(words, word)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating word rows: %w", err)
+	}
+
+	return words, nil
+}
+
+func (s *storage) SetWordAudioURL(wordID int64, audioURL string) error {
+	_, err := s.db.Exec(`UPDATE words SET audio_url = ? WHERE id = ?`, audioURL, wordID)
+	if err != nil {
+		return fmt.Errorf("error setting word audio url: %w", err)
+	}
+	return nil
 }
 
 type WordReview struct {
-	ID           int       `json:"id"`
-	WordID       int       `json:"word_id"`
-	UserID       int       `json:"user_id"`
-	NextReview   time.Time `json:"next_review"`
-	Repetition   int       `json:"repetition"`
-	LastReviewed time.Time `json:"last_reviewed"`
+	ID            int          `json:"id"`
+	WordID        int          `json:"word_id"`
+	UserID        int          `json:"user_id"`
+	Direction     string       `json:"direction"`
+	NextReview    time.Time    `json:"next_review"`
+	Repetition    int          `json:"repetition"`
+	LastReviewed  time.Time    `json:"last_reviewed"`
+	Ease          float64      `json:"ease"`
+	Stability     float64      `json:"stability"`
+	Difficulty    float64      `json:"difficulty"`
+	Lapses        int          `json:"lapses"`
+	LastGrade     *Grade       `json:"last_grade"`
+	PreviousState *ReviewState `json:"previous_state"`
+	Leech         bool         `json:"leech"`
+	Suspended     bool         `json:"suspended"`
+	Mnemonic      *string      `json:"mnemonic"`
+
+	// wasLeech and wasSuspended are the flags before the latest review, nil
+	// for the reviews saved before they were kept
+	wasLeech, wasSuspended *bool
+}
+
+// previousReview is what previous_state holds: the review state before the
+// latest review and the leech flags it had then.
+type previousReview struct {
+	ReviewState
+	Leech     *bool `json:"leech,omitempty"`
+	Suspended *bool `json:"suspended,omitempty"`
+}
+
+func (r WordReview) State() ReviewState {
+	return ReviewState{
+		Repetition:   r.Repetition,
+		Ease:         r.Ease,
+		Stability:    r.Stability,
+		Difficulty:   r.Difficulty,
+		Lapses:       r.Lapses,
+		Interval:     r.NextReview.Sub(r.LastReviewed),
+		LastReviewed: r.LastReviewed,
+		NextReview:   r.NextReview,
+	}
 }
 
 type TranslationSubmission struct {
-	WordID      int64  `json:"word_id"`
-	UserID      int64  `json:"user_id"`
-	Translation string `json:"translation"`
-	IsCorrect   bool   `json:"is_correct"`
+	WordID      int64         `json:"word_id"`
+	UserID      int64         `json:"user_id"`
+	Direction   string        `json:"direction"`
+	Translation string        `json:"translation"`
+	UserInput   string        `json:"user_input"`
+	Score       int           `json:"score"`
+	Latency     time.Duration `json:"latency"`
+	Grade       Grade         `json:"grade"`
 }
 
-func (s *storage) SaveWordReview(submission TranslationSubmission) error {
-	// Update or create a review record
+func (t TranslationSubmission) IsCorrect() bool {
+	return t.Grade != GradeAgain
+}
+
+func (s *storage) GetWordReview(userID, wordID int64, direction string) (WordReview, error) {
 	var review WordReview
+	var previousState sql.NullString
 	err := s.db.QueryRow(`
-		SELECT id, word_id, user_id, next_review, repetition, last_reviewed
-		FROM word_reviews WHERE word_id = ? AND user_id = ?`,
-		submission.WordID, submission.UserID,
-	).Scan(&review.ID, &review.WordID, &review.UserID, &review.NextReview,
-		&review.Repetition, &review.LastReviewed)
-
-	var nextReview time.Time
-	var newRepetition int
-
-	if errors.Is(err, sql.ErrNoRows) {
-		// New review
-		newRepetition = calculateRepetition(0, submission.IsCorrect)
-		nextReview = calculateNextReview(0, submission.IsCorrect)
-		_, err = s.db.Exec(`
-			INSERT INTO word_reviews (word_id, user_id, next_review, repetition, last_reviewed)
-			VALUES (?, ?, ?, ?, ?)`,
-			submission.WordID, submission.UserID, nextReview, newRepetition, time.Now())
-	} else if err != nil {
-		return fmt.Errorf("error fetching word review: %w", err)
+		SELECT id, word_id, user_id, direction, next_review, repetition, last_reviewed,
+		       COALESCE(ease, 0), COALESCE(stability, 0), COALESCE(difficulty, 0), COALESCE(lapses, 0),
+		       last_grade, previous_state, COALESCE(leech, 0), COALESCE(suspended, 0), mnemonic
+		FROM word_reviews WHERE word_id = ? AND user_id = ? AND direction = ?`,
+		wordID, userID, direction,
+	).Scan(&review.ID, &review.WordID, &review.UserID, &review.Direction, &review.NextReview,
+		&review.Repetition, &review.LastReviewed,
+		&review.Ease, &review.Stability, &review.Difficulty, &review.Lapses,
+		&review.LastGrade, &previousState, &review.Leech, &review.Suspended, &review.Mnemonic)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WordReview{}, ErrNotFound
+		}
+		return WordReview{}, fmt.Errorf("error fetching word review: %w", err)
+	}
+
+	if previousState.Valid {
+		previous, err := UnmarshalJSONToStruct[previousReview](previousState.String)
+		if err != nil {
+			return WordReview{}, fmt.Errorf("error unmarshalling previous review state: %w", err)
+		}
+		review.PreviousState = &previous.ReviewState
+		review.wasLeech, review.wasSuspended = previous.Leech, previous.Suspended
+	}
+
+	return review, nil
+}
+
+func (s *storage) SaveWordReview(submission TranslationSubmission) error {
+	if !submission.Grade.IsValid() {
+		return fmt.Errorf("invalid grade: %d", submission.Grade)
+	}
+	if !IsValidCardDirection(submission.Direction) {
+		return fmt.Errorf("invalid card direction: %s", submission.Direction)
+	}
+
+	// Update or create a review record
+	review, err := s.GetWordReview(submission.UserID, submission.WordID, submission.Direction)
+	isNew := errors.Is(err, ErrNotFound)
+	if err != nil && !isNew {
+		return err
+	}
+
+	now := time.Now()
+	var state ReviewState
+	if !isNew {
+		state = review.State()
+	}
+	next := s.scheduler.Next(state, submission.Grade, now)
+
+	// The state before this review is kept so that the grade can be overridden later.
+	var previousState []byte
+	if !isNew {
+		previous := previousReview{ReviewState: state, Leech: &review.Leech, Suspended: &review.Suspended}
+		if previousState, err = MarshalStructToJSON(previous); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if isNew {
+		_, err = tx.Exec(`
+			INSERT INTO word_reviews (word_id, user_id, direction, next_review, repetition, last_reviewed, ease, stability, difficulty, lapses, last_grade)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			submission.WordID, submission.UserID, submission.Direction, next.NextReview, next.Repetition, next.LastReviewed,
+			next.Ease, next.Stability, next.Difficulty, next.Lapses, submission.Grade)
 	} else {
-		// Update the existing review
-		newRepetition = calculateRepetition(review.Repetition, submission.IsCorrect)
-		nextReview = calculateNextReview(review.Repetition, submission.IsCorrect)
-		_, err = s.db.Exec(`
+		suspend := s.isLeechLapse(state.Lapses, next.Lapses)
+		_, err = tx.Exec(`
 			UPDATE word_reviews 
-			SET next_review = ?, repetition = ?, last_reviewed = ?
-			WHERE word_id = ? AND user_id = ?`,
-			nextReview, newRepetition, time.Now(),
-			submission.WordID, submission.UserID)
+			SET next_review = ?, repetition = ?, last_reviewed = ?, ease = ?, stability = ?, difficulty = ?, lapses = ?,
+			    last_grade = ?, previous_state = ?, leech = leech OR ?, suspended = suspended OR ?
+			WHERE word_id = ? AND user_id = ? AND direction = ?`,
+			next.NextReview, next.Repetition, next.LastReviewed, next.Ease, next.Stability, next.Difficulty, next.Lapses,
+			submission.Grade, string(previousState), suspend, suspend,
+			submission.WordID, submission.UserID, submission.Direction)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving word review: %w", err)
+	}
+
+	entry := ReviewLogEntry{
+		WordID:             submission.WordID,
+		UserID:             submission.UserID,
+		Direction:          submission.Direction,
+		Kind:               ReviewLogKindReview,
+		Grade:              submission.Grade,
+		NewIntervalSeconds: int64(next.Interval.Seconds()),
+	}
+	if submission.UserInput != "" {
+		entry.UserInput = &submission.UserInput
+		entry.Score = &submission.Score
+	}
+	if submission.Latency > 0 {
+		latency := submission.Latency.Milliseconds()
+		entry.LatencyMS = &latency
+	}
+	if !isNew {
+		entry.ElapsedSeconds = int64(now.Sub(state.LastReviewed).Seconds())
+		entry.OldIntervalSeconds = int64(state.Interval.Seconds())
+	}
+	if err := insertReviewLog(tx, entry); err != nil {
+		return err
 	}
 
 	// Update user stats
-	if submission.IsCorrect {
+	if submission.IsCorrect() {
 		pointsToAdd := 0.5
-		_, err = s.db.Exec(`
+		_, err = tx.Exec(`
 		UPDATE users 
 		SET points = points + ?, exercises_done = exercises_done + 1
 		WHERE id = ?`,
@@ -243,13 +408,79 @@ func (s *storage) SaveWordReview(submission TranslationSubmission) error {
 		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (s *storage) MarkWordSent(userID, wordID int64) error {
+// RegradeWordReview replaces the grade of the latest review of a word and
+// reschedules it from the state it had before that review.
+func (s *storage) RegradeWordReview(userID, wordID int64, direction string, grade Grade) error {
+	if !grade.IsValid() {
+		return fmt.Errorf("invalid grade: %d", grade)
+	}
+
+	review, err := s.GetWordReview(userID, wordID, direction)
+	if err != nil {
+		return err
+	}
+
+	if review.LastGrade != nil && *review.LastGrade == grade {
+		return nil
+	}
+
+	var state ReviewState
+	if review.PreviousState != nil {
+		state = *review.PreviousState
+	}
+	next := s.scheduler.Next(state, grade, review.LastReviewed)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The flags set by the replaced review are undone, unless the new grade
+	// is a lapse that sets them again.
+	leech, suspended := review.Leech, review.Suspended
+	if review.wasLeech != nil && review.wasSuspended != nil {
+		leech, suspended = *review.wasLeech, *review.wasSuspended
+	}
+	suspend := s.isLeechLapse(state.Lapses, next.Lapses)
+	_, err = tx.Exec(`
+		UPDATE word_reviews
+		SET next_review = ?, repetition = ?, ease = ?, stability = ?, difficulty = ?, lapses = ?, last_grade = ?,
+		    leech = ?, suspended = ?
+		WHERE word_id = ? AND user_id = ? AND direction = ?`,
+		next.NextReview, next.Repetition, next.Ease, next.Stability, next.Difficulty, next.Lapses, grade,
+		leech || suspend, suspended || suspend,
+		wordID, userID, direction)
+	if err != nil {
+		return fmt.Errorf("error regrading word review: %w", err)
+	}
+
+	entry := ReviewLogEntry{
+		WordID:             wordID,
+		UserID:             userID,
+		Direction:          direction,
+		Kind:               ReviewLogKindOverride,
+		Grade:              grade,
+		OldIntervalSeconds: int64(review.State().Interval.Seconds()),
+		NewIntervalSeconds: int64(next.Interval.Seconds()),
+	}
+	if err := insertReviewLog(tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *storage) MarkWordSent(userID, wordID int64, direction string) error {
 	_, err := s.db.Exec(
-		`UPDATE users SET current_word_id = ?, current_mode = 'vocab' WHERE telegram_id = ?`,
-		wordID, userID,
+		`UPDATE users
+		SET current_word_id = ?, current_card_direction = ?, current_mode = 'vocab',
+		    current_word_sent_at = CURRENT_TIMESTAMP, current_word_reviewed = 0
+		WHERE telegram_id = ?`,
+		wordID, direction, userID,
 	)
 	if err != nil {
 		return fmt.Errorf("error updating current word: %w", err)
@@ -257,6 +488,19 @@ func (s *storage) MarkWordSent(userID, wordID int64) error {
 	return nil
 }
 
+// MarkWordReviewed records that the current word already has a review, so
+// retries of the same word are not scheduled again.
+func (s *storage) MarkWordReviewed(userID int64) error {
+	_, err := s.db.Exec(
+		`UPDATE users SET current_word_reviewed = 1 WHERE telegram_id = ?`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("error marking current word reviewed: %w", err)
+	}
+	return nil
+}
+
 func (s *storage) ClearUserWord(userID int64) error {
 	_, err := s.db.Exec(
 		`UPDATE users SET current_word_id = NULL WHERE telegram_id = ?`,
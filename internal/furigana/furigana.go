@@ -0,0 +1,105 @@
+// Package furigana renders example sentences with readings in the formats
+// used by the bot and the web app.
+package furigana
+
+import (
+	"html"
+	"jpbot/internal/db"
+	"jpbot/internal/kana"
+	"strings"
+)
+
+type Format string
+
+const (
+	// FormatTelegram is Telegram HTML with readings hidden under a spoiler: 漢字(<tg-spoiler>かんじ</tg-spoiler>).
+	FormatTelegram Format = "telegram"
+	// FormatBrackets is plain text with readings in brackets: 漢字(かんじ).
+	FormatBrackets Format = "brackets"
+	// FormatRuby is HTML for the web app: <ruby>漢字<rt>かんじ</rt></ruby>.
+	FormatRuby Format = "ruby"
+	// FormatKana replaces every fragment with its reading.
+	FormatKana Format = "kana"
+)
+
+// Filter decides whether the reading of a fragment is shown.
+type Filter func(fragment string) bool
+
+func Always(string) bool { return true }
+
+func Never(string) bool { return false }
+
+// Unknown shows readings only for fragments containing a kanji that is not known.
+func Unknown(known map[rune]bool) Filter {
+	return func(fragment string) bool {
+		for _, r := range fragment {
+			if kana.IsKanji(r) && !known[r] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ForMode returns the filter of a user's furigana mode.
+func ForMode(mode string, known map[rune]bool) Filter {
+	switch mode {
+	case db.FuriganaModeAlways:
+		return Always
+	case db.FuriganaModeNever:
+		return Never
+	default:
+		return Unknown(known)
+	}
+}
+
+// Render joins the fragments of a sentence in the given format. Readings are
+// added to fragments that have furigana and pass the filter, the kana format
+// ignores the filter. HTML formats are escaped.
+func Render(sentence []db.Sentence, format Format, show Filter) string {
+	var b strings.Builder
+	for _, s := range sentence {
+		if s.Furigana == nil || *s.Furigana == "" {
+			writeText(&b, format, s.Fragment)
+			continue
+		}
+
+		reading := *s.Furigana
+		if format == FormatKana {
+			b.WriteString(reading)
+			continue
+		}
+
+		if !show(s.Fragment) {
+			writeText(&b, format, s.Fragment)
+			continue
+		}
+
+		switch format {
+		case FormatTelegram:
+			b.WriteString(html.EscapeString(s.Fragment))
+			b.WriteString("(<tg-spoiler>")
+			b.WriteString(html.EscapeString(reading))
+			b.WriteString("</tg-spoiler>)")
+		case FormatRuby:
+			b.WriteString("<ruby>")
+			b.WriteString(html.EscapeString(s.Fragment))
+			b.WriteString("<rt>")
+			b.WriteString(html.EscapeString(reading))
+			b.WriteString("</rt></ruby>")
+		default:
+			b.WriteString(s.Fragment)
+			b.WriteString("(")
+			b.WriteString(reading)
+			b.WriteString(")")
+		}
+	}
+	return b.String()
+}
+
+func writeText(b *strings.Builder, format Format, text string) {
+	if format == FormatTelegram || format == FormatRuby {
+		text = html.EscapeString(text)
+	}
+	b.WriteString(text)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/labstack/echo/v4"
+	"io"
+	"jpbot/internal/audio"
+	"jpbot/internal/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// sendVoice sends synthesized speech to a chat, generating it only if it is
+// neither uploaded to Telegram nor in the audio store yet.
+func (h *handler) sendVoice(ctx context.Context, chatID int64, params audio.Params) error {
+	return h.sendStoredVoice(ctx, chatID, params.Key(), func() ([]byte, string, error) {
+		return h.loadAudio(params)
+	})
+}
+
+// sendWordVoice sends the pronunciation of a word made by the word audio job, if there is one yet.
+func (h *handler) sendWordVoice(ctx context.Context, chatID int64, word db.Word) {
+	if word.AudioURL == "" {
+		return
+	}
+
+	err := h.sendStoredVoice(ctx, chatID, word.AudioURL, func() ([]byte, string, error) {
+		r, err := h.audioStore.Get(word.AudioURL)
+		if err != nil {
+			return nil, "", err
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		return data, word.AudioURL, err
+	})
+	if err != nil {
+		log.Printf("Failed to send word audio: %v", err)
+	}
+}
+
+// sendStoredVoice resends audio uploaded before by its Telegram file_id,
+// otherwise it uploads the audio returned by load and remembers its file_id
+// under the key load returns with it.
+func (h *handler) sendStoredVoice(ctx context.Context, chatID int64, key string, load func() ([]byte, string, error)) error {
+	fileID, err := h.db.GetAudioFileID(key)
+	if err == nil {
+		voice := &telegram.SendVoiceParams{
+			ChatID: chatID,
+			Voice:  &models.InputFileString{Data: fileID},
+		}
+		_, err := h.bot.SendVoice(ctx, voice)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to send audio by file id, uploading it again: %v", err)
+	} else if !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to get audio file id: %v", err)
+	}
+
+	data, key, err := load()
+	if err != nil {
+		return err
+	}
+
+	voice := &telegram.SendVoiceParams{
+		ChatID: chatID,
+		Voice: &models.InputFileUpload{
+			Filename: "voice.ogg", // Telegram требует имя
+			Data:     bytes.NewReader(data),
+		},
+	}
+
+	msg, err := h.bot.SendVoice(ctx, voice)
+	if err != nil {
+		return fmt.Errorf("failed to send audio: %w", err)
+	}
+
+	if msg.Voice != nil {
+		if err := h.db.SaveAudioFileID(key, msg.Voice.FileID); err != nil {
+			log.Printf("Failed to save audio file id: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// loadAudio returns the audio from the store, generating and storing it on a
+// miss, and the key it is stored under. That is the key of the params unless
+// the audio is made by the fallback model.
+func (h *handler) loadAudio(params audio.Params) ([]byte, string, error) {
+	key := params.Key()
+	cached, err := h.audioStore.Get(key)
+	if err == nil {
+		defer cached.Close()
+		data, err := io.ReadAll(cached)
+		if err == nil {
+			return data, key, nil
+		}
+		log.Printf("Failed to read cached audio: %v", err)
+	} else if !errors.Is(err, audio.ErrNotFound) {
+		log.Printf("Failed to get cached audio: %v", err)
+	}
+
+	generated, used, err := h.openaiClient.GenerateAudio(params)
+	if err != nil {
+		return nil, "", err
+	}
+	defer generated.Close()
+
+	data, err := io.ReadAll(generated)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read audio data: %w", err)
+	}
+
+	key = used.Key()
+	if err := h.audioStore.Put(key, bytes.NewReader(data)); err != nil {
+		log.Printf("Failed to cache audio: %v", err)
+	}
+
+	return data, key, nil
+}
+
+func (h *handler) HandleWordAudio(c echo.Context) error {
+	wordID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid word id")
+	}
+
+	word, err := h.db.GetWordByID(wordID)
+	if err != nil && errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "word not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get word").SetInternal(err)
+	}
+
+	if word.AudioURL == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "word audio not found")
+	}
+
+	r, err := h.audioStore.Get(word.AudioURL)
+	if err != nil && errors.Is(err, audio.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "word audio not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get word audio").SetInternal(err)
+	}
+	defer r.Close()
+
+	return c.Stream(http.StatusOK, "audio/ogg", r)
+}
+
+// maxVoiceSize limits voice answers to about a minute of speech.
+const maxVoiceSize = 1 << 20
+
+// transcribeVoice downloads a voice message through the bot API and returns its transcript.
+func (h *handler) transcribeVoice(ctx context.Context, voice *tgbotapi.Voice) (string, error) {
+	if voice.FileSize > maxVoiceSize {
+		return "", fmt.Errorf("voice message is too large: %d bytes", voice.FileSize)
+	}
+
+	file, err := h.bot.GetFile(ctx, &telegram.GetFileParams{FileID: voice.FileID})
+	if err != nil {
+		return "", fmt.Errorf("failed to get voice file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.bot.FileDownloadLink(file), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create voice download request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download voice file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download voice file: status %d", resp.StatusCode)
+	}
+
+	transcript, err := h.openaiClient.TranscribeAudio(io.LimitReader(resp.Body, maxVoiceSize), "voice.ogg")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(transcript), nil
+}
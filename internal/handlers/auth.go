@@ -148,3 +148,31 @@ func generateJWT(userID int64, chatID int64, secretKey string) (string, error) {
 
 	return t, nil
 }
+
+func getClaims(c echo.Context) *JWTClaims {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return nil
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok {
+		return nil
+	}
+
+	return claims
+}
+
+func getUserID(c echo.Context) int64 {
+	if claims := getClaims(c); claims != nil {
+		return claims.UID
+	}
+	return 0
+}
+
+func getChatID(c echo.Context) int64 {
+	if claims := getClaims(c); claims != nil {
+		return claims.ChatID
+	}
+	return 0
+}
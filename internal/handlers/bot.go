@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,12 +8,19 @@ import (
 	telegram "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/labstack/echo/v4"
+	"html"
 	"io"
 	"jpbot/internal/ai"
+	"jpbot/internal/audio"
 	"jpbot/internal/db"
+	"jpbot/internal/furigana"
+	"jpbot/internal/kana"
 	"log"
 	"math/rand"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Storager interface {
@@ -23,6 +29,7 @@ type Storager interface {
 	GetExercisesByLevel(level string) ([]db.Exercise, error)
 	GetNextExerciseForUser(userID int64, level string, exTypes []string) (db.Exercise, error)
 	MarkExerciseSent(userID, exerciseID int64) error
+	MarkReadingAnswered(userID int64, question, correct int) error
 	SaveUser(user *db.User) error
 	UpdateUser(user *db.User) error
 	GetExerciseByID(exerciseID int64) (db.Exercise, error)
@@ -30,47 +37,92 @@ type Storager interface {
 	ClearUserExercise(userID int64) error
 	UpdateUserLevel(userID int64, level string) error
 	CountUsers() (int, error)
-	GetNextWordForUser(userID int64, level string) (db.Word, error)
+	GetNextCardForUser(userID int64, level string, directions []string) (db.Card, error)
 	GetWordByID(wordID int64) (db.Word, error)
+	GetSpellingReadings(kanji string) ([]string, error)
 	SaveWordReview(submission db.TranslationSubmission) error
-	MarkWordSent(userID, wordID int64) error
+	RegradeWordReview(userID, wordID int64, direction string, grade db.Grade) error
+	MarkWordSent(userID, wordID int64, direction string) error
+	MarkWordReviewed(userID int64) error
+	GetWordReview(userID, wordID int64, direction string) (db.WordReview, error)
+	GetLeeches(userID int64) ([]db.Leech, error)
+	UnsuspendWord(userID, wordID int64, direction string) error
+	UpdateUserCardDirections(userID int64, directions []string) error
+	UpdateUserShowRomaji(telegramID int64, show bool) error
+	UpdateUserFuriganaMode(telegramID int64, mode string) error
+	GetAudioFileID(key string) (string, error)
+	SaveAudioFileID(key, fileID string) error
+	GetKnownKanji(userID int64) (map[rune]bool, error)
+	SaveWordMnemonic(userID, wordID int64, mnemonic string) error
 	ClearUserWord(userID int64) error
+	GetRandomConjugationWord(level string) (db.Word, error)
+	MarkConjugationSent(userID, wordID int64, form string) error
+	IsKanjiInitialized() (bool, error)
+	GetKanji(character string) (db.Kanji, error)
+	GetWordsWithKanji(character string, limit int) ([]db.Word, error)
+	GetKnownKanjiWords(userID int64, limit int) ([]db.Word, error)
+	MarkKanjiSent(userID, wordID int64, character, mode string) error
+	GetRandomExercises(level, exType string, limit int) ([]db.Exercise, error)
+	CreateMockAttempt(attempt *db.MockAttempt) error
+	UpdateMockAttempt(attempt db.MockAttempt) error
+	GetMockAttempt(userID, attemptID int64) (db.MockAttempt, error)
+	GetActiveMockAttempt(userID int64) (db.MockAttempt, error)
+	GetMockAttempts(userID int64, limit int) ([]db.MockAttempt, error)
 	UpdateUserRanking(userID int64, score int) error
 	GetLeaderboard(periodType db.PeriodType, limit int) ([]db.LeaderboardEntry, error)
 	GetUsersPaginated(limit, offset int) ([]db.User, error)
+	GetReviewLog(userID, wordID int64, limit int) ([]db.ReviewLogEntry, error)
+	GetDailyProgress(userID int64, level string) (db.DailyProgress, error)
+	UpdateUserLimits(userID int64, newWordsPerDay, reviewsPerDay int) error
 }
 
 type OpenAIClient interface {
 	CheckExercise(s db.Submission) (ai.ExerciseFeedback, error)
-	GenerateAudio(text string) (io.ReadCloser, error)
+	SpeechParams(text string) audio.Params
+	GenerateAudio(params audio.Params) (io.ReadCloser, audio.Params, error)
 	CheckWordTranslation(word, translation, userInput string) (ai.WordTranslationEvaluation, error)
+	CheckWordRecognition(word, reading, translation, userInput string) (ai.WordTranslationEvaluation, error)
+	CheckWordReading(word, reading, userInput string) (ai.WordTranslationEvaluation, error)
 	ExplainSentence(sentence string) (string, error)
+	ExplainCloze(sentence, answer, userInput string) (string, error)
+	GenerateMnemonic(word, reading, translation string) (string, error)
+	TranscribeAudio(r io.Reader, filename string) (string, error)
 }
 
 type handler struct {
 	bot          *telegram.Bot
 	db           Storager
 	openaiClient OpenAIClient
+	audioStore   audio.Store
 	jwtSecret    string
 	botToken     string
+	dispatcher   *Dispatcher
 }
 
 func NewHandler(
 	bot *telegram.Bot,
 	db Storager,
 	openaiClient *ai.Client,
+	audioStore audio.Store,
 	jwtSecret string,
 	botToken string,
+	dispatch DispatcherConfig,
 ) *handler {
-	return &handler{
+	h := &handler{
 		bot:          bot,
 		db:           db,
 		openaiClient: openaiClient,
+		audioStore:   audioStore,
 		jwtSecret:    jwtSecret,
 		botToken:     botToken,
 	}
+	h.dispatcher = NewDispatcher(dispatch, h.processUpdate)
+	return h
 }
 
+// HandleWebhook acknowledges an update at once and leaves it to the
+// dispatcher, so that Telegram doesn't deliver it again while the answer is
+// being graded. A full queue is reported for Telegram to retry later.
 func (h *handler) HandleWebhook(c echo.Context) error {
 	var update tgbotapi.Update
 	if err := c.Bind(&update); err != nil {
@@ -82,12 +134,29 @@ func (h *handler) HandleWebhook(c echo.Context) error {
 		return c.NoContent(200)
 	}
 
+	if err := h.dispatcher.Dispatch(update); err != nil {
+		log.Printf("Failed to dispatch update: %v", err)
+		return c.NoContent(http.StatusServiceUnavailable)
+	}
+
+	return c.NoContent(200)
+}
+
+// Shutdown waits for the updates already accepted to be answered.
+func (h *handler) Shutdown(ctx context.Context) error {
+	return h.dispatcher.Shutdown(ctx)
+}
+
+// processUpdate answers an update taken from the dispatcher queue.
+func (h *handler) processUpdate(update tgbotapi.Update) {
 	resp := h.handleUpdate(update)
+	// callbacks that edit the message in place have nothing to send
+	if resp.Text == "" {
+		return
+	}
 	if _, err := h.bot.SendMessage(context.Background(), resp); err != nil {
 		log.Printf("Failed to send message: %v", err)
 	}
-
-	return c.NoContent(200)
 }
 
 func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessageParams) {
@@ -196,6 +265,46 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 
 			return
 		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "grade:") {
+			h.handleGradeCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "unsuspend:") {
+			h.handleUnsuspendCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "cards:") {
+			h.handleCardsCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "furigana:") {
+			h.handleFuriganaCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "mc:") {
+			h.handleMultipleChoiceCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "ro:") {
+			h.handleReorderCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "rd:") {
+			h.handleReadingCallback(user, update.CallbackQuery, msg)
+			return
+		}
+
+		if strings.HasPrefix(update.CallbackQuery.Data, "mock:") {
+			h.handleMockCallback(user, update.CallbackQuery, msg)
+			return
+		}
 	}
 
 	switch update.Message.Command() {
@@ -210,19 +319,31 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 	case "start":
 		msg.Text = "Привет\\! Этот бот для изучения японского языка\\. Он поможет тебе практиковать перевод предложений, слов и грамматику\\!\n\n" +
 			"*Как использовать:*\n" +
-			"\\- /task — получить задание \\(перевод, вопрос, грамматика или аудио\\)\\.\n" +
+			"\\- /task — получить задание \\(перевод, вопрос, грамматика, аудио, текст для чтения или тест с вариантами ответа\\)\\.\n" +
 			"\\- /vocab — учить новые слова\\.\n" +
+			"\\- /conjugate — тренировать спряжение глаголов и прилагательных\\.\n" +
+			"\\- /kanji — чтение и написание кандзи, /kanji 日 — слова с этим кандзи\\.\n" +
+			"\\- /mock — пробный тест JLPT на время, /mock history — прошлые результаты\\.\n" +
 			"\\- /level — выбрать уровень сложности \\(N5, N4, N3\\)\\.\n" +
+			"\\- /cards — выбрать типы карточек: перевод, узнавание, чтение\\.\n" +
+			"\\- /limits — дневные лимиты новых слов и повторений\\.\n" +
+			"\\- /romaji — показывать ромадзи в /answer\\.\n" +
+			"\\- /furigana — когда показывать чтение кандзи в примерах\\.\n" +
+			"\\- /leeches — слова, которые никак не запоминаются\\.\n" +
 			"🤖 Ответ проверит AI, который даст обратную связь и советы\\. Начинай с /task или /vocab\\!\n\n" +
 			"Подписывайся на канал @jpbot\\_learn\\_japanese\\. Там будет информация об обновлениях и обсуждение фич\\."
 		msg.ParseMode = models.ParseModeMarkdown
 	case "task":
 		if user.CurrentExerciseID != nil {
+			// a reading passage goes on from the question the user is at
+			if h.continueReading(user, msg) {
+				break
+			}
 			msg.Text = "У тебя уже есть задание. Попробуй решить его!"
 			break
 		}
 
-		types := []string{db.ExerciseTypeQuestion, db.ExerciseTypeTranslation, db.ExerciseTypeGrammar, db.ExerciseTypeAudio}
+		types := []string{db.ExerciseTypeQuestion, db.ExerciseTypeTranslation, db.ExerciseTypeGrammar, db.ExerciseTypeAudio, db.ExerciseTypeShadowing, db.ExerciseTypeMultipleChoice, db.ExerciseTypeCloze, db.ExerciseTypeReorder, db.ExerciseTypeReading}
 
 		exercise, err := h.db.GetNextExerciseForUser(chatID, user.Level, types)
 		if err != nil && errors.Is(err, db.ErrNotFound) {
@@ -251,55 +372,65 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 				c, _ := db.ContentAs[db.AudioContent](exercise.Content)
 				msg.Text = fmt.Sprintf("Задание:\n\nПрослушай аудио и ответь на вопрос: %s\n\nИспользуй /explain для подсказки", c.Question)
 
-				audioReader, err := h.openaiClient.GenerateAudio(c.Text)
-				if err != nil {
-					log.Printf("Failed to generate audio: %v", err)
+				if err := h.sendVoice(context.Background(), chatID, h.openaiClient.SpeechParams(c.Text)); err != nil {
+					log.Printf("Failed to send audio: %v", err)
 					msg.Text = "Ошибка при генерации аудио. Попробуй позже."
 					return
 				}
-				defer audioReader.Close()
+			case db.ExerciseTypeShadowing:
+				c, _ := db.ContentAs[db.ShadowingContent](exercise.Content)
+				msg.Text = fmt.Sprintf("Задание:\n\nПослушай и повтори голосовым сообщением как можно ближе к диктору:\n\n%s\n\nИспользуй /explain для подсказки", c.Text)
 
-				// Считываем всё содержимое в память (аналогично os.ReadFile в примере)
-				audioData, err := io.ReadAll(audioReader)
-				if err != nil {
-					log.Printf("Failed to read audio data: %v", err)
-					msg.Text = "Ошибка при обработке аудио. Попробуй позже."
+				if err := h.sendVoice(context.Background(), chatID, h.openaiClient.SpeechParams(c.Text)); err != nil {
+					log.Printf("Failed to send audio: %v", err)
+					msg.Text = "Ошибка при генерации аудио. Попробуй позже."
 					return
 				}
-
-				params := &telegram.SendVoiceParams{
-					ChatID: chatID,
-					Voice: &models.InputFileUpload{
-						Filename: "voice.ogg", // Telegram требует имя
-						Data:     bytes.NewReader(audioData),
-					},
-				}
-
-				if _, err := h.bot.SendVoice(context.Background(), params); err != nil {
-					log.Printf("Failed to send audio: %v", err)
-					msg.Text = "Ошибка при отправке аудио. Попробуй позже."
+			case db.ExerciseTypeMultipleChoice:
+				c, _ := db.ContentAs[db.MultipleChoiceContent](exercise.Content)
+				msg.Text = fmt.Sprintf("Задание:\n\n%s", c.Stem)
+				msg.ReplyMarkup = multipleChoiceKeyboard(exercise.ID, c)
+			case db.ExerciseTypeCloze:
+				c, _ := db.ContentAs[db.ClozeContent](exercise.Content)
+				msg.Text = clozePrompt(c, h.furiganaFilter(user.ID, user.FuriganaMode))
+				msg.ParseMode = models.ParseModeHTML
+			case db.ExerciseTypeReorder:
+				c, _ := db.ContentAs[db.ReorderContent](exercise.Content)
+				msg.Text = reorderText(c, nil)
+				msg.ReplyMarkup = reorderKeyboard(exercise.ID, c, "")
+			case db.ExerciseTypeReading:
+				c, err := db.ContentAs[db.ReadingContent](exercise.Content)
+				if err != nil || len(c.Questions) == 0 {
+					log.Printf("Invalid reading exercise %d: %v", exercise.ID, err)
+					msg.Text = "Ошибка при получении задания. Попробуй позже."
 					return
 				}
+				msg.Text = readingPrompt(c, 0)
+				msg.ReplyMarkup = readingKeyboard(exercise.ID, 0, c.Questions[0])
 			}
 			if err := h.db.MarkExerciseSent(user.ID, exercise.ID); err != nil {
 				log.Printf("Failed to mark exercise as sent: %v", err)
 			}
 		}
 	case "vocab":
-		if user.CurrentWordID != nil {
+		if user.CurrentWordID != nil && user.CurrentMode == db.ModeVocab {
 			msg.Text = "У тебя уже есть задание. Попробуй решить его!"
 			break
 		}
-		word, err := h.db.GetNextWordForUser(user.ID, user.Level)
+		card, err := h.db.GetNextCardForUser(user.ID, user.Level, user.Directions())
 		if err != nil && errors.Is(err, db.ErrNotFound) {
-			msg.Text = "Слова для твоего уровня закончились. Попробуй зайти завтра!"
+			msg.Text = h.noWordsText(user)
 		} else if err != nil {
 			msg.Text = "Ошибка при получении слова. Попробуй позже."
-			log.Printf("Failed to get next word: %v", err)
+			log.Printf("Failed to get next card: %v", err)
 		} else {
-			msg.Text = fmt.Sprintf("Переведи слово: *%s*", telegram.EscapeMarkdown(word.Translation))
+			msg.Text = fmt.Sprintf("%s%s", cardPrompt(card), h.leechHint(user, card))
 			msg.ParseMode = models.ParseModeMarkdown
-			if err := h.db.MarkWordSent(chatID, word.ID); err != nil {
+			// the pronunciation would give away the answer of the other directions
+			if card.Direction == db.CardDirectionRecognition {
+				h.sendWordVoice(context.Background(), chatID, card.Word)
+			}
+			if err := h.db.MarkWordSent(chatID, card.Word.ID, card.Direction); err != nil {
 				log.Printf("Failed to mark word as sent: %v", err)
 			}
 		}
@@ -322,8 +453,14 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 		} else if exercise.Type == db.ExerciseTypeAudio {
 			c, _ := db.ContentAs[db.AudioContent](exercise.Content)
 			sentence = c.Text
+		} else if exercise.Type == db.ExerciseTypeShadowing {
+			c, _ := db.ContentAs[db.ShadowingContent](exercise.Content)
+			sentence = c.Text
+		} else if exercise.Type == db.ExerciseTypeReading {
+			c, _ := db.ContentAs[db.ReadingContent](exercise.Content)
+			sentence = c.Passage
 		} else {
-			msg.Text = "Подсказка доступна только для вопросов и аудио."
+			msg.Text = "Подсказка доступна только для вопросов, аудио, шэдоуинга и текстов для чтения."
 			break
 		}
 
@@ -349,6 +486,15 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 			break
 		}
 
+		if user.CurrentMode == db.ModeConjugate {
+			h.handleConjugationReveal(user, msg)
+			break
+		}
+		if user.CurrentMode == db.ModeKanjiReading || user.CurrentMode == db.ModeKanjiWriting {
+			h.handleKanjiReveal(user, msg)
+			break
+		}
+
 		word, err := h.db.GetWordByID(*user.CurrentWordID)
 		if err != nil {
 			msg.Text = "Ошибка при получении слова."
@@ -358,28 +504,64 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 
 		var exampleText string
 		if len(word.Examples) > 0 {
-			var parts []string
-			for _, s := range word.Examples[0].Sentence {
-				if s.Furigana != nil {
-					parts = append(parts, fmt.Sprintf("%s(%s)", s.Fragment, *s.Furigana))
-				} else {
-					parts = append(parts, s.Fragment)
-				}
+			example := word.Examples[0]
+			jap := furigana.Render(example.Sentence, furigana.FormatTelegram, h.furiganaFilter(user.ID, user.FuriganaMode))
+			if user.ShowRomaji {
+				jap = fmt.Sprintf("%s\n%s", jap, html.EscapeString(exampleRomaji(example)))
 			}
-			jap := strings.Join(parts, "")
-			exampleText = fmt.Sprintf("\n\nПример: %s\n%s\n\nПопробуй снова:", jap, word.Examples[0].Translation)
+			exampleText = fmt.Sprintf("\n\nПример: %s\n%s\n\nПопробуй снова:", jap, html.EscapeString(example.Translation))
 		}
 
-		msg.Text = fmt.Sprintf("%s%s", word.GetKanji(), exampleText)
-		submission := db.TranslationSubmission{
-			UserID:    user.ID,
-			WordID:    word.ID,
-			IsCorrect: false,
+		h.sendWordVoice(context.Background(), chatID, word)
+
+		card := db.Card{Word: word, Direction: user.CurrentCardDirection}
+		answer := cardAnswer(card)
+		if user.ShowRomaji {
+			answer = fmt.Sprintf("%s [%s]", answer, kana.ToRomaji(word.Kana))
 		}
+		msg.Text = fmt.Sprintf("%s%s", html.EscapeString(answer), exampleText)
+		msg.ParseMode = models.ParseModeHTML
+		msg.ReplyMarkup = gradeKeyboard(card)
+
+		if !user.CurrentWordReviewed {
+			submission := db.TranslationSubmission{
+				UserID:    user.ID,
+				WordID:    word.ID,
+				Direction: card.Direction,
+				Grade:     db.GradeRecall(0, 0, true),
+			}
 
-		if err := h.db.SaveWordReview(submission); err != nil {
-			log.Printf("Failed to save word review: %v", err)
+			if err := h.db.SaveWordReview(submission); err != nil {
+				log.Printf("Failed to save word review: %v", err)
+			} else if err := h.db.MarkWordReviewed(chatID); err != nil {
+				log.Printf("Failed to mark word reviewed: %v", err)
+			} else if notice := h.suspendedLeechNotice(user, card); notice != "" {
+				msg.Text = fmt.Sprintf("%s\n\n%s", msg.Text, html.EscapeString(notice))
+			}
+		}
+	case "conjugate":
+		h.handleConjugateCommand(user, msg)
+	case "kanji":
+		h.handleKanjiCommand(user, update.Message.CommandArguments(), msg)
+	case "mock":
+		h.handleMockCommand(user, update.Message.CommandArguments(), msg)
+	case "cards":
+		h.handleCardsCommand(user, msg)
+	case "furigana":
+		h.handleFuriganaCommand(user, msg)
+	case "romaji":
+		if err := h.db.UpdateUserShowRomaji(chatID, !user.ShowRomaji); err != nil {
+			log.Printf("Failed to update romaji setting: %v", err)
+			msg.Text = "Ошибка при сохранении настроек. Попробуй позже."
+		} else if user.ShowRomaji {
+			msg.Text = "Ромадзи в /answer выключены."
+		} else {
+			msg.Text = "Ромадзи в /answer включены."
 		}
+	case "leeches":
+		h.handleLeechesCommand(user, msg)
+	case "limits":
+		msg.Text = h.handleLimitsCommand(user, update.Message.CommandArguments())
 	case "reset":
 		if err := h.db.ClearUserExercise(chatID); err != nil {
 			log.Printf("Failed to clear user exercise: %v", err)
@@ -400,7 +582,6 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 		msg.ReplyMarkup = &keyboard
 	default:
 		if user.CurrentExerciseID != nil && user.CurrentMode == db.ModeExercise {
-			userInput := update.Message.Text
 			exercise, err := h.db.GetExerciseByID(*user.CurrentExerciseID)
 			if err != nil {
 				msg.Text = "Ошибка при проверке задания."
@@ -408,6 +589,50 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 				break
 			}
 
+			if exercise.Type == db.ExerciseTypeShadowing && update.Message.Voice == nil {
+				msg.Text = "Чтобы выполнить это задание, запиши голосовое сообщение."
+				break
+			}
+
+			if exercise.Type == db.ExerciseTypeMultipleChoice || exercise.Type == db.ExerciseTypeReading {
+				msg.Text = "Выбери вариант ответа кнопкой под заданием."
+				break
+			}
+
+			if exercise.Type == db.ExerciseTypeReorder {
+				msg.Text = "Собери предложение кнопками под заданием."
+				break
+			}
+
+			userInput := update.Message.Text
+			// inputNote shows the answer as it was checked when it differs from what the user sent
+			var inputNote string
+			if update.Message.Voice != nil {
+				transcript, err := h.transcribeVoice(context.Background(), update.Message.Voice)
+				if err != nil {
+					log.Printf("Failed to transcribe voice: %v", err)
+					msg.Text = "Не удалось распознать голосовое сообщение. Попробуй еще раз или ответь текстом."
+					break
+				}
+				if transcript == "" {
+					msg.Text = "В голосовом сообщении ничего не слышно. Попробуй еще раз."
+					break
+				}
+				userInput = transcript
+				inputNote = fmt.Sprintf("🎙 Распознано: %s\n\n", telegram.EscapeMarkdown(transcript))
+			} else if kana.IsRomaji(userInput) {
+				// answers typed without a Japanese keyboard are checked as kana
+				if converted, ok := kana.RomajiTextToHiragana(userInput); ok {
+					userInput = converted
+					inputNote = fmt.Sprintf("Твой ответ каной: %s\n\n", telegram.EscapeMarkdown(converted))
+				}
+			}
+
+			if exercise.Type == db.ExerciseTypeCloze {
+				h.checkClozeAnswer(user, exercise, userInput, inputNote, msg)
+				break
+			}
+
 			submission := db.Submission{
 				UserID:     chatID,
 				ExerciseID: exercise.ID,
@@ -431,7 +656,7 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 			submission.IsCorrect = feedback.Score >= 80
 
 			if submission.IsCorrect {
-				msg.Text = fmt.Sprintf("Правильно\\! 🎉\n\nЧтобы получить новое задание, используй /task\\.")
+				msg.Text = fmt.Sprintf("%sПравильно\\! 🎉\n\nЧтобы получить новое задание, используй /task\\.", inputNote)
 				msg.ParseMode = models.ParseModeMarkdown
 				user.CurrentExerciseID = nil
 				if err := h.db.ClearUserExercise(chatID); err != nil {
@@ -442,7 +667,8 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 					log.Printf("Failed to update user ranking: %v", err)
 				}
 			} else {
-				msg.Text = fmt.Sprintf("Неправильно\\.\n\n%s\n%s\n\nПопробуй еще раз:",
+				msg.Text = fmt.Sprintf("%sНеправильно\\.\n\n%s\n%s\n\nПопробуй еще раз:",
+					inputNote,
 					telegram.EscapeMarkdown(feedback.Comment),
 					telegram.EscapeMarkdown(feedback.Suggestion))
 				msg.ParseMode = models.ParseModeMarkdown
@@ -453,6 +679,11 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 				msg.Text = "Ошибка при сохранении ответа."
 			}
 		} else if user.CurrentWordID != nil && user.CurrentMode == db.ModeVocab {
+			if update.Message.Voice != nil {
+				msg.Text = "Голосом можно отвечать только на задания из /task. Напиши слово текстом."
+				break
+			}
+
 			userInput := update.Message.Text
 			word, err := h.db.GetWordByID(*user.CurrentWordID)
 			if err != nil {
@@ -461,48 +692,95 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 				break
 			}
 
-			res, err := h.openaiClient.CheckWordTranslation(word.GetKanji(), word.Translation, userInput)
+			card := db.Card{Word: word, Direction: user.CurrentCardDirection}
+			res, err := h.checkCard(card, userInput)
 			if err != nil {
 				msg.Text = "Ошибка при проверке ответа."
 				log.Printf("Failed to check word translation: %v", err)
 				break
 			}
 
-			isCorrect := res.Score >= 80
+			var latency time.Duration
+			if user.CurrentWordSentAt != nil {
+				latency = time.Since(*user.CurrentWordSentAt)
+			}
 
 			submission := db.TranslationSubmission{
 				UserID:      user.ID,
 				WordID:      word.ID,
+				Direction:   card.Direction,
 				Translation: word.Translation,
-				IsCorrect:   isCorrect,
+				UserInput:   userInput,
+				Score:       res.Score,
+				Latency:     latency,
+				Grade:       db.GradeRecall(res.Score, latency, false),
 			}
 
-			if err := h.db.SaveWordReview(submission); err != nil {
-				log.Printf("Failed to save word review: %v", err)
+			// Only the first attempt at a word is scheduled, retries after a
+			// miss or after /answer just move on to the next word.
+			var leechNotice string
+			if !user.CurrentWordReviewed {
+				if err := h.db.SaveWordReview(submission); err != nil {
+					log.Printf("Failed to save word review: %v", err)
+				} else if !submission.IsCorrect() {
+					if err := h.db.MarkWordReviewed(chatID); err != nil {
+						log.Printf("Failed to mark word reviewed: %v", err)
+					}
+					leechNotice = h.suspendedLeechNotice(user, card)
+				}
 			}
 
-			if isCorrect {
-				nextWord, err := h.db.GetNextWordForUser(user.ID, user.Level)
-				if err != nil && errors.Is(err, db.ErrNotFound) {
-					log.Printf("No more words for user: %v", err)
-					msg.Text = "Слова для твоего уровня закончились\\. Попробуй зайти завтра\\!"
-				} else if err != nil {
-					log.Printf("Failed to get next word: %v", err)
-				}
+			if submission.IsCorrect() {
+				praise := fmt.Sprintf("Правильно\\! 🎉 %s: *%s*",
+					telegram.EscapeMarkdown(cardAnswer(card)),
+					telegram.EscapeMarkdown(gradeLabels[submission.Grade]))
 
-				msg.Text = fmt.Sprintf("Правильно\\! 🎉\n\nСледующее слово: *%s*\n\nЕсли не знаешь, используй /answer", telegram.EscapeMarkdown(nextWord.Translation))
+				nextCard, err := h.db.GetNextCardForUser(user.ID, user.Level, user.Directions())
+				if err != nil {
+					if errors.Is(err, db.ErrNotFound) {
+						msg.Text = fmt.Sprintf("%s\n\n%s", praise, telegram.EscapeMarkdown(h.noWordsText(user)))
+					} else {
+						log.Printf("Failed to get next word: %v", err)
+						msg.Text = fmt.Sprintf("%s\n\nОшибка при получении следующего слова\\. Попробуй /vocab позже\\.", praise)
+					}
+					if err := h.db.ClearUserWord(chatID); err != nil {
+						log.Printf("Failed to clear user word: %v", err)
+					}
+				} else {
+					msg.Text = fmt.Sprintf("%s\n\n%s%s\n\nЕсли не знаешь, используй /answer",
+						praise,
+						cardPrompt(nextCard),
+						h.leechHint(user, nextCard))
+					if err := h.db.MarkWordSent(chatID, nextCard.Word.ID, nextCard.Direction); err != nil {
+						log.Printf("Failed to mark word as sent: %v", err)
+					}
+				}
 
-				msg.ParseMode = models.ParseModeMarkdown
-				if err := h.db.MarkWordSent(chatID, nextWord.ID); err != nil {
-					log.Printf("Failed to mark word as sent: %v", err)
+				if !user.CurrentWordReviewed {
+					msg.ReplyMarkup = gradeKeyboard(card)
 				}
+				msg.ParseMode = models.ParseModeMarkdown
 				// Update rankings
 				if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
 					log.Printf("Failed to update user ranking: %v", err)
 				}
+			} else if leechNotice != "" {
+				msg.Text = fmt.Sprintf("%s\n\n%s", res.Comment, leechNotice)
 			} else {
 				msg.Text = fmt.Sprintf("%s\n\nПопробуй еще раз:", res.Comment)
 			}
+		} else if user.CurrentWordID != nil && user.CurrentMode == db.ModeConjugate {
+			if update.Message.Voice != nil {
+				msg.Text = "Голосом можно отвечать только на задания из /task. Напиши форму текстом."
+				break
+			}
+			h.handleConjugationAnswer(user, update.Message.Text, msg)
+		} else if user.CurrentWordID != nil && (user.CurrentMode == db.ModeKanjiReading || user.CurrentMode == db.ModeKanjiWriting) {
+			if update.Message.Voice != nil {
+				msg.Text = "Голосом можно отвечать только на задания из /task. Напиши ответ текстом."
+				break
+			}
+			h.handleKanjiAnswer(user, update.Message.Text, msg)
 		} else {
 			msg.Text = "Чтобы получить задание, используй /task или /vocab.\n\n" +
 				"Если хочешь сменить уровень, используй /level.\n\n"
@@ -512,3 +790,53 @@ func (h *handler) handleUpdate(update tgbotapi.Update) (msg *telegram.SendMessag
 
 	return msg
 }
+
+var gradeLabels = map[db.Grade]string{
+	db.GradeAgain: "Не вспомнил",
+	db.GradeHard:  "Трудно",
+	db.GradeGood:  "Хорошо",
+	db.GradeEasy:  "Легко",
+}
+
+func gradeKeyboard(card db.Card) *tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, g := range []db.Grade{db.GradeAgain, db.GradeHard, db.GradeGood, db.GradeEasy} {
+		data := fmt.Sprintf("grade:%d:%s:%d", card.Word.ID, card.Direction, g)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(gradeLabels[g], data))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &keyboard
+}
+
+// handleGradeCallback overrides the grade of the latest review of a card
+// with the one the user picked on the inline keyboard.
+func (h *handler) handleGradeCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	parts := strings.Split(strings.TrimPrefix(query.Data, "grade:"), ":")
+	var wordID int64
+	var grade int
+	var err error
+	if len(parts) == 3 {
+		wordID, err = strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			grade, err = strconv.Atoi(parts[2])
+		}
+	}
+
+	if len(parts) != 3 || err != nil || !db.Grade(grade).IsValid() || !db.IsValidCardDirection(parts[1]) {
+		msg.Text = "Недопустимая оценка."
+	} else if err := h.db.RegradeWordReview(user.ID, wordID, parts[1], db.Grade(grade)); err != nil {
+		log.Printf("Failed to regrade word review: %v", err)
+		msg.Text = "Ошибка при сохранении оценки. Попробуй позже."
+	} else {
+		msg.Text = fmt.Sprintf("Оценка сохранена: %s", gradeLabels[db.Grade(grade)])
+	}
+
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            msg.Text,
+	}
+
+	if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	telegram "github.com/go-telegram/bot"
+	"github.com/labstack/echo/v4"
+	"jpbot/internal/ai"
+	"jpbot/internal/ai/aitest"
+	"jpbot/internal/audio"
+	"jpbot/internal/db"
+	"jpbot/internal/telegramtest"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testChatID = 1001
+
+var testUser = telegramtest.User{ID: testChatID, FirstName: "Test", UserName: "test"}
+
+type testEnv struct {
+	h        *handler
+	ai       *aitest.Server
+	telegram *telegramtest.Server
+}
+
+// newTestEnv makes a handler with a fresh database holding the exercises and
+// words, talking to the fake AI and the Telegram stand-in, which delivers
+// updates to the handler's webhook.
+func newTestEnv(t *testing.T, exercises []db.Exercise, words []db.Word) *testEnv {
+	t.Helper()
+
+	storage, err := db.ConnectDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	if len(exercises) > 0 {
+		if err := storage.SaveTasksBatch(exercises); err != nil {
+			t.Fatalf("failed to save exercises: %v", err)
+		}
+	}
+	if len(words) > 0 {
+		if err := storage.SaveWordsBatch(words); err != nil {
+			t.Fatalf("failed to save words: %v", err)
+		}
+	}
+
+	fake := aitest.NewServer()
+	t.Cleanup(fake.Close)
+	openaiClient, err := ai.NewClient(fake.Config())
+	if err != nil {
+		t.Fatalf("failed to create AI client: %v", err)
+	}
+
+	tg := telegramtest.NewServer()
+	t.Cleanup(tg.Close)
+	bot, err := tg.Bot()
+	if err != nil {
+		t.Fatalf("failed to create bot: %v", err)
+	}
+
+	audioStore, err := audio.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create audio store: %v", err)
+	}
+
+	h := NewHandler(bot, storage, openaiClient, audioStore, "secret", telegramtest.Token, DispatcherConfig{})
+	t.Cleanup(func() {
+		if err := h.Shutdown(context.Background()); err != nil {
+			t.Errorf("failed to shut down: %v", err)
+		}
+	})
+
+	e := echo.New()
+	e.POST("/webhook", h.HandleWebhook)
+	web := httptest.NewServer(e)
+	t.Cleanup(web.Close)
+	if _, err := bot.SetWebhook(context.Background(), &telegram.SetWebhookParams{URL: web.URL + "/webhook"}); err != nil {
+		t.Fatalf("failed to set webhook: %v", err)
+	}
+
+	return &testEnv{
+		h:        h,
+		ai:       fake,
+		telegram: tg,
+	}
+}
+
+// send passes a message from the test user to handleUpdate and returns the
+// text of the reply.
+func (e *testEnv) send(text string) string {
+	return e.h.handleUpdate(e.telegram.Message(testUser, text)).Text
+}
+
+func (e *testEnv) user(t *testing.T) *db.User {
+	t.Helper()
+	user, err := e.h.db.GetUser(testChatID)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	return user
+}
+
+// production registers the user with only the Russian to Japanese cards.
+func (e *testEnv) production(t *testing.T) {
+	t.Helper()
+	e.send("/start")
+	if err := e.h.db.UpdateUserCardDirections(testChatID, []string{db.CardDirectionProduction}); err != nil {
+		t.Fatalf("failed to set card directions: %v", err)
+	}
+}
+
+func exercise(t *testing.T, exType string, content interface{}) db.Exercise {
+	t.Helper()
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.Exercise{Level: db.LevelN5, Type: exType, Content: data}
+}
+
+func expectContains(t *testing.T, reply, want string) {
+	t.Helper()
+	if !strings.Contains(reply, want) {
+		t.Errorf("reply %q doesn't contain %q", reply, want)
+	}
+}
+
+func TestTaskTranslation(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."}),
+	}, nil)
+
+	expectContains(t, env.send("/task"), "Переведи: Я ем рыбу.")
+	expectContains(t, env.send("/task"), "У тебя уже есть задание")
+
+	env.ai.On(aitest.Rule{Schema: "feedback", Reply: `{"score": 40, "feedback": "Не то время", "suggestion": "魚を食べます。"}`})
+	reply := env.send("魚を食べた")
+	expectContains(t, reply, "Неправильно")
+	expectContains(t, reply, "Не то время")
+	if env.user(t).CurrentExerciseID == nil {
+		t.Fatal("the exercise is cleared after a wrong answer")
+	}
+
+	env.ai.Enqueue(`{"score": 95, "feedback": "Отлично", "suggestion": ""}`)
+	expectContains(t, env.send("魚を食べます"), "Правильно")
+	if env.user(t).CurrentExerciseID != nil {
+		t.Error("the exercise is not cleared after a right answer")
+	}
+
+	requests := env.ai.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests to the AI, want 2", len(requests))
+	}
+	expectContains(t, requests[1].Last(), "Я ем рыбу.")
+	expectContains(t, requests[1].Last(), "魚を食べます")
+}
+
+func TestTaskAudio(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeAudio, db.AudioContent{Text: "毎朝コーヒーを飲みます。", Question: "何を飲みますか？"}),
+	}, nil)
+
+	expectContains(t, env.send("/task"), "何を飲みますか？")
+	if speeches := env.ai.Speeches(); len(speeches) != 1 || speeches[0] != "毎朝コーヒーを飲みます。" {
+		t.Errorf("got speeches %q", speeches)
+	}
+	if len(env.telegram.CallsOf("sendVoice")) != 1 {
+		t.Error("the audio is not sent")
+	}
+}
+
+func TestTaskClozeParticleInRomaji(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeCloze, db.ClozeContent{
+			Sentence:    []db.Sentence{{Fragment: "学校"}, {Fragment: "＿＿"}, {Fragment: "行きます。"}},
+			Translation: "Я иду в школу.",
+			Hint:        "частица",
+			Answers:     []string{"に", "へ"},
+		}),
+	}, nil)
+
+	expectContains(t, env.send("/task"), "Заполни пропуск")
+	expectContains(t, env.send("ni"), "Правильно")
+	if len(env.ai.Requests()) != 0 {
+		t.Error("the AI is asked to explain a right romaji answer")
+	}
+}
+
+func TestTaskReadingWithoutQuestions(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeReading, db.ReadingContent{Passage: "毎朝コーヒーを飲みます。"}),
+	}, nil)
+
+	expectContains(t, env.send("/task"), "Ошибка при получении задания")
+}
+
+func TestExplain(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeQuestion, db.QuestionContent{Question: "趣味は何ですか？"}),
+	}, nil)
+
+	expectContains(t, env.send("/explain"), "Сначала получи задание")
+
+	env.send("/task")
+	env.ai.On(aitest.Rule{Contains: "趣味は何ですか？", Reply: "趣味（しゅみ） — хобби"})
+	if reply := env.send("/explain"); reply != "趣味（しゅみ） — хобби" {
+		t.Errorf("got %q, want the explanation", reply)
+	}
+
+	env.ai.Fail(http.StatusBadRequest)
+	expectContains(t, env.send("/explain"), "Ошибка при получении подсказки")
+}
+
+func fish() db.Word {
+	kanji := "魚"
+	return db.Word{Kanji: &kanji, Kana: "さかな", Translation: "рыба", Level: db.LevelN5}
+}
+
+func TestVocab(t *testing.T) {
+	env := newTestEnv(t, nil, []db.Word{fish()})
+	env.production(t)
+
+	expectContains(t, env.send("/vocab"), "рыба")
+
+	// a close but wrong answer is left to the AI
+	env.ai.On(aitest.Rule{Schema: "translation_evaluation", Reply: `{"score": 30, "comment": "Это другое слово"}`})
+	reply := env.send("魚肉")
+	expectContains(t, reply, "Это другое слово")
+	expectContains(t, reply, "Попробуй еще раз")
+
+	// the exact answer is matched without asking the AI
+	requests := len(env.ai.Requests())
+	expectContains(t, env.send("さかな"), "Правильно")
+	if len(env.ai.Requests()) != requests {
+		t.Error("the AI is asked to check an exact answer")
+	}
+	if env.user(t).CurrentWordID != nil {
+		t.Error("the word is not cleared when there are no more words")
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	env := newTestEnv(t, nil, []db.Word{fish()})
+	env.production(t)
+
+	expectContains(t, env.send("/answer"), "Сначала получи слово")
+
+	env.send("/vocab")
+	expectContains(t, env.send("/answer"), "魚 (さかな)")
+	if !env.user(t).CurrentWordReviewed {
+		t.Error("the word is not marked as reviewed after /answer")
+	}
+	if len(env.ai.Requests()) != 0 {
+		t.Error("the AI is asked to reveal the answer")
+	}
+}
+
+func TestKanjiDrillWithoutTable(t *testing.T) {
+	env := newTestEnv(t, nil, []db.Word{fish()})
+
+	expectContains(t, env.send("/kanji"), "таблица кандзи не загружена")
+	if env.user(t).CurrentWordID != nil {
+		t.Error("a drill is started without the kanji table")
+	}
+}
+
+func TestVocabReadingMismatch(t *testing.T) {
+	env := newTestEnv(t, nil, []db.Word{fish()})
+	env.send("/start")
+	if err := env.h.db.UpdateUserCardDirections(testChatID, []string{db.CardDirectionReading}); err != nil {
+		t.Fatalf("failed to set card directions: %v", err)
+	}
+
+	expectContains(t, env.send("/vocab"), "Напиши чтение каной")
+	expectContains(t, env.send("ぎょ"), "Неверное чтение")
+	if len(env.ai.Requests()) != 0 {
+		t.Error("the AI is asked to check a wrong kana reading")
+	}
+}
+
+func TestVocabReadingOfSameSpelling(t *testing.T) {
+	kanji := "明日"
+	ashita := db.Word{Kanji: &kanji, Kana: "あした", Translation: "завтра", Level: db.LevelN5}
+	asu := db.Word{Kanji: &kanji, Kana: "あす", Translation: "завтра", Level: db.LevelN5}
+	env := newTestEnv(t, nil, []db.Word{ashita, asu})
+	env.send("/start")
+	if err := env.h.db.UpdateUserCardDirections(testChatID, []string{db.CardDirectionReading}); err != nil {
+		t.Fatalf("failed to set card directions: %v", err)
+	}
+
+	expectContains(t, env.send("/vocab"), "Напиши чтение каной")
+	word, err := env.h.db.GetWordByID(*env.user(t).CurrentWordID)
+	if err != nil {
+		t.Fatalf("failed to get the current word: %v", err)
+	}
+	other := "あす"
+	if word.Kana == "あす" {
+		other = "あした"
+	}
+	env.ai.On(aitest.Rule{Schema: "translation_evaluation", Reply: `{"score": 100, "comment": ""}`})
+	expectContains(t, env.send(other), "Правильно")
+	if len(env.ai.Requests()) == 0 {
+		t.Error("another reading of the spelling is rejected without asking the AI")
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/ai"
+	"jpbot/internal/db"
+	"jpbot/internal/kana"
+	"jpbot/internal/matcher"
+	"log"
+	"slices"
+	"strings"
+)
+
+var cardDirectionLabels = map[string]string{
+	db.CardDirectionProduction:  "Перевод RU→JP",
+	db.CardDirectionRecognition: "Узнавание JP→RU",
+	db.CardDirectionReading:     "Чтение кандзи",
+}
+
+// cardPrompt returns the MarkdownV2 question of a card.
+func cardPrompt(card db.Card) string {
+	switch card.Direction {
+	case db.CardDirectionRecognition:
+		return fmt.Sprintf("Переведи на русский: *%s*", telegram.EscapeMarkdown(card.Word.GetKanji()))
+	case db.CardDirectionReading:
+		return fmt.Sprintf("Напиши чтение каной: *%s*", telegram.EscapeMarkdown(card.Word.GetKanji()))
+	default:
+		return fmt.Sprintf("Переведи слово: *%s*", telegram.EscapeMarkdown(card.Word.Translation))
+	}
+}
+
+// cardAnswer returns the expected answer of a card as plain text.
+func cardAnswer(card db.Card) string {
+	switch card.Direction {
+	case db.CardDirectionRecognition:
+		return fmt.Sprintf("%s — %s", card.Word.GetKanji(), card.Word.Translation)
+	case db.CardDirectionReading:
+		return fmt.Sprintf("%s — %s", card.Word.GetKanji(), card.Word.Kana)
+	default:
+		if card.Word.Kanji != nil && *card.Word.Kanji != "" {
+			return fmt.Sprintf("%s (%s)", *card.Word.Kanji, card.Word.Kana)
+		}
+		return card.Word.Kana
+	}
+}
+
+// exampleRomaji returns the romaji of an example sentence, fragments without
+// furigana are expected to be kana already. The particles は, へ and を are
+// written as they are pronounced.
+func exampleRomaji(example db.Example) string {
+	var b, text strings.Builder
+	for _, s := range example.Sentence {
+		if s.Furigana != nil {
+			text.WriteString(*s.Furigana)
+			continue
+		}
+		particle, ok := kana.ParticleToRomaji(s.Fragment)
+		if !ok {
+			text.WriteString(s.Fragment)
+			continue
+		}
+		b.WriteString(kana.ToRomaji(text.String()))
+		// ほんを is hon'o, not hono
+		if strings.HasSuffix(text.String(), "ん") {
+			b.WriteByte('\'')
+		}
+		b.WriteString(particle)
+		text.Reset()
+	}
+	b.WriteString(kana.ToRomaji(text.String()))
+	return b.String()
+}
+
+// checkCard evaluates an answer locally and only asks the LLM if the local
+// matcher can't tell whether it is correct.
+func (h *handler) checkCard(card db.Card, userInput string) (ai.WordTranslationEvaluation, error) {
+	if matcher.Match(card, userInput) {
+		return ai.WordTranslationEvaluation{Score: 100}, nil
+	}
+	if h.readingMismatch(card, userInput) {
+		return ai.WordTranslationEvaluation{Comment: "Неверное чтение."}, nil
+	}
+
+	word := card.Word
+	switch card.Direction {
+	case db.CardDirectionRecognition:
+		return h.openaiClient.CheckWordRecognition(word.GetKanji(), word.Kana, word.Translation, userInput)
+	case db.CardDirectionReading:
+		return h.openaiClient.CheckWordReading(word.GetKanji(), word.Kana, userInput)
+	default:
+		return h.openaiClient.CheckWordTranslation(word.GetKanji(), word.Translation, userInput)
+	}
+}
+
+// readingMismatch reports whether a reading answer is certainly wrong. If the
+// readings of the spelling can't be looked up the answer is left to the LLM.
+func (h *handler) readingMismatch(card db.Card, userInput string) bool {
+	if card.Direction != db.CardDirectionReading {
+		return false
+	}
+	var readings []string
+	if card.Word.Kanji != nil {
+		var err error
+		readings, err = h.db.GetSpellingReadings(*card.Word.Kanji)
+		if err != nil {
+			log.Printf("Failed to get readings of %s: %v", *card.Word.Kanji, err)
+			return false
+		}
+	}
+	return matcher.Mismatch(card, userInput, readings)
+}
+
+func cardsKeyboard(enabled []string) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range db.CardDirections {
+		mark := "❌"
+		if slices.Contains(enabled, d) {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s", mark, cardDirectionLabels[d]), "cards:"+d),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+func (h *handler) handleCardsCommand(user *db.User, msg *telegram.SendMessageParams) {
+	msg.Text = "Выбери, какие карточки показывать в /vocab. Нажми, чтобы включить или выключить."
+	msg.ReplyMarkup = cardsKeyboard(user.Directions())
+}
+
+// handleCardsCallback toggles a card direction. At least one direction always stays enabled.
+func (h *handler) handleCardsCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	direction := strings.TrimPrefix(query.Data, "cards:")
+	enabled := user.Directions()
+
+	if !db.IsValidCardDirection(direction) {
+		msg.Text = "Недопустимый тип карточек."
+	} else if slices.Contains(enabled, direction) && len(enabled) == 1 {
+		msg.Text = "Должен остаться хотя бы один тип карточек."
+	} else {
+		if i := slices.Index(enabled, direction); i >= 0 {
+			enabled = slices.Delete(enabled, i, i+1)
+		} else {
+			enabled = append(enabled, direction)
+		}
+
+		if err := h.db.UpdateUserCardDirections(user.TelegramID, enabled); err != nil {
+			log.Printf("Failed to update card directions: %v", err)
+			msg.Text = "Ошибка при сохранении настроек. Попробуй позже."
+		} else {
+			msg.Text = "Типы карточек сохранены."
+			msg.ReplyMarkup = cardsKeyboard(enabled)
+		}
+	}
+
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            msg.Text,
+	}
+
+	if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+}
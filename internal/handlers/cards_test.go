@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"jpbot/internal/db"
+	"testing"
+)
+
+func TestExampleRomaji(t *testing.T) {
+	furigana := func(s string) *string { return &s }
+
+	tests := []struct {
+		sentence []db.Sentence
+		want     string
+	}{
+		{
+			[]db.Sentence{{Fragment: "私", Furigana: furigana("わたし")}, {Fragment: "は"}, {Fragment: "学生", Furigana: furigana("がくせい")}, {Fragment: "です"}},
+			"watashiwagakuseidesu",
+		},
+		{
+			[]db.Sentence{{Fragment: "本", Furigana: furigana("ほん")}, {Fragment: "を"}, {Fragment: "読みます", Furigana: furigana("よみます")}},
+			"hon'oyomimasu",
+		},
+		{
+			[]db.Sentence{{Fragment: "学校", Furigana: furigana("がっこう")}, {Fragment: "へ"}, {Fragment: "行きます", Furigana: furigana("いきます")}},
+			"gakkoueikimasu",
+		},
+		// は inside a word is not the particle
+		{
+			[]db.Sentence{{Fragment: "はな"}, {Fragment: "が"}, {Fragment: "きれい"}},
+			"hanagakirei",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := exampleRomaji(db.Example{Sentence: tt.sentence}); got != tt.want {
+			t.Errorf("exampleRomaji() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	telegram "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"html"
+	"jpbot/internal/db"
+	"jpbot/internal/furigana"
+	"jpbot/internal/kana"
+	"log"
+	"strings"
+)
+
+// clozePrompt is the cloze exercise in HTML, with readings of the sentence
+// shown by the user's furigana mode.
+func clozePrompt(c db.ClozeContent, show furigana.Filter) string {
+	return fmt.Sprintf("Задание:\n\nЗаполни пропуск (%s):\n\n%s\n\n<i>%s</i>",
+		html.EscapeString(c.Hint),
+		furigana.Render(c.Sentence, furigana.FormatTelegram, show),
+		html.EscapeString(c.Translation),
+	)
+}
+
+// clozeSentence is the sentence with the blank as plain text.
+func clozeSentence(c db.ClozeContent) string {
+	var b strings.Builder
+	for _, s := range c.Sentence {
+		b.WriteString(s.Fragment)
+	}
+	return b.String()
+}
+
+// clozeMatches reports whether the input is the removed fragment, written as
+// in the sentence, in kana or in romaji. Romaji is converted here too since
+// a single particle like "ni" is too short for the text conversion.
+func clozeMatches(c db.ClozeContent, input string) bool {
+	input = strings.Trim(input, " 　.,!?。、！？")
+	if kana.IsRomaji(input) {
+		if converted, ok := kana.RomajiWordsToHiragana(input); ok {
+			input = converted
+		}
+	}
+	input = kana.ToHiragana(input)
+	for _, answer := range c.Answers {
+		if input == kana.ToHiragana(answer) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClozeAnswer checks a cloze answer locally. AI is asked only to explain
+// a wrong answer.
+func (h *handler) checkClozeAnswer(user *db.User, exercise db.Exercise, userInput, inputNote string, msg *telegram.SendMessageParams) {
+	c, err := db.ContentAs[db.ClozeContent](exercise.Content)
+	if err != nil || len(c.Answers) == 0 {
+		log.Printf("Invalid cloze exercise %d: %v", exercise.ID, err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	submission := db.Submission{
+		UserID:     user.TelegramID,
+		ExerciseID: exercise.ID,
+		UserInput:  userInput,
+		IsCorrect:  clozeMatches(c, userInput),
+	}
+
+	msg.ParseMode = models.ParseModeMarkdown
+	if submission.IsCorrect {
+		msg.Text = fmt.Sprintf("%sПравильно\\! 🎉\n\nЧтобы получить новое задание, используй /task\\.", inputNote)
+		user.CurrentExerciseID = nil
+		if err := h.db.ClearUserExercise(user.TelegramID); err != nil {
+			log.Printf("Failed to save user: %v", err)
+		}
+		if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
+			log.Printf("Failed to update user ranking: %v", err)
+		}
+	} else {
+		explanation, err := h.openaiClient.ExplainCloze(clozeSentence(c), c.Answers[0], userInput)
+		if err != nil {
+			log.Printf("Failed to explain cloze: %v", err)
+		}
+		submission.GPTFeedback = explanation
+		msg.Text = fmt.Sprintf("%sНеправильно\\.\n\n%s\n\nПопробуй еще раз:",
+			inputNote,
+			telegram.EscapeMarkdown(explanation))
+	}
+
+	if err := h.db.SaveSubmission(submission); err != nil {
+		log.Printf("Failed to save submission: %v", err)
+		msg.Text = "Ошибка при сохранении ответа."
+		msg.ParseMode = ""
+	}
+}
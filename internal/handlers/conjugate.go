@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/conjugate"
+	"jpbot/internal/db"
+	"jpbot/internal/kana"
+	"log"
+	"math/rand"
+)
+
+var conjugationFormLabels = map[conjugate.Form]string{
+	conjugate.FormTe:         "て-форма",
+	conjugate.FormTa:         "た-форма (прошедшее время)",
+	conjugate.FormNegative:   "отрицательная форма (〜ない)",
+	conjugate.FormPolite:     "вежливая форма (〜ます / 〜です)",
+	conjugate.FormPotential:  "потенциальная форма (могу сделать)",
+	conjugate.FormPassive:    "страдательный залог",
+	conjugate.FormCausative:  "побудительный залог (заставить сделать)",
+	conjugate.FormVolitional: "волитив (〜よう, давай сделаем)",
+	conjugate.FormHonorific:  "почтительная форма (尊敬語)",
+	conjugate.FormHumble:     "скромная форма (謙譲語)",
+}
+
+// conjugationAnswer is the form of the word as written in the vocabulary,
+// with the kana version when it differs.
+func conjugationAnswer(word db.Word, form conjugate.Form) (string, error) {
+	spelling, reading := word.DictionaryForm()
+	class := conjugate.Class(word.VerbClass)
+
+	answer, err := conjugate.Conjugate(spelling, class, form)
+	if err != nil {
+		return conjugate.Conjugate(reading, class, form)
+	}
+	if kanaAnswer, err := conjugate.Conjugate(reading, class, form); err == nil && kanaAnswer != answer {
+		answer = fmt.Sprintf("%s (%s)", answer, kanaAnswer)
+	}
+	return answer, nil
+}
+
+// nextConjugation picks a random word of the user's level and one of its
+// forms and makes it the current drill.
+func (h *handler) nextConjugation(user *db.User) (string, error) {
+	word, err := h.db.GetRandomConjugationWord(user.Level)
+	if err != nil {
+		return "", err
+	}
+
+	spelling, reading := word.DictionaryForm()
+	forms := conjugate.Forms(spelling, conjugate.Class(word.VerbClass))
+	if len(forms) == 0 {
+		return "", fmt.Errorf("word %d has unknown verb class %q", word.ID, word.VerbClass)
+	}
+	form := forms[rand.Intn(len(forms))]
+	if _, err := conjugationAnswer(word, form); err != nil {
+		return "", fmt.Errorf("error conjugating word %d: %w", word.ID, err)
+	}
+
+	if err := h.db.MarkConjugationSent(user.TelegramID, word.ID, string(form)); err != nil {
+		return "", err
+	}
+
+	if spelling == reading {
+		return fmt.Sprintf("Поставь «%s» (%s) в форму: %s", spelling, word.Translation, conjugationFormLabels[form]), nil
+	}
+	return fmt.Sprintf("Поставь «%s» (%s — %s) в форму: %s", spelling, reading, word.Translation, conjugationFormLabels[form]), nil
+}
+
+func (h *handler) handleConjugateCommand(user *db.User, msg *telegram.SendMessageParams) {
+	if user.CurrentWordID != nil && user.CurrentMode == db.ModeConjugate {
+		msg.Text = "У тебя уже есть задание. Попробуй решить его или используй /answer."
+		return
+	}
+
+	prompt, err := h.nextConjugation(user)
+	if errors.Is(err, db.ErrNotFound) {
+		msg.Text = "Для твоего уровня нет глаголов и прилагательных."
+		return
+	} else if err != nil {
+		log.Printf("Failed to get conjugation drill: %v", err)
+		msg.Text = "Ошибка при получении задания. Попробуй позже."
+		return
+	}
+
+	msg.Text = fmt.Sprintf("%s\n\nЕсли не знаешь, используй /answer", prompt)
+}
+
+// handleConjugationAnswer grades the answer against the conjugation engine,
+// a correct answer is followed by the next drill.
+func (h *handler) handleConjugationAnswer(user *db.User, userInput string, msg *telegram.SendMessageParams) {
+	word, err := h.db.GetWordByID(*user.CurrentWordID)
+	if err != nil {
+		msg.Text = "Ошибка при получении слова."
+		log.Printf("Failed to get word: %v", err)
+		return
+	}
+
+	if kana.IsRomaji(userInput) {
+		if converted, ok := kana.RomajiTextToHiragana(userInput); ok {
+			userInput = converted
+		}
+	}
+
+	spelling, reading := word.DictionaryForm()
+	form := conjugate.Form(user.CurrentConjugationForm)
+	if !conjugate.Check(spelling, reading, conjugate.Class(word.VerbClass), form, userInput) {
+		msg.Text = fmt.Sprintf("Неправильно: %s\n\nПопробуй еще раз или используй /answer.", userInput)
+		return
+	}
+
+	answer, _ := conjugationAnswer(word, form)
+	praise := fmt.Sprintf("Правильно! 🎉 %s", answer)
+
+	prompt, err := h.nextConjugation(user)
+	if err != nil {
+		log.Printf("Failed to get next conjugation drill: %v", err)
+		msg.Text = fmt.Sprintf("%s\n\nЧтобы продолжить, используй /conjugate.", praise)
+		if err := h.db.ClearUserWord(user.TelegramID); err != nil {
+			log.Printf("Failed to clear user word: %v", err)
+		}
+		return
+	}
+	msg.Text = fmt.Sprintf("%s\n\n%s", praise, prompt)
+
+	if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
+		log.Printf("Failed to update user ranking: %v", err)
+	}
+}
+
+// handleConjugationReveal shows the answer of the current drill for /answer.
+func (h *handler) handleConjugationReveal(user *db.User, msg *telegram.SendMessageParams) {
+	word, err := h.db.GetWordByID(*user.CurrentWordID)
+	if err != nil {
+		msg.Text = "Ошибка при получении слова."
+		log.Printf("Failed to get word: %v", err)
+		return
+	}
+
+	answer, err := conjugationAnswer(word, conjugate.Form(user.CurrentConjugationForm))
+	if err != nil {
+		log.Printf("Failed to conjugate word %d: %v", word.ID, err)
+		msg.Text = "Ошибка при получении ответа."
+		return
+	}
+
+	if err := h.db.ClearUserWord(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user word: %v", err)
+	}
+	msg.Text = fmt.Sprintf("Ответ: %s\n\nЧтобы продолжить, используй /conjugate.", answer)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	DefaultWorkers   = 8
+	DefaultQueueSize = 64
+
+	// seenUpdates is how many update IDs are remembered to drop redeliveries.
+	seenUpdates = 1024
+)
+
+var (
+	ErrQueueFull        = errors.New("update queue is full")
+	ErrDispatcherClosed = errors.New("dispatcher is closed")
+)
+
+// DispatcherConfig is the dispatch section of config.yml. Zero values are
+// replaced with the defaults.
+type DispatcherConfig struct {
+	// Workers process updates in parallel, the updates of a chat always go to
+	// the same worker so they are processed in order.
+	Workers int `yaml:"workers" validate:"gte=0"`
+	// QueueSize is how many updates may wait for each worker.
+	QueueSize int `yaml:"queue_size" validate:"gte=0"`
+}
+
+// Dispatcher processes updates in the background. Every update is processed
+// once, even if Telegram delivers it again.
+type Dispatcher struct {
+	process func(tgbotapi.Update)
+
+	mu     sync.Mutex
+	queues []chan tgbotapi.Update
+	seen   map[int]struct{}
+	// order is a ring of the update IDs in seen, the oldest is forgotten first
+	order  []int
+	next   int
+	closed bool
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher starts the workers calling process for every update.
+func NewDispatcher(cfg DispatcherConfig, process func(tgbotapi.Update)) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	d := &Dispatcher{
+		process: process,
+		queues:  make([]chan tgbotapi.Update, cfg.Workers),
+		seen:    make(map[int]struct{}, seenUpdates),
+		order:   make([]int, 0, seenUpdates),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan tgbotapi.Update, cfg.QueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Dispatch queues the update for the worker of its chat. An update seen
+// before is dropped without an error. If the queue is full the update is not
+// remembered, so that it is accepted when Telegram delivers it again.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	if _, ok := d.seen[update.UpdateID]; ok {
+		return nil
+	}
+
+	queue := d.queues[shard(updateChatID(update), len(d.queues))]
+	select {
+	case queue <- update:
+	default:
+		return fmt.Errorf("%w: update %d", ErrQueueFull, update.UpdateID)
+	}
+
+	d.remember(update.UpdateID)
+	return nil
+}
+
+func (d *Dispatcher) remember(updateID int) {
+	if len(d.order) < cap(d.order) {
+		d.order = append(d.order, updateID)
+	} else {
+		delete(d.seen, d.order[d.next])
+		d.order[d.next] = updateID
+		d.next = (d.next + 1) % len(d.order)
+	}
+	d.seen[updateID] = struct{}{}
+}
+
+// Shutdown stops accepting updates and waits until the queued ones are
+// processed or the context is done.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error draining update queues: %w", ctx.Err())
+	}
+}
+
+func (d *Dispatcher) work(queue <-chan tgbotapi.Update) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.safeProcess(update)
+	}
+}
+
+// safeProcess keeps the worker alive if processing an update panics.
+func (d *Dispatcher) safeProcess(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic while processing update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+	d.process(update)
+}
+
+// updateChatID is the user the update is from, the same ID handleUpdate
+// uses as the chat.
+func updateChatID(update tgbotapi.Update) int64 {
+	if update.Message != nil && update.Message.From != nil {
+		return update.Message.From.ID
+	}
+	if update.CallbackQuery != nil && update.CallbackQuery.From != nil {
+		return update.CallbackQuery.From.ID
+	}
+	return 0
+}
+
+func shard(chatID int64, n int) int {
+	return int(uint64(chatID) % uint64(n))
+}
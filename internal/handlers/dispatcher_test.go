@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync"
+	"testing"
+	"time"
+)
+
+func update(id int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message:  &tgbotapi.Message{From: &tgbotapi.User{ID: chatID}, Text: "text"},
+	}
+}
+
+// recorder collects the processed update IDs by chat.
+type recorder struct {
+	mu    sync.Mutex
+	chats map[int64][]int
+}
+
+func (r *recorder) process(u tgbotapi.Update) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chats == nil {
+		r.chats = make(map[int64][]int)
+	}
+	chatID := updateChatID(u)
+	r.chats[chatID] = append(r.chats[chatID], u.UpdateID)
+}
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	var r recorder
+	d := NewDispatcher(DispatcherConfig{Workers: 4, QueueSize: 100}, r.process)
+
+	id := 0
+	for i := 0; i < 50; i++ {
+		for chatID := int64(1); chatID <= 5; chatID++ {
+			id++
+			if err := d.Dispatch(update(id, chatID)); err != nil {
+				t.Fatalf("Dispatch: %v", err)
+			}
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for chatID, ids := range r.chats {
+		if len(ids) != 50 {
+			t.Errorf("chat %d: got %d updates, want 50", chatID, len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("chat %d: update %d processed after %d", chatID, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestDispatcherDropsDuplicates(t *testing.T) {
+	var r recorder
+	d := NewDispatcher(DispatcherConfig{Workers: 2, QueueSize: 10}, r.process)
+
+	for _, id := range []int{1, 2, 1, 3, 2} {
+		if err := d.Dispatch(update(id, 7)); err != nil {
+			t.Fatalf("Dispatch(%d): %v", id, err)
+		}
+	}
+	d.Shutdown(context.Background())
+
+	if got := r.chats[7]; len(got) != 3 {
+		t.Errorf("got updates %v, want 1, 2 and 3 once", got)
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	var r recorder
+	d := NewDispatcher(DispatcherConfig{Workers: 1, QueueSize: 1}, func(u tgbotapi.Update) {
+		<-release
+		r.process(u)
+	})
+
+	// the first update is taken by the worker, the second waits in the queue
+	d.Dispatch(update(1, 1))
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err := d.Dispatch(update(2, 1)); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Dispatch(2): %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := d.Dispatch(update(3, 1)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+
+	// a rejected update is accepted when it is delivered again
+	close(release)
+	deadline = time.Now().Add(time.Second)
+	for d.Dispatch(update(3, 1)) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the rejected update is not accepted again")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	d.Shutdown(context.Background())
+
+	if got := r.chats[1]; len(got) != 3 {
+		t.Errorf("got updates %v, want 1, 2 and 3", got)
+	}
+}
+
+func TestDispatcherShutdown(t *testing.T) {
+	var r recorder
+	d := NewDispatcher(DispatcherConfig{Workers: 2, QueueSize: 10}, func(u tgbotapi.Update) {
+		if u.UpdateID == 1 {
+			panic("broken update")
+		}
+		time.Sleep(10 * time.Millisecond)
+		r.process(u)
+	})
+
+	for id := 1; id <= 5; id++ {
+		d.Dispatch(update(id, 1))
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// the pending updates are processed before Shutdown returns, the panic
+	// doesn't stop the worker
+	if got := r.chats[1]; len(got) != 4 {
+		t.Errorf("got updates %v, want 2 to 5", got)
+	}
+	if err := d.Dispatch(update(6, 1)); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("got %v after shutdown, want ErrDispatcherClosed", err)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/db"
+	"jpbot/internal/furigana"
+	"log"
+	"strings"
+)
+
+var furiganaModeLabels = map[string]string{
+	db.FuriganaModeAlways:  "Всегда",
+	db.FuriganaModeUnknown: "Только для незнакомых кандзи",
+	db.FuriganaModeNever:   "Никогда",
+}
+
+// furiganaFilter returns the filter of the user's furigana mode. Kanji of
+// words the user has learned count as known.
+func (h *handler) furiganaFilter(userID int64, mode string) furigana.Filter {
+	if mode != db.FuriganaModeUnknown {
+		return furigana.ForMode(mode, nil)
+	}
+
+	known, err := h.db.GetKnownKanji(userID)
+	if err != nil {
+		log.Printf("Failed to get known kanji: %v", err)
+	}
+
+	return furigana.ForMode(mode, known)
+}
+
+func furiganaKeyboard(current string) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, mode := range db.FuriganaModes {
+		label := furiganaModeLabels[mode]
+		if mode == current {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "furigana:"+mode),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+func (h *handler) handleFuriganaCommand(user *db.User, msg *telegram.SendMessageParams) {
+	msg.Text = "Когда показывать чтение кандзи в примерах? Кандзи считается знакомым, " +
+		"если ты несколько раз подряд вспомнил слово с ним."
+	msg.ReplyMarkup = furiganaKeyboard(user.FuriganaMode)
+}
+
+func (h *handler) handleFuriganaCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	mode := strings.TrimPrefix(query.Data, "furigana:")
+
+	if !db.IsValidFuriganaMode(mode) {
+		msg.Text = "Недопустимая настройка."
+	} else if err := h.db.UpdateUserFuriganaMode(user.TelegramID, mode); err != nil {
+		log.Printf("Failed to update furigana mode: %v", err)
+		msg.Text = "Ошибка при сохранении настроек. Попробуй позже."
+	} else {
+		msg.Text = fmt.Sprintf("Фуригана: %s", strings.ToLower(furiganaModeLabels[mode]))
+	}
+
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            msg.Text,
+	}
+
+	if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+}
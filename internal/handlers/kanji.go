@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/db"
+	"jpbot/internal/kana"
+	"jpbot/internal/kanji"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+const (
+	kanjiLookupWords = 15
+	// kanjiDrillCandidates is the number of known words tried for a drill,
+	// words with irregular readings like 今日 can't be split.
+	kanjiDrillCandidates = 20
+)
+
+// errNoKanjiTable means the kanji table is not imported, so there are no
+// readings to check the drills against.
+var errNoKanjiTable = errors.New("kanji table is empty")
+
+// alignWord splits the reading of a word between its kanji using the
+// readings from the kanji table. ok is false unless every kanji of the word
+// has readings there, a guessed split can't be used as an answer key.
+func (h *handler) alignWord(word db.Word) ([]kanji.Segment, bool) {
+	spelling, reading := word.DictionaryForm()
+	known := true
+	segments, ok := kanji.Align(spelling, reading, func(r rune) []string {
+		k, err := h.db.GetKanji(string(r))
+		if err != nil {
+			if !errors.Is(err, db.ErrNotFound) {
+				log.Printf("Failed to get kanji: %v", err)
+			}
+			known = false
+			return nil
+		}
+		readings := k.Readings()
+		if len(readings) == 0 {
+			known = false
+		}
+		return readings
+	})
+	return segments, ok && known
+}
+
+// kanjiSegment returns the segment of the kanji in the current drill word.
+func (h *handler) kanjiSegment(word db.Word, character string) (kanji.Segment, bool) {
+	segments, ok := h.alignWord(word)
+	if !ok {
+		return kanji.Segment{}, false
+	}
+	for _, s := range segments {
+		if s.Text == character {
+			return s, true
+		}
+	}
+	return kanji.Segment{}, false
+}
+
+func kanjiDrillPrompt(word db.Word, character, mode string, segment kanji.Segment) string {
+	spelling, reading := word.DictionaryForm()
+	if mode == db.ModeKanjiWriting {
+		hidden := strings.Replace(spelling, character, fmt.Sprintf("［%s］", segment.Reading), 1)
+		return fmt.Sprintf("Напиши кандзи: %s — %s (%s)", hidden, reading, word.Translation)
+	}
+	return fmt.Sprintf("Как читается %s в слове %s (%s)? Напиши каной.", character, spelling, word.Translation)
+}
+
+// nextKanjiDrill asks for the reading or the writing of a kanji in a word the
+// user already knows.
+func (h *handler) nextKanjiDrill(user *db.User) (string, error) {
+	initialized, err := h.db.IsKanjiInitialized()
+	if err != nil {
+		return "", err
+	}
+	if !initialized {
+		return "", errNoKanjiTable
+	}
+
+	words, err := h.db.GetKnownKanjiWords(user.ID, kanjiDrillCandidates)
+	if err != nil {
+		return "", err
+	}
+
+	for _, word := range words {
+		segments, ok := h.alignWord(word)
+		if !ok {
+			continue
+		}
+		var kanjiSegments []kanji.Segment
+		for _, s := range segments {
+			if s.IsKanji() {
+				kanjiSegments = append(kanjiSegments, s)
+			}
+		}
+		if len(kanjiSegments) == 0 {
+			continue
+		}
+
+		segment := kanjiSegments[rand.Intn(len(kanjiSegments))]
+		mode := db.ModeKanjiReading
+		if rand.Intn(2) == 0 {
+			mode = db.ModeKanjiWriting
+		}
+		if err := h.db.MarkKanjiSent(user.TelegramID, word.ID, segment.Text, mode); err != nil {
+			return "", err
+		}
+		return kanjiDrillPrompt(word, segment.Text, mode, segment), nil
+	}
+
+	return "", db.ErrNotFound
+}
+
+func (h *handler) handleKanjiCommand(user *db.User, args string, msg *telegram.SendMessageParams) {
+	if args = strings.TrimSpace(args); args != "" {
+		h.handleKanjiLookup(args, msg)
+		return
+	}
+
+	if user.CurrentWordID != nil && (user.CurrentMode == db.ModeKanjiReading || user.CurrentMode == db.ModeKanjiWriting) {
+		msg.Text = "У тебя уже есть задание. Попробуй решить его или используй /answer."
+		return
+	}
+
+	prompt, err := h.nextKanjiDrill(user)
+	if errors.Is(err, errNoKanjiTable) {
+		msg.Text = "Задания с кандзи пока недоступны: таблица кандзи не загружена.\n\nЧтобы узнать о кандзи, напиши /kanji и сам кандзи, например /kanji 日"
+		return
+	} else if errors.Is(err, db.ErrNotFound) {
+		msg.Text = "Сначала выучи несколько слов с кандзи в /vocab.\n\nЧтобы узнать о кандзи, напиши /kanji и сам кандзи, например /kanji 日"
+		return
+	} else if err != nil {
+		log.Printf("Failed to get kanji drill: %v", err)
+		msg.Text = "Ошибка при получении задания. Попробуй позже."
+		return
+	}
+
+	msg.Text = fmt.Sprintf("%s\n\nЕсли не знаешь, используй /answer", prompt)
+}
+
+// handleKanjiLookup shows a kanji from the table and the words that use it.
+func (h *handler) handleKanjiLookup(character string, msg *telegram.SendMessageParams) {
+	runes := []rune(character)
+	if len(runes) != 1 || !kana.IsKanji(runes[0]) {
+		msg.Text = "Напиши один кандзи, например /kanji 日"
+		return
+	}
+
+	k, err := h.db.GetKanji(character)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to get kanji: %v", err)
+		msg.Text = "Ошибка при получении кандзи. Попробуй позже."
+		return
+	}
+	words, err := h.db.GetWordsWithKanji(character, kanjiLookupWords)
+	if err != nil {
+		log.Printf("Failed to get words with kanji: %v", err)
+		msg.Text = "Ошибка при получении кандзи. Попробуй позже."
+		return
+	}
+
+	if k.Character == "" && len(words) == 0 {
+		msg.Text = fmt.Sprintf("Кандзи %s не найден.", character)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(character)
+	if k.Character != "" {
+		if len(k.OnReadings) > 0 {
+			fmt.Fprintf(&b, "\nОн: %s", strings.Join(k.OnReadings, ", "))
+		}
+		if len(k.KunReadings) > 0 {
+			fmt.Fprintf(&b, "\nКун: %s", strings.Join(k.KunReadings, ", "))
+		}
+		if len(k.Meanings) > 0 {
+			fmt.Fprintf(&b, "\nЗначения: %s", strings.Join(k.Meanings, ", "))
+		}
+		fmt.Fprintf(&b, "\nЧерт: %d", k.StrokeCount)
+		if len(k.Radicals) > 0 {
+			fmt.Fprintf(&b, ", ключ: %d", k.Radicals[0])
+		}
+		if k.Level != "" {
+			fmt.Fprintf(&b, ", JLPT %s", k.Level)
+		}
+	}
+
+	if len(words) > 0 {
+		b.WriteString("\n\nСлова:")
+		for _, w := range words {
+			fmt.Fprintf(&b, "\n%s (%s) — %s [%s]", w.GetKanji(), w.Kana, w.Translation, w.Level)
+		}
+	}
+
+	msg.Text = b.String()
+}
+
+// handleKanjiAnswer checks the reading or the kanji the user wrote, a correct
+// answer is followed by the next drill.
+func (h *handler) handleKanjiAnswer(user *db.User, userInput string, msg *telegram.SendMessageParams) {
+	word, err := h.db.GetWordByID(*user.CurrentWordID)
+	if err != nil {
+		msg.Text = "Ошибка при получении слова."
+		log.Printf("Failed to get word: %v", err)
+		return
+	}
+
+	segment, ok := h.kanjiSegment(word, user.CurrentKanji)
+	if !ok {
+		log.Printf("Failed to align word %d for kanji %s", word.ID, user.CurrentKanji)
+		msg.Text = "Ошибка при проверке ответа."
+		return
+	}
+
+	userInput = strings.TrimSpace(userInput)
+	var correct bool
+	if user.CurrentMode == db.ModeKanjiWriting {
+		correct = userInput == segment.Text
+	} else {
+		if kana.IsRomaji(userInput) {
+			if converted, ok := kana.RomajiToHiragana(userInput); ok {
+				userInput = converted
+			}
+		}
+		correct = kana.ToHiragana(userInput) == segment.Reading
+	}
+
+	if !correct {
+		msg.Text = fmt.Sprintf("Неправильно: %s\n\nПопробуй еще раз или используй /answer.", userInput)
+		return
+	}
+
+	spelling, reading := word.DictionaryForm()
+	praise := fmt.Sprintf("Правильно! 🎉 %s (%s) — %s", spelling, reading, word.Translation)
+
+	prompt, err := h.nextKanjiDrill(user)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) && !errors.Is(err, errNoKanjiTable) {
+			log.Printf("Failed to get next kanji drill: %v", err)
+		}
+		msg.Text = fmt.Sprintf("%s\n\nЧтобы продолжить, используй /kanji.", praise)
+		if err := h.db.ClearUserWord(user.TelegramID); err != nil {
+			log.Printf("Failed to clear user word: %v", err)
+		}
+		return
+	}
+	msg.Text = fmt.Sprintf("%s\n\n%s", praise, prompt)
+
+	if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
+		log.Printf("Failed to update user ranking: %v", err)
+	}
+}
+
+// handleKanjiReveal shows the answer of the current drill for /answer.
+func (h *handler) handleKanjiReveal(user *db.User, msg *telegram.SendMessageParams) {
+	word, err := h.db.GetWordByID(*user.CurrentWordID)
+	if err != nil {
+		msg.Text = "Ошибка при получении слова."
+		log.Printf("Failed to get word: %v", err)
+		return
+	}
+
+	segment, ok := h.kanjiSegment(word, user.CurrentKanji)
+	if !ok {
+		log.Printf("Failed to align word %d for kanji %s", word.ID, user.CurrentKanji)
+		msg.Text = "Ошибка при получении ответа."
+		return
+	}
+
+	if err := h.db.ClearUserWord(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user word: %v", err)
+	}
+	spelling, reading := word.DictionaryForm()
+	msg.Text = fmt.Sprintf("Ответ: %s читается «%s» в слове %s (%s) — %s\n\nЧтобы продолжить, используй /kanji.",
+		segment.Text, segment.Reading, spelling, reading, word.Translation)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"github.com/labstack/echo/v4"
+	"jpbot/internal/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxLeechButtons = 10
+
+// leechHint returns a MarkdownV2 spoiler with a mnemonic if the card is a
+// leech. The mnemonic is generated once and stored with the word's reviews.
+func (h *handler) leechHint(user *db.User, card db.Card) string {
+	word := card.Word
+	review, err := h.db.GetWordReview(user.ID, word.ID, card.Direction)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			log.Printf("Failed to get word review: %v", err)
+		}
+		return ""
+	}
+
+	if !review.Leech {
+		return ""
+	}
+
+	var mnemonic string
+	if review.Mnemonic != nil {
+		mnemonic = *review.Mnemonic
+	} else {
+		mnemonic, err = h.openaiClient.GenerateMnemonic(word.GetKanji(), word.Kana, word.Translation)
+		if err != nil {
+			log.Printf("Failed to generate mnemonic: %v", err)
+			return ""
+		}
+		if err := h.db.SaveWordMnemonic(user.ID, word.ID, mnemonic); err != nil {
+			log.Printf("Failed to save mnemonic: %v", err)
+		}
+	}
+
+	return fmt.Sprintf("\n\n💡 Мнемоника: ||%s||", telegram.EscapeMarkdown(mnemonic))
+}
+
+// suspendedLeechNotice clears the current word and returns a notice if the
+// last review turned the card into a suspended leech.
+func (h *handler) suspendedLeechNotice(user *db.User, card db.Card) string {
+	review, err := h.db.GetWordReview(user.ID, card.Word.ID, card.Direction)
+	if err != nil {
+		log.Printf("Failed to get word review: %v", err)
+		return ""
+	}
+
+	if !review.Suspended {
+		return ""
+	}
+
+	if err := h.db.ClearUserWord(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user word: %v", err)
+	}
+
+	return fmt.Sprintf("Это слово не запоминается уже %d раз, поэтому оно приостановлено. "+
+		"Вернуть его можно через /leeches, тогда оно появится с мнемоникой. Следующее слово: /vocab", review.Lapses)
+}
+
+func (h *handler) handleLeechesCommand(user *db.User, msg *telegram.SendMessageParams) {
+	leeches, err := h.db.GetLeeches(user.ID)
+	if err != nil {
+		log.Printf("Failed to get leeches: %v", err)
+		msg.Text = "Ошибка при получении слов. Попробуй позже."
+		return
+	}
+
+	if len(leeches) == 0 {
+		msg.Text = "У тебя нет слов, которые никак не запоминаются. Так держать!"
+		return
+	}
+
+	lines := []string{"Слова, которые никак не запоминаются:"}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, l := range leeches {
+		status := "на повторении"
+		if l.Suspended {
+			status = "приостановлено"
+		}
+		lines = append(lines, fmt.Sprintf("• %s (%s) — %s, %s, ошибок: %d, %s",
+			l.Word.GetKanji(), l.Word.Kana, l.Word.Translation, cardDirectionLabels[l.Direction], l.Lapses, status))
+
+		if l.Suspended && len(rows) < maxLeechButtons {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("Вернуть %s", l.Word.GetKanji()),
+					fmt.Sprintf("unsuspend:%d:%s", l.Word.ID, l.Direction),
+				),
+			))
+		}
+	}
+
+	msg.Text = strings.Join(lines, "\n")
+	if len(rows) > 0 {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		msg.ReplyMarkup = &keyboard
+	}
+}
+
+func (h *handler) handleUnsuspendCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	wordID, direction, found := strings.Cut(strings.TrimPrefix(query.Data, "unsuspend:"), ":")
+	id, err := strconv.ParseInt(wordID, 10, 64)
+	if !found || err != nil || !db.IsValidCardDirection(direction) {
+		msg.Text = "Недопустимое слово."
+	} else if err := h.db.UnsuspendWord(user.ID, id, direction); err != nil && errors.Is(err, db.ErrNotFound) {
+		msg.Text = "Это слово уже на повторении."
+	} else if err != nil {
+		log.Printf("Failed to unsuspend word: %v", err)
+		msg.Text = "Ошибка при возвращении слова. Попробуй позже."
+	} else {
+		msg.Text = "Слово вернулось на повторение. Используй /vocab."
+	}
+
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            msg.Text,
+	}
+
+	if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+}
+
+func (h *handler) HandleLeeches(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	leeches, err := h.db.GetLeeches(uid)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get leeches").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, leeches)
+}
+
+func (h *handler) HandleUnsuspendLeech(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	wordID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid word id")
+	}
+
+	direction := c.QueryParam("direction")
+	if direction == "" {
+		direction = db.CardDirectionProduction
+	} else if !db.IsValidCardDirection(direction) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid card direction")
+	}
+
+	if err := h.db.UnsuspendWord(uid, wordID, direction); err != nil && errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "suspended word not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unsuspend word").SetInternal(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"jpbot/internal/db"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxDailyLimit = 1000
+
+// noWordsText explains why there is no word to study right now: either the
+// level is exhausted or one of the user's daily limits is reached.
+func (h *handler) noWordsText(user *db.User) string {
+	p, err := h.db.GetDailyProgress(user.ID, user.Level)
+	if err != nil {
+		log.Printf("Failed to get daily progress: %v", err)
+		return "Слова для твоего уровня закончились. Попробуй зайти завтра!"
+	}
+
+	newLimitHit := p.NewWordsLeft() == 0
+	reviewLimitHit := p.ReviewsLeft() == 0 && p.DueReviews > 0
+	if !newLimitHit && !reviewLimitHit {
+		return "Слова для твоего уровня закончились. Попробуй зайти завтра!"
+	}
+
+	var lines []string
+	if newLimitHit {
+		lines = append(lines, fmt.Sprintf("Лимит новых слов на сегодня исчерпан (%d/%d).", p.NewWords, p.NewWordsLimit))
+	}
+	if reviewLimitHit {
+		lines = append(lines, fmt.Sprintf("Лимит повторений на сегодня исчерпан (%d/%d), ещё %d слов ждут повторения.",
+			p.Reviews, p.ReviewsLimit, p.DueReviews))
+	} else {
+		lines = append(lines, fmt.Sprintf("Слов к повторению сейчас нет, осталось повторений на сегодня: %d.", p.ReviewsLeft()))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Лимиты обновятся через %s.", formatDuration(time.Until(p.ResetAt))),
+		"Изменить лимиты: /limits <новых слов> <повторений>",
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+func (h *handler) handleLimitsCommand(user *db.User, args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		p, err := h.db.GetDailyProgress(user.ID, user.Level)
+		if err != nil {
+			log.Printf("Failed to get daily progress: %v", err)
+			return "Ошибка при получении лимитов. Попробуй позже."
+		}
+		return fmt.Sprintf("Сегодня:\nНовые слова: %d/%d\nПовторения: %d/%d\nЖдут повторения: %d\n\nИзменить: /limits <новых слов> <повторений>",
+			p.NewWords, p.NewWordsLimit, p.Reviews, p.ReviewsLimit, p.DueReviews)
+	}
+
+	if len(fields) != 2 {
+		return "Использование: /limits <новых слов> <повторений>, например /limits 10 100"
+	}
+
+	newWords, err1 := strconv.Atoi(fields[0])
+	reviews, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || newWords < 0 || reviews < 0 || newWords > maxDailyLimit || reviews > maxDailyLimit {
+		return fmt.Sprintf("Лимиты должны быть числами от 0 до %d.", maxDailyLimit)
+	}
+
+	if err := h.db.UpdateUserLimits(user.TelegramID, newWords, reviews); err != nil {
+		log.Printf("Failed to update user limits: %v", err)
+		return "Ошибка при обновлении лимитов. Попробуй позже."
+	}
+
+	return fmt.Sprintf("Лимиты обновлены: %d новых слов и %d повторений в день.", newWords, reviews)
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%d мин", minutes)
+	}
+	return fmt.Sprintf("%d ч %d мин", hours, minutes)
+}
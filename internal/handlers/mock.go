@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"github.com/labstack/echo/v4"
+	"jpbot/internal/db"
+	"jpbot/internal/mock"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMockHistoryLimit = 10
+
+var mockSectionLabels = map[string]string{
+	mock.SectionVocabulary: "Лексика",
+	mock.SectionGrammar:    "Грамматика",
+	mock.SectionReading:    "Чтение",
+	mock.SectionListening:  "Аудирование",
+}
+
+var mockScoreLabels = map[string]string{
+	mock.ScoreLanguage:  "Языковые знания",
+	mock.ScoreReading:   "Чтение",
+	mock.ScoreListening: "Аудирование",
+}
+
+// startMock assembles a new mock test for the user's level and saves it.
+func (h *handler) startMock(userID int64, level string) (db.MockAttempt, error) {
+	attempt, err := mock.Start(userID, level, h.db, time.Now())
+	if err != nil {
+		return attempt, err
+	}
+	if err := h.db.CreateMockAttempt(&attempt); err != nil {
+		return attempt, err
+	}
+	return attempt, nil
+}
+
+// activeMock returns the unfinished attempt of the user. An attempt whose
+// time is over is finished and saved on the way, expired then is true.
+func (h *handler) activeMock(userID int64) (attempt db.MockAttempt, expired bool, err error) {
+	attempt, err = h.db.GetActiveMockAttempt(userID)
+	if err != nil {
+		return attempt, false, err
+	}
+	if now := time.Now(); mock.Expired(&attempt, now) {
+		mock.Finish(&attempt, now)
+		if err := h.db.UpdateMockAttempt(attempt); err != nil {
+			return attempt, true, err
+		}
+		return attempt, true, nil
+	}
+	return attempt, false, nil
+}
+
+func (h *handler) handleMockCommand(user *db.User, args string, msg *telegram.SendMessageParams) {
+	if strings.TrimSpace(args) == "history" {
+		h.handleMockHistory(user, msg)
+		return
+	}
+
+	attempt, expired, err := h.activeMock(user.ID)
+	if err == nil && expired {
+		msg.Text = fmt.Sprintf("Время прошлого теста вышло.\n\n%s\n\nЧтобы начать новый тест, используй /mock.", mockResultText(attempt))
+		return
+	} else if err == nil {
+		h.mockQuestion(user.TelegramID, attempt, msg)
+		return
+	} else if !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to get mock attempt: %v", err)
+		msg.Text = "Ошибка при получении теста. Попробуй позже."
+		return
+	}
+
+	attempt, err = h.startMock(user.ID, user.Level)
+	if errors.Is(err, mock.ErrNoExercises) {
+		msg.Text = "Для твоего уровня пока недостаточно заданий для пробного теста."
+		return
+	} else if err != nil {
+		log.Printf("Failed to start mock test: %v", err)
+		msg.Text = "Ошибка при создании теста. Попробуй позже."
+		return
+	}
+
+	h.mockQuestion(user.TelegramID, attempt, msg)
+	if msg.ReplyMarkup != nil {
+		msg.Text = fmt.Sprintf("Пробный тест JLPT %s: %d вопросов, %d мин. Лексика, грамматика, чтение и аудирование, "+
+			"как на экзамене. Результат и баллы будут в конце.\n\n%s",
+			attempt.Level, len(attempt.Questions), int(attempt.Deadline.Sub(attempt.StartedAt).Minutes()), msg.Text)
+	}
+}
+
+// mockQuestion puts the current question of the attempt into msg, the
+// recording of a listening question is sent before it.
+func (h *handler) mockQuestion(chatID int64, attempt db.MockAttempt, msg *telegram.SendMessageParams) {
+	index := len(attempt.Answers)
+	q := attempt.Questions[index]
+
+	if q.Audio != "" {
+		if err := h.sendVoice(context.Background(), chatID, h.openaiClient.SpeechParams(q.Audio)); err != nil {
+			log.Printf("Failed to send audio: %v", err)
+			msg.Text = "Ошибка при генерации аудио. Попробуй позже: /mock"
+			return
+		}
+	}
+
+	left := int(math.Ceil(time.Until(attempt.Deadline).Minutes()))
+	lines := []string{
+		fmt.Sprintf("Вопрос %d/%d · %s · осталось %d мин", index+1, len(attempt.Questions), mockSectionLabels[q.Section], left),
+		"",
+		q.Stem,
+		"",
+	}
+	var buttons []tgbotapi.InlineKeyboardButton
+	for i, option := range q.Options {
+		lines = append(lines, fmt.Sprintf("%d) %s", i+1, option))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			strconv.Itoa(i+1),
+			fmt.Sprintf("mock:%d:%d:%d", attempt.ID, index, i),
+		))
+	}
+
+	msg.Text = strings.Join(lines, "\n")
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+	msg.ReplyMarkup = &keyboard
+}
+
+func mockScoreLabel(result db.MockResult, section string) string {
+	if section != mock.ScoreLanguage {
+		return mockScoreLabels[section]
+	}
+	for _, s := range result.Sections {
+		if s.Section == mock.ScoreReading {
+			return mockScoreLabels[section]
+		}
+	}
+	// N4 and N5 have no separate reading score
+	return "Языковые знания и чтение"
+}
+
+func mockResultText(attempt db.MockAttempt) string {
+	if attempt.Result == nil {
+		return ""
+	}
+	result := *attempt.Result
+
+	lines := []string{fmt.Sprintf("Результат пробного теста JLPT %s:", attempt.Level), ""}
+	for _, s := range result.Sections {
+		lines = append(lines, fmt.Sprintf("%s: %d/%d (верно %d из %d, минимум %d)",
+			mockScoreLabel(result, s.Section), s.Score, s.Max, s.Correct, s.Total, s.Min))
+	}
+	lines = append(lines, "", fmt.Sprintf("Итого: %d/%d, проходной балл %d", result.Total, result.Max, result.PassMark))
+	if result.Passed {
+		lines = append(lines, "Экзамен был бы сдан ✅")
+	} else {
+		lines = append(lines, "Экзамен пока не был бы сдан ❌")
+	}
+
+	var mistakes []string
+	for i, q := range attempt.Questions {
+		if i < len(attempt.Answers) && attempt.Answers[i] != q.Correct {
+			mistakes = append(mistakes, fmt.Sprintf("%d) %s", i+1, q.Options[q.Correct]))
+		}
+	}
+	if len(mistakes) > 0 {
+		lines = append(lines, "", "Правильные ответы на ошибки:")
+		lines = append(lines, mistakes...)
+	}
+
+	lines = append(lines, "", "История попыток: /mock history")
+	return strings.Join(lines, "\n")
+}
+
+func (h *handler) handleMockHistory(user *db.User, msg *telegram.SendMessageParams) {
+	attempts, err := h.db.GetMockAttempts(user.ID, defaultMockHistoryLimit)
+	if err != nil {
+		log.Printf("Failed to get mock attempts: %v", err)
+		msg.Text = "Ошибка при получении истории. Попробуй позже."
+		return
+	}
+
+	if len(attempts) == 0 {
+		msg.Text = "Ты еще не проходил пробный тест. Начни с /mock."
+		return
+	}
+
+	lines := []string{"Последние пробные тесты:"}
+	for _, a := range attempts {
+		if a.Result == nil {
+			continue
+		}
+		mark := "❌"
+		if a.Result.Passed {
+			mark = "✅"
+		}
+		lines = append(lines, fmt.Sprintf("• %s %s: %d/%d %s",
+			a.StartedAt.Format("02.01.2006"), a.Level, a.Result.Total, a.Result.Max, mark))
+	}
+	msg.Text = strings.Join(lines, "\n")
+}
+
+// handleMockCallback records the option picked for a question of a mock test
+// and sends the next question or the result. As on the exam, the user learns
+// what was right only at the end.
+func (h *handler) handleMockCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+	}
+	defer func() {
+		if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+			log.Printf("Failed to answer callback query: %v", err)
+		}
+	}()
+
+	parts := strings.Split(strings.TrimPrefix(query.Data, "mock:"), ":")
+	var attemptID int64
+	var index, option int
+	var err error
+	if len(parts) == 3 {
+		attemptID, err = strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			index, err = strconv.Atoi(parts[1])
+		}
+		if err == nil {
+			option, err = strconv.Atoi(parts[2])
+		}
+	}
+	if len(parts) != 3 || err != nil {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+
+	attempt, err := h.db.GetMockAttempt(user.ID, attemptID)
+	if errors.Is(err, db.ErrNotFound) {
+		msg.Text = "Тест не найден. Чтобы начать новый, используй /mock."
+		return
+	} else if err != nil {
+		log.Printf("Failed to get mock attempt: %v", err)
+		msg.Text = "Ошибка при сохранении ответа. Попробуй позже."
+		return
+	}
+
+	err = mock.Answer(&attempt, index, option, time.Now())
+	switch {
+	case errors.Is(err, mock.ErrFinished):
+		msg.Text = "Этот тест уже завершен. Чтобы начать новый, используй /mock."
+		return
+	case errors.Is(err, mock.ErrNotCurrent):
+		// a button of an earlier question, the current one is already sent
+		ack.Text = "На этот вопрос ответ уже есть."
+		return
+	case errors.Is(err, mock.ErrInvalidOption):
+		msg.Text = "Недопустимый ответ."
+		return
+	case err != nil && !errors.Is(err, mock.ErrExpired):
+		log.Printf("Failed to answer mock question: %v", err)
+		msg.Text = "Ошибка при сохранении ответа. Попробуй позже."
+		return
+	}
+
+	if err := h.db.UpdateMockAttempt(attempt); err != nil {
+		log.Printf("Failed to update mock attempt: %v", err)
+		msg.Text = "Ошибка при сохранении ответа. Попробуй позже."
+		return
+	}
+
+	switch {
+	case errors.Is(err, mock.ErrExpired):
+		ack.Text = "Время вышло"
+		msg.Text = fmt.Sprintf("Время вышло, ответ не засчитан.\n\n%s", mockResultText(attempt))
+	case attempt.FinishedAt != nil:
+		ack.Text = "Тест завершен"
+		msg.Text = mockResultText(attempt)
+	default:
+		ack.Text = "Ответ принят"
+		h.mockQuestion(user.TelegramID, attempt, msg)
+	}
+}
+
+type MockQuestionResponse struct {
+	Section  string   `json:"section"`
+	Stem     string   `json:"stem"`
+	HasAudio bool     `json:"has_audio"`
+	Options  []string `json:"options"`
+	// The answer, the right option and the text of the recording are shown
+	// once the attempt is finished.
+	Answer     *int   `json:"answer,omitempty"`
+	Correct    *int   `json:"correct,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+type MockAttemptResponse struct {
+	ID         int64                  `json:"id"`
+	Level      string                 `json:"level"`
+	StartedAt  time.Time              `json:"started_at"`
+	Deadline   time.Time              `json:"deadline"`
+	FinishedAt *time.Time             `json:"finished_at"`
+	Answered   int                    `json:"answered"`
+	Questions  []MockQuestionResponse `json:"questions"`
+	Result     *db.MockResult         `json:"result"`
+}
+
+type MockAttemptSummary struct {
+	ID         int64          `json:"id"`
+	Level      string         `json:"level"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at"`
+	Result     *db.MockResult `json:"result"`
+}
+
+type MockAnswerRequest struct {
+	Question int `json:"question"`
+	Option   int `json:"option"`
+}
+
+func newMockAttemptResponse(attempt db.MockAttempt) MockAttemptResponse {
+	resp := MockAttemptResponse{
+		ID:         attempt.ID,
+		Level:      attempt.Level,
+		StartedAt:  attempt.StartedAt,
+		Deadline:   attempt.Deadline,
+		FinishedAt: attempt.FinishedAt,
+		Answered:   len(attempt.Answers),
+		Questions:  make([]MockQuestionResponse, len(attempt.Questions)),
+		Result:     attempt.Result,
+	}
+	for i, q := range attempt.Questions {
+		qr := MockQuestionResponse{
+			Section:  q.Section,
+			Stem:     q.Stem,
+			HasAudio: q.Audio != "",
+			Options:  q.Options,
+		}
+		if attempt.FinishedAt != nil {
+			correct := q.Correct
+			qr.Correct = &correct
+			qr.Transcript = q.Audio
+			if i < len(attempt.Answers) {
+				answer := attempt.Answers[i]
+				qr.Answer = &answer
+			}
+		}
+		resp.Questions[i] = qr
+	}
+	return resp
+}
+
+func (h *handler) HandleMockHistory(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	limit := defaultMockHistoryLimit
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = parseIntQueryParam(limitStr); err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit query parameter")
+		}
+	}
+
+	attempts, err := h.db.GetMockAttempts(uid, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get mock attempts").SetInternal(err)
+	}
+
+	summaries := make([]MockAttemptSummary, len(attempts))
+	for i, a := range attempts {
+		summaries[i] = MockAttemptSummary{
+			ID:         a.ID,
+			Level:      a.Level,
+			StartedAt:  a.StartedAt,
+			FinishedAt: a.FinishedAt,
+			Result:     a.Result,
+		}
+	}
+
+	return c.JSON(http.StatusOK, summaries)
+}
+
+// HandleStartMock returns the unfinished attempt of the user or starts a new
+// one for the user's level.
+func (h *handler) HandleStartMock(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	attempt, expired, err := h.activeMock(uid)
+	if err == nil && !expired {
+		return c.JSON(http.StatusOK, newMockAttemptResponse(attempt))
+	} else if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get mock attempt").SetInternal(err)
+	}
+
+	user, err := h.db.GetUser(getChatID(c))
+	if err != nil && errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "user not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user").SetInternal(err)
+	}
+
+	attempt, err = h.startMock(uid, user.Level)
+	if err != nil && errors.Is(err, mock.ErrNoExercises) {
+		return echo.NewHTTPError(http.StatusConflict, "not enough exercises for a mock test")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start mock test").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusCreated, newMockAttemptResponse(attempt))
+}
+
+// getMockAttempt loads the attempt of the :id parameter, finishing it if its
+// time is over.
+func (h *handler) getMockAttempt(c echo.Context, uid int64) (db.MockAttempt, error) {
+	attemptID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return db.MockAttempt{}, echo.NewHTTPError(http.StatusBadRequest, "invalid mock attempt id")
+	}
+
+	attempt, err := h.db.GetMockAttempt(uid, attemptID)
+	if err != nil && errors.Is(err, db.ErrNotFound) {
+		return attempt, echo.NewHTTPError(http.StatusNotFound, "mock attempt not found")
+	} else if err != nil {
+		return attempt, echo.NewHTTPError(http.StatusInternalServerError, "failed to get mock attempt").SetInternal(err)
+	}
+
+	if now := time.Now(); mock.Expired(&attempt, now) {
+		mock.Finish(&attempt, now)
+		if err := h.db.UpdateMockAttempt(attempt); err != nil {
+			return attempt, echo.NewHTTPError(http.StatusInternalServerError, "failed to update mock attempt").SetInternal(err)
+		}
+	}
+	return attempt, nil
+}
+
+func (h *handler) HandleMockAttempt(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	attempt, err := h.getMockAttempt(c, uid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newMockAttemptResponse(attempt))
+}
+
+// HandleMockAnswer records the answer to the current question. An answer
+// after the time is over finishes the attempt without counting.
+func (h *handler) HandleMockAnswer(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	var req MockAnswerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to bind request")
+	}
+
+	attempt, err := h.getMockAttempt(c, uid)
+	if err != nil {
+		return err
+	}
+
+	err = mock.Answer(&attempt, req.Question, req.Option, time.Now())
+	if err != nil && errors.Is(err, mock.ErrFinished) {
+		return echo.NewHTTPError(http.StatusConflict, "mock attempt is finished")
+	} else if err != nil && errors.Is(err, mock.ErrNotCurrent) {
+		return echo.NewHTTPError(http.StatusConflict, "question is not the current one")
+	} else if err != nil && errors.Is(err, mock.ErrInvalidOption) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid option")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to answer question").SetInternal(err)
+	}
+
+	if err := h.db.UpdateMockAttempt(attempt); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update mock attempt").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, newMockAttemptResponse(attempt))
+}
+
+// HandleMockAudio streams the recording of a listening question.
+func (h *handler) HandleMockAudio(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	attempt, err := h.getMockAttempt(c, uid)
+	if err != nil {
+		return err
+	}
+
+	index, err := strconv.Atoi(c.Param("n"))
+	if err != nil || index < 0 || index >= len(attempt.Questions) || attempt.Questions[index].Audio == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "question audio not found")
+	}
+
+	data, _, err := h.loadAudio(h.openaiClient.SpeechParams(attempt.Questions[index].Audio))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get question audio").SetInternal(err)
+	}
+
+	return c.Blob(http.StatusOK, "audio/ogg", data)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/db"
+	"log"
+	"strconv"
+	"strings"
+)
+
+func multipleChoiceKeyboard(exerciseID int64, c db.MultipleChoiceContent) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range c.Options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, fmt.Sprintf("mc:%d:%d", exerciseID, i)),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// handleMultipleChoiceCallback checks the option the user picked. The answer
+// is known in advance, so no AI is involved. A multiple-choice exercise is
+// answered once: after a miss the right option is shown and the exercise is
+// finished.
+func (h *handler) handleMultipleChoiceCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+	}
+	defer func() {
+		if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+			log.Printf("Failed to answer callback query: %v", err)
+		}
+	}()
+
+	parts := strings.Split(strings.TrimPrefix(query.Data, "mc:"), ":")
+	var exerciseID int64
+	var index int
+	var err error
+	if len(parts) == 2 {
+		exerciseID, err = strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			index, err = strconv.Atoi(parts[1])
+		}
+	}
+	if len(parts) != 2 || err != nil {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+
+	if user.CurrentExerciseID == nil || *user.CurrentExerciseID != exerciseID {
+		msg.Text = "Это задание уже завершено. Чтобы получить новое, используй /task."
+		return
+	}
+
+	exercise, err := h.db.GetExerciseByID(exerciseID)
+	if err != nil {
+		log.Printf("Failed to get exercise: %v", err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	c, err := db.ContentAs[db.MultipleChoiceContent](exercise.Content)
+	if err != nil || index < 0 || index >= len(c.Options) || c.Correct < 0 || c.Correct >= len(c.Options) {
+		log.Printf("Invalid multiple choice answer %d for exercise %d: %v", index, exerciseID, err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	submission := db.Submission{
+		UserID:     user.TelegramID,
+		ExerciseID: exercise.ID,
+		UserInput:  c.Options[index],
+		IsCorrect:  index == c.Correct,
+	}
+
+	if submission.IsCorrect {
+		msg.Text = "Правильно! 🎉\n\nЧтобы получить новое задание, используй /task."
+		ack.Text = "Правильно!"
+		if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
+			log.Printf("Failed to update user ranking: %v", err)
+		}
+	} else {
+		msg.Text = fmt.Sprintf("Неправильно. Правильный ответ: %s\n\nЧтобы получить новое задание, используй /task.", c.Options[c.Correct])
+		ack.Text = "Неправильно"
+	}
+
+	if err := h.db.ClearUserExercise(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user exercise: %v", err)
+	}
+
+	if err := h.db.SaveSubmission(submission); err != nil {
+		log.Printf("Failed to save submission: %v", err)
+		msg.Text = "Ошибка при сохранении ответа."
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// pollTimeout is how long Telegram holds a getUpdates request open
+	// waiting for updates.
+	pollTimeout = 30 * time.Second
+
+	minPollBackoff = time.Second
+	maxPollBackoff = 30 * time.Second
+
+	// queueFullWait is the pause before an update is offered again to a full queue.
+	queueFullWait = 100 * time.Millisecond
+)
+
+type getUpdatesResponse struct {
+	OK          bool              `json:"ok"`
+	Result      []tgbotapi.Update `json:"result"`
+	Description string            `json:"description"`
+}
+
+// Poll gets updates with getUpdates instead of a webhook and hands them to the
+// same dispatcher, until the context is done. apiURL is the Bot API server,
+// https://api.telegram.org for Telegram itself.
+//
+// An update is confirmed to Telegram only after it is queued, so the ones
+// not queued yet when Poll stops are delivered again on the next start.
+func (h *handler) Poll(ctx context.Context, apiURL string) error {
+	// Telegram refuses getUpdates while a webhook is set, the updates waiting
+	// for it are kept and come with the first getUpdates
+	if _, err := h.bot.DeleteWebhook(ctx, &telegram.DeleteWebhookParams{DropPendingUpdates: false}); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	client := &http.Client{Timeout: pollTimeout + 10*time.Second}
+	endpoint := fmt.Sprintf("%s/bot%s/getUpdates", apiURL, h.botToken)
+
+	var offset int
+	var backoff time.Duration
+	for {
+		updates, err := getUpdates(ctx, client, endpoint, offset)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			backoff = min(max(backoff*2, minPollBackoff), maxPollBackoff)
+			log.Printf("Failed to get updates, retrying in %s: %v", backoff, err)
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			continue
+		}
+		backoff = 0
+
+		for _, update := range updates {
+			if update.Message != nil || update.CallbackQuery != nil {
+				if err := h.dispatchWaiting(ctx, update); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+			offset = update.UpdateID + 1
+		}
+	}
+}
+
+// dispatchWaiting queues the update, waiting while the queue is full.
+func (h *handler) dispatchWaiting(ctx context.Context, update tgbotapi.Update) error {
+	for {
+		err := h.dispatcher.Dispatch(update)
+		if !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+		if !sleep(ctx, queueFullWait) {
+			return ctx.Err()
+		}
+	}
+}
+
+func getUpdates(ctx context.Context, client *http.Client, endpoint string, offset int) ([]tgbotapi.Update, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(pollTimeout.Seconds()),
+		"allowed_updates": []string{"message", "callback_query"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding getUpdates params: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating getUpdates request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the URL holds the bot token, keep it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("error requesting updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result getUpdatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding updates: %w", err)
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("getUpdates failed with status %d: %s", resp.StatusCode, result.Description)
+	}
+	return result.Result, nil
+}
+
+// sleep waits for the duration, it returns false if the context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
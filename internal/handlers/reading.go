@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/db"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// readingQuestionText is a question of the passage with numbered options.
+func readingQuestionText(c db.ReadingContent, index int) string {
+	q := c.Questions[index]
+	lines := []string{fmt.Sprintf("Вопрос %d/%d: %s", index+1, len(c.Questions), q.Question), ""}
+	for i, option := range q.Options {
+		lines = append(lines, fmt.Sprintf("%d) %s", i+1, option))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readingPrompt is the passage with the question the user is at.
+func readingPrompt(c db.ReadingContent, index int) string {
+	return fmt.Sprintf("Задание:\n\nПрочитай текст и ответь на вопросы.\n\n%s\n\n%s", c.Passage, readingQuestionText(c, index))
+}
+
+func readingKeyboard(exerciseID int64, index int, q db.ReadingQuestion) *tgbotapi.InlineKeyboardMarkup {
+	var buttons []tgbotapi.InlineKeyboardButton
+	for i := range q.Options {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			strconv.Itoa(i+1),
+			fmt.Sprintf("rd:%d:%d:%d", exerciseID, index, i),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+	return &keyboard
+}
+
+// continueReading shows the passage again with the current question if the
+// user's current exercise is a reading one.
+func (h *handler) continueReading(user *db.User, msg *telegram.SendMessageParams) bool {
+	if user.CurrentExerciseID == nil || user.CurrentMode != db.ModeExercise {
+		return false
+	}
+
+	exercise, err := h.db.GetExerciseByID(*user.CurrentExerciseID)
+	if err != nil {
+		log.Printf("Failed to get exercise: %v", err)
+		return false
+	}
+	if exercise.Type != db.ExerciseTypeReading {
+		return false
+	}
+
+	c, err := db.ContentAs[db.ReadingContent](exercise.Content)
+	if err != nil || user.CurrentReadingQuestion >= len(c.Questions) {
+		log.Printf("Invalid reading position %d for exercise %d: %v", user.CurrentReadingQuestion, exercise.ID, err)
+		return false
+	}
+
+	msg.Text = readingPrompt(c, user.CurrentReadingQuestion)
+	msg.ReplyMarkup = readingKeyboard(exercise.ID, user.CurrentReadingQuestion, c.Questions[user.CurrentReadingQuestion])
+	return true
+}
+
+// handleReadingCallback grades the option picked for the current question of
+// a passage and asks the next one. After the last question the right answers
+// are counted into one submission.
+func (h *handler) handleReadingCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+	}
+	defer func() {
+		if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+			log.Printf("Failed to answer callback query: %v", err)
+		}
+	}()
+
+	parts := strings.Split(strings.TrimPrefix(query.Data, "rd:"), ":")
+	var exerciseID int64
+	var index, option int
+	var err error
+	if len(parts) == 3 {
+		exerciseID, err = strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			index, err = strconv.Atoi(parts[1])
+		}
+		if err == nil {
+			option, err = strconv.Atoi(parts[2])
+		}
+	}
+	if len(parts) != 3 || err != nil {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+
+	if user.CurrentExerciseID == nil || *user.CurrentExerciseID != exerciseID {
+		msg.Text = "Это задание уже завершено. Чтобы получить новое, используй /task."
+		return
+	}
+	if index != user.CurrentReadingQuestion {
+		ack.Text = "На этот вопрос ответ уже есть."
+		return
+	}
+
+	exercise, err := h.db.GetExerciseByID(exerciseID)
+	if err != nil {
+		log.Printf("Failed to get exercise: %v", err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	c, err := db.ContentAs[db.ReadingContent](exercise.Content)
+	if err != nil || index >= len(c.Questions) || option < 0 || option >= len(c.Questions[index].Options) {
+		log.Printf("Invalid reading answer %d:%d for exercise %d: %v", index, option, exerciseID, err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	q := c.Questions[index]
+	correct := user.CurrentReadingCorrect
+	var feedback string
+	if option == q.Correct {
+		correct++
+		feedback = "Правильно! 🎉"
+		ack.Text = "Правильно!"
+	} else {
+		feedback = fmt.Sprintf("Неправильно. Правильный ответ: %s", q.Options[q.Correct])
+		ack.Text = "Неправильно"
+	}
+
+	if next := index + 1; next < len(c.Questions) {
+		if err := h.db.MarkReadingAnswered(user.TelegramID, next, correct); err != nil {
+			log.Printf("Failed to save reading answer: %v", err)
+			msg.Text = "Ошибка при сохранении ответа."
+			return
+		}
+		msg.Text = fmt.Sprintf("%s\n\n%s", feedback, readingQuestionText(c, next))
+		msg.ReplyMarkup = readingKeyboard(exercise.ID, next, c.Questions[next])
+		return
+	}
+
+	submission := db.Submission{
+		UserID:     user.TelegramID,
+		ExerciseID: exercise.ID,
+		UserInput:  fmt.Sprintf("%d/%d", correct, len(c.Questions)),
+		IsCorrect:  correct == len(c.Questions),
+	}
+
+	msg.Text = fmt.Sprintf("%s\n\nРезультат: %d из %d.\n\nЧтобы получить новое задание, используй /task.", feedback, correct, len(c.Questions))
+
+	if err := h.db.ClearUserExercise(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user exercise: %v", err)
+	}
+
+	if correct > 0 {
+		if err := h.db.UpdateUserRanking(user.TelegramID, correct); err != nil {
+			log.Printf("Failed to update user ranking: %v", err)
+		}
+	}
+
+	if err := h.db.SaveSubmission(submission); err != nil {
+		log.Printf("Failed to save submission: %v", err)
+		msg.Text = "Ошибка при сохранении ответа."
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"jpbot/internal/db"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// The chunks picked so far travel in the callback data as a string of chunk
+// indices, "ro:<exerciseID>:<picked>", so no progress is stored. The undo
+// button drops the last index.
+
+// reorderShuffle is the order the chunk buttons are shown in. It depends only
+// on the exercise, so the buttons stay in place while the user taps them.
+func reorderShuffle(exerciseID int64, n int) []int {
+	order := rand.New(rand.NewSource(exerciseID)).Perm(n)
+	// shift a shuffle that happens to give the answer away
+	for i := range order {
+		if order[i] != i {
+			return order
+		}
+	}
+	return append(order[1:], order[0])
+}
+
+// parseReorderPicked returns the picked chunk indices, ok is false unless
+// they are distinct and within the chunks.
+func parseReorderPicked(picked string, n int) ([]int, bool) {
+	indices := make([]int, 0, len(picked))
+	seen := make(map[int]bool)
+	for _, r := range picked {
+		i := int(r - '0')
+		if i < 0 || i >= n || seen[i] {
+			return nil, false
+		}
+		seen[i] = true
+		indices = append(indices, i)
+	}
+	return indices, true
+}
+
+// freeParticles end argument chunks like 公園で or 友達と.
+var freeParticles = []string{"を", "に", "で", "へ", "と", "まで"}
+
+// freeChunks returns the run [from, to) of argument chunks right before the
+// predicate. Their order is free: 公園で友達とテニスをします and
+// 友達と公園でテニスをします are both right. The topic and the predicate stay
+// in place, and an argument after a の modifier is bound to it.
+func freeChunks(chunks []string) (from, to int) {
+	to = len(chunks) - 1
+	from = to
+	for from > 0 && hasParticleSuffix(chunks[from-1]) {
+		from--
+	}
+	if from > 0 && strings.HasSuffix(chunks[from-1], "の") {
+		from++
+	}
+	return from, to
+}
+
+func hasParticleSuffix(chunk string) bool {
+	for _, p := range freeParticles {
+		if strings.HasSuffix(chunk, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// reorderCorrect reports whether the picked chunks make the sentence. Chunks
+// are compared as text, so chunks like two の are interchangeable, and the
+// free chunks may come in any order.
+func reorderCorrect(chunks []string, picked []int) bool {
+	if len(picked) != len(chunks) {
+		return false
+	}
+	from, to := freeChunks(chunks)
+	for pos, i := range picked {
+		if chunks[i] == chunks[pos] {
+			continue
+		}
+		if pos >= from && pos < to && i >= from && i < to {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func reorderText(c db.ReorderContent, picked []int) string {
+	var sentence strings.Builder
+	for _, i := range picked {
+		sentence.WriteString(c.Chunks[i])
+	}
+	for range len(c.Chunks) - len(picked) {
+		sentence.WriteString("＿")
+	}
+	return fmt.Sprintf("Задание:\n\nСобери предложение: %s\n\n%s", c.Translation, sentence.String())
+}
+
+func reorderKeyboard(exerciseID int64, c db.ReorderContent, picked string) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, i := range reorderShuffle(exerciseID, len(c.Chunks)) {
+		index := strconv.Itoa(i)
+		if strings.Contains(picked, index) {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(c.Chunks[i], fmt.Sprintf("ro:%d:%s%s", exerciseID, picked, index)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	if picked != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⌫ Отменить", fmt.Sprintf("ro:%d:%s", exerciseID, picked[:len(picked)-1])),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// handleReorderCallback edits the exercise message with the chunks picked so
+// far. A finished sentence is checked locally, like multiple choice it is
+// answered once.
+func (h *handler) handleReorderCallback(user *db.User, query *tgbotapi.CallbackQuery, msg *telegram.SendMessageParams) {
+	ack := telegram.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+	}
+	defer func() {
+		if _, err := h.bot.AnswerCallbackQuery(context.Background(), &ack); err != nil {
+			log.Printf("Failed to answer callback query: %v", err)
+		}
+	}()
+
+	parts := strings.Split(strings.TrimPrefix(query.Data, "ro:"), ":")
+	if len(parts) != 2 {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+	exerciseID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+
+	if user.CurrentExerciseID == nil || *user.CurrentExerciseID != exerciseID {
+		msg.Text = "Это задание уже завершено. Чтобы получить новое, используй /task."
+		return
+	}
+
+	exercise, err := h.db.GetExerciseByID(exerciseID)
+	if err != nil {
+		log.Printf("Failed to get exercise: %v", err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	c, err := db.ContentAs[db.ReorderContent](exercise.Content)
+	if err != nil {
+		log.Printf("Failed to parse reorder exercise %d: %v", exerciseID, err)
+		msg.Text = "Ошибка при проверке задания."
+		return
+	}
+
+	picked, ok := parseReorderPicked(parts[1], len(c.Chunks))
+	if !ok {
+		msg.Text = "Недопустимый ответ."
+		return
+	}
+
+	edit := telegram.EditMessageTextParams{
+		Text: reorderText(c, picked),
+	}
+	if query.Message != nil {
+		edit.ChatID = query.Message.Chat.ID
+		edit.MessageID = query.Message.MessageID
+	}
+
+	if len(picked) < len(c.Chunks) {
+		edit.ReplyMarkup = reorderKeyboard(exerciseID, c, parts[1])
+		if _, err := h.bot.EditMessageText(context.Background(), &edit); err != nil {
+			log.Printf("Failed to edit reorder message: %v", err)
+		}
+		return
+	}
+
+	// the keyboard is removed once the sentence is complete
+	if _, err := h.bot.EditMessageText(context.Background(), &edit); err != nil {
+		log.Printf("Failed to edit reorder message: %v", err)
+	}
+
+	var answer strings.Builder
+	for _, i := range picked {
+		answer.WriteString(c.Chunks[i])
+	}
+
+	submission := db.Submission{
+		UserID:     user.TelegramID,
+		ExerciseID: exercise.ID,
+		UserInput:  answer.String(),
+		IsCorrect:  reorderCorrect(c.Chunks, picked),
+	}
+
+	if submission.IsCorrect {
+		msg.Text = "Правильно! 🎉\n\nЧтобы получить новое задание, используй /task."
+		ack.Text = "Правильно!"
+		if err := h.db.UpdateUserRanking(user.TelegramID, 1); err != nil {
+			log.Printf("Failed to update user ranking: %v", err)
+		}
+	} else {
+		msg.Text = fmt.Sprintf("Неправильно. Правильный порядок: %s\n\nЧтобы получить новое задание, используй /task.", strings.Join(c.Chunks, " / "))
+		ack.Text = "Неправильно"
+	}
+
+	if err := h.db.ClearUserExercise(user.TelegramID); err != nil {
+		log.Printf("Failed to clear user exercise: %v", err)
+	}
+
+	if err := h.db.SaveSubmission(submission); err != nil {
+		log.Printf("Failed to save submission: %v", err)
+		msg.Text = "Ошибка при сохранении ответа."
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import "testing"
+
+func TestReorderCorrect(t *testing.T) {
+	chunks := []string{"私は", "公園で", "友達と", "テニスを", "します。"}
+	modified := []string{"私は", "友達の", "家で", "本を", "読みます。"}
+	repeated := []string{"母の", "姉の", "本です。"}
+
+	tests := []struct {
+		name   string
+		chunks []string
+		picked []int
+		want   bool
+	}{
+		{"source order", chunks, []int{0, 1, 2, 3, 4}, true},
+		{"arguments swapped", chunks, []int{0, 2, 1, 3, 4}, true},
+		{"object first", chunks, []int{0, 3, 1, 2, 4}, true},
+		{"topic moved", chunks, []int{1, 0, 2, 3, 4}, false},
+		{"predicate moved", chunks, []int{0, 1, 2, 4, 3}, false},
+		{"argument bound to a modifier", modified, []int{0, 1, 3, 2, 4}, false},
+		{"modifier moved", modified, []int{0, 2, 1, 3, 4}, false},
+		{"source order with a modifier", modified, []int{0, 1, 2, 3, 4}, true},
+		{"same chunks swapped", []string{"母の", "母の", "本です。"}, []int{1, 0, 2}, true},
+		{"different modifiers swapped", repeated, []int{1, 0, 2}, false},
+		{"incomplete", chunks, []int{0, 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reorderCorrect(tt.chunks, tt.picked); got != tt.want {
+				t.Errorf("reorderCorrect(%q, %v) = %v, want %v", tt.chunks, tt.picked, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"jpbot/internal/ai/aitest"
+	"jpbot/internal/db"
+	"jpbot/internal/telegramtest"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replyTimeout is how long a scenario waits for the bot to call Telegram.
+const replyTimeout = 5 * time.Second
+
+// scenario is a conversation of a user with the bot through the webhook:
+//
+//	env.scenario(t, testUser).
+//		Sends("/task").Expects(`Переведи`).
+//		Sends("魚を食べます").Expects(`Правильно`)
+//
+// Every expectation looks at the calls made after the previous one, so the
+// replies are checked in the order the bot sends them.
+type scenario struct {
+	t    *testing.T
+	env  *testEnv
+	user telegramtest.User
+	// next is the index of the first call not looked at yet
+	next int
+	// last is the latest message expected, the one Presses looks for buttons in
+	last telegramtest.Call
+}
+
+func (e *testEnv) scenario(t *testing.T, user telegramtest.User) *scenario {
+	return &scenario{t: t, env: e, user: user, next: len(e.telegram.Calls())}
+}
+
+// Sends delivers a text message from the user.
+func (s *scenario) Sends(text string) *scenario {
+	s.t.Helper()
+	if err := s.env.telegram.Inject(s.env.telegram.Message(s.user, text)); err != nil {
+		s.t.Fatalf("sending %q: %v", text, err)
+	}
+	return s
+}
+
+// Presses presses the button with the label under the latest expected message.
+func (s *scenario) Presses(label string) *scenario {
+	s.t.Helper()
+	for _, b := range s.last.Buttons() {
+		if b.Text == label {
+			if err := s.env.telegram.Inject(s.env.telegram.Callback(s.user, s.last.MessageID, b.Data)); err != nil {
+				s.t.Fatalf("pressing %q: %v", label, err)
+			}
+			return s
+		}
+	}
+	s.t.Fatalf("no button %q under %q, buttons: %+v", label, s.last.Text(), s.last.Buttons())
+	return s
+}
+
+// Expects waits for a message to the user's chat and checks that it matches
+// the pattern.
+func (s *scenario) Expects(pattern string) *scenario {
+	s.t.Helper()
+	call := s.wait("message", func(c telegramtest.Call) bool {
+		return (c.Method == "sendMessage" || c.Method == "editMessageText") && c.ChatID() == s.user.ID
+	})
+	if !regexp.MustCompile(pattern).MatchString(call.Text()) {
+		s.t.Fatalf("got message %q, want one matching %q", call.Text(), pattern)
+	}
+	s.last = call
+	return s
+}
+
+// ExpectsAlert waits for the answer to a callback query and checks that its
+// text matches the pattern.
+func (s *scenario) ExpectsAlert(pattern string) *scenario {
+	s.t.Helper()
+	call := s.wait("callback query answer", func(c telegramtest.Call) bool {
+		return c.Method == "answerCallbackQuery"
+	})
+	if !regexp.MustCompile(pattern).MatchString(call.Text()) {
+		s.t.Fatalf("got callback query answer %q, want one matching %q", call.Text(), pattern)
+	}
+	return s
+}
+
+func (s *scenario) wait(what string, match func(telegramtest.Call) bool) telegramtest.Call {
+	s.t.Helper()
+	i, call := s.env.telegram.Wait(s.next, replyTimeout, match)
+	if i < 0 {
+		s.t.Fatalf("no %s in %s", what, replyTimeout)
+	}
+	s.next = i + 1
+	return call
+}
+
+func TestScenarioRegistration(t *testing.T) {
+	env := newTestEnv(t, nil, nil)
+
+	env.scenario(t, testUser).
+		Sends("/start").Expects(`Привет`).
+		Sends("/users").Expects(`Всего пользователей: 1`)
+
+	user := env.user(t)
+	if user.Level != db.LevelN5 || user.Username == nil || *user.Username != testUser.UserName {
+		t.Errorf("user is registered as %+v", user)
+	}
+}
+
+func TestScenarioLevelChange(t *testing.T) {
+	n4 := exercise(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "駅はどこですか。", Russian: "Где станция?"})
+	n4.Level = db.LevelN4
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."}),
+		n4,
+	}, nil)
+
+	env.scenario(t, testUser).
+		Sends("/level").Expects(`Выбери уровень`).
+		Presses("N4").ExpectsAlert(`Уровень обновлен на N4`).Expects(`Уровень обновлен на N4`).
+		Sends("/task").Expects(`Переведи: Где станция\?`)
+}
+
+func TestScenarioExerciseLoop(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."}),
+		exercise(t, db.ExerciseTypeAudio, db.AudioContent{Text: "毎朝コーヒーを飲みます。", Question: "何を飲みますか？"}),
+	}, nil)
+	env.ai.On(
+		aitest.Rule{Contains: "魚を食べた", Reply: `{"score": 30, "feedback": "Не то время", "suggestion": "魚を食べます。"}`},
+		aitest.Rule{Reply: `{"score": 100, "feedback": "Отлично", "suggestion": ""}`},
+	)
+
+	// the exercises come in random order
+	s := env.scenario(t, testUser)
+	for i := 0; i < 2; i++ {
+		s.Sends("/task").Expects(`Переведи: Я ем рыбу\.|何を飲みますか？`)
+		if strings.Contains(s.last.Text(), "何を飲みますか？") {
+			s.Sends("コーヒー").Expects(`Правильно`)
+		} else {
+			s.Sends("魚を食べた").Expects(`(?s)Неправильно.*Не то время`).
+				Sends("魚を食べます").Expects(`Правильно`)
+		}
+	}
+	s.Sends("/task").Expects(`закончились`)
+
+	if voices := env.telegram.CallsOf("sendVoice"); len(voices) != 1 {
+		t.Errorf("got %d voice messages, want 1 for the audio exercise", len(voices))
+	}
+}
+
+func TestScenarioVocabLoop(t *testing.T) {
+	water := db.Word{Kana: "みず", Translation: "вода", Level: db.LevelN5}
+	env := newTestEnv(t, nil, []db.Word{fish(), water})
+	env.production(t)
+	env.ai.On(aitest.Rule{Reply: `{"score": 20, "comment": "Это другое слово"}`})
+
+	// the words come in random order, each right answer brings the next one
+	s := env.scenario(t, testUser).Sends("/vocab").Expects(`Переведи слово: \*(рыба|вода)\*`)
+	prompt := s.last.Text()
+	for i := 0; i < 2; i++ {
+		if strings.Contains(prompt, "вода") {
+			s.Sends("みず").Expects(`Правильно`)
+			prompt = s.last.Text()
+			s.Presses("Легко").ExpectsAlert(`Оценка сохранена: Легко`).Expects(`Оценка сохранена`)
+		} else {
+			s.Sends("肉").Expects(`Это другое слово`).
+				Sends("/answer").Expects(`魚 \(さかな\)`).
+				Presses("Не вспомнил").ExpectsAlert(`Оценка сохранена: Не вспомнил`).Expects(`Оценка сохранена`).
+				Sends("さかな").Expects(`Правильно`)
+			prompt = s.last.Text()
+		}
+	}
+
+	if !strings.Contains(prompt, "закончились") {
+		t.Errorf("got %q after the last word, want no more words", prompt)
+	}
+}
+
+func TestScenarioRedelivery(t *testing.T) {
+	env := newTestEnv(t, nil, nil)
+	s := env.scenario(t, testUser)
+
+	// Telegram delivers an update again when the webhook is slow to answer
+	update := env.telegram.Message(testUser, "/users")
+	for i := 0; i < 2; i++ {
+		if err := env.telegram.Inject(update); err != nil {
+			t.Fatalf("failed to deliver update: %v", err)
+		}
+	}
+
+	// the next message is answered right after the first delivery
+	s.Sends("/start").Expects(`Всего пользователей`).Expects(`Привет`)
+}
+
+func TestScenarioPolling(t *testing.T) {
+	env := newTestEnv(t, []db.Exercise{
+		exercise(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."}),
+	}, nil)
+	s := env.scenario(t, testUser)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	polled := make(chan error, 1)
+	go func() { polled <- env.h.Poll(ctx, env.telegram.URL) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-polled; err != nil {
+			t.Errorf("Poll: %v", err)
+		}
+	})
+
+	// the updates are sent once the bot is polling instead of using the webhook
+	s.wait("getUpdates", func(c telegramtest.Call) bool { return c.Method == "getUpdates" })
+	if url := env.telegram.WebhookURL(); url != "" {
+		t.Fatalf("webhook %s is left set", url)
+	}
+	for _, c := range env.telegram.CallsOf("deleteWebhook") {
+		if c.Params["drop_pending_updates"] == "true" {
+			t.Fatal("the updates waiting for the webhook are dropped")
+		}
+	}
+
+	s.Sends("/start").Expects(`Привет`).
+		Sends("/task").Expects(`Переведи: Я ем рыбу\.`).
+		Sends("魚を食べます").Expects(`Правильно`)
+}
+
+func TestGetUpdatesErrorHidesToken(t *testing.T) {
+	tg := telegramtest.NewServer()
+	tg.Close()
+
+	client := &http.Client{Timeout: time.Second}
+	_, err := getUpdates(context.Background(), client, tg.URL+"/bot"+telegramtest.Token+"/getUpdates", 0)
+	if err == nil {
+		t.Fatal("getUpdates succeeded with the server closed")
+	}
+	if strings.Contains(err.Error(), telegramtest.Token) {
+		t.Errorf("error %q contains the bot token", err)
+	}
+}
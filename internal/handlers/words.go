@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"jpbot/internal/db"
+	"jpbot/internal/furigana"
+	"net/http"
+	"strconv"
+)
+
+const defaultHistoryLimit = 100
+
+type WordHistoryResponse struct {
+	Word    db.Word             `json:"word"`
+	Reviews []db.ReviewLogEntry `json:"reviews"`
+	// ExamplesHTML are the example sentences with <ruby> furigana according to the user's setting.
+	ExamplesHTML []string `json:"examples_html"`
+}
+
+func (h *handler) HandleWordHistory(c echo.Context) error {
+	uid := getUserID(c)
+	if uid == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "auth is invalid")
+	}
+
+	wordID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid word id")
+	}
+
+	limit := defaultHistoryLimit
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if limit, err = parseIntQueryParam(limitStr); err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit query parameter")
+		}
+	}
+
+	word, err := h.db.GetWordByID(wordID)
+	if err != nil && errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "word not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get word").SetInternal(err)
+	}
+
+	reviews, err := h.db.GetReviewLog(uid, wordID, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get review history").SetInternal(err)
+	}
+
+	mode := db.FuriganaModeUnknown
+	if user, err := h.db.GetUser(getChatID(c)); err == nil {
+		mode = user.FuriganaMode
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user").SetInternal(err)
+	}
+
+	filter := h.furiganaFilter(uid, mode)
+	examples := make([]string, len(word.Examples))
+	for i, example := range word.Examples {
+		examples[i] = furigana.Render(example.Sentence, furigana.FormatRuby, filter)
+	}
+
+	return c.JSON(http.StatusOK, WordHistoryResponse{
+		Word:         word,
+		Reviews:      reviews,
+		ExamplesHTML: examples,
+	})
+}
@@ -0,0 +1,103 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"jpbot/internal/db"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	wordAudioBatchSize = 50
+	// wordAudioDelay spaces out TTS requests to stay within the API rate limits.
+	wordAudioDelay = time.Second
+)
+
+// wordAudioText is the text of a word's pronunciation clip: the reading
+// followed by the first example sentence.
+func wordAudioText(word db.Word) string {
+	text := word.Kana
+	if len(word.Examples) > 0 {
+		var sentence strings.Builder
+		for _, s := range word.Examples[0].Sentence {
+			sentence.WriteString(s.Fragment)
+		}
+		text = fmt.Sprintf("%s。%s", text, sentence.String())
+	}
+	return text
+}
+
+// generateWordAudio stores the word's pronunciation unless the store
+// already has the same clip, e.g. for a homonym.
+func (j *job) generateWordAudio(word db.Word) error {
+	params := j.openaiClient.SpeechParams(wordAudioText(word))
+	key := params.Key()
+
+	if cached, err := j.audioStore.Get(key); err == nil {
+		cached.Close()
+	} else {
+		generated, used, err := j.openaiClient.GenerateAudio(params)
+		if err != nil {
+			return err
+		}
+		defer generated.Close()
+
+		key = used.Key()
+		if err := j.audioStore.Put(key, generated); err != nil {
+			return fmt.Errorf("failed to store audio: %w", err)
+		}
+	}
+
+	return j.db.SetWordAudioURL(word.ID, key)
+}
+
+// SetWordAudioLimit sets how many words get pronunciation audio on each
+// start. TTS is paid, so no audio is generated unless it is set.
+func (j *job) SetWordAudioLimit(limit int) {
+	j.wordAudioLimit = limit
+}
+
+// syncWordAudio generates pronunciation audio for up to the word audio limit
+// of words that don't have it yet. The audio store key is recorded as the
+// word's audio_url.
+func (j *job) syncWordAudio(ctx context.Context) error {
+	if j.wordAudioLimit <= 0 {
+		log.Printf("Word audio is off, set word_audio_limit to generate it")
+		return nil
+	}
+
+	for remaining := j.wordAudioLimit; remaining > 0; {
+		words, err := j.db.GetWordsWithoutAudio(min(remaining, wordAudioBatchSize))
+		if err != nil {
+			return err
+		}
+		if len(words) == 0 {
+			return nil
+		}
+		remaining -= len(words)
+
+		generated := 0
+		for _, word := range words {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wordAudioDelay):
+			}
+
+			if err := j.generateWordAudio(word); err != nil {
+				log.Printf("Failed to generate audio for word %d: %v", word.ID, err)
+				continue
+			}
+			generated++
+		}
+
+		// every word of the batch failed, retrying right away would fail again
+		if generated == 0 {
+			return fmt.Errorf("failed to generate audio for %d words", len(words))
+		}
+		log.Printf("Generated audio for %d words", generated)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package job
+
+import (
+	"jpbot/internal/db"
+	"math/rand"
+	"strings"
+)
+
+// particles are the particle fragments of example sentences.
+var particles = map[string]bool{
+	"は": true, "が": true, "を": true, "に": true, "で": true, "へ": true, "と": true,
+	"も": true, "の": true, "から": true, "まで": true, "より": true, "や": true,
+}
+
+func isParticle(s db.Sentence) bool {
+	return s.Furigana == nil && particles[s.Fragment]
+}
+
+// clozeExercises makes up to two exercises from every example of a word: one
+// with a particle blanked out and one with the word itself.
+func clozeExercises(words []db.Word) []interface{} {
+	var exercises []interface{}
+	for _, word := range words {
+		for _, example := range word.Examples {
+			if c, ok := particleCloze(example); ok {
+				exercises = append(exercises, c)
+			}
+			if c, ok := wordCloze(word, example); ok {
+				exercises = append(exercises, c)
+			}
+		}
+	}
+	return exercises
+}
+
+// particleCloze blanks out a random particle of the sentence.
+func particleCloze(example db.Example) (db.ClozeContent, bool) {
+	var blanks []int
+	for i, s := range example.Sentence {
+		if isParticle(s) {
+			blanks = append(blanks, i)
+		}
+	}
+	if len(blanks) == 0 {
+		return db.ClozeContent{}, false
+	}
+
+	i := blanks[rand.Intn(len(blanks))]
+	return clozeContent(example, i, i+1, db.ClozeHintParticle), true
+}
+
+// wordCloze blanks out the fragments the word is written with.
+func wordCloze(word db.Word, example db.Example) (db.ClozeContent, bool) {
+	spelling := word.Kana
+	if word.Kanji != nil && *word.Kanji != "" {
+		spelling = *word.Kanji
+	}
+
+	for start := range example.Sentence {
+		var text strings.Builder
+		for end := start; end < len(example.Sentence) && text.Len() < len(spelling); end++ {
+			text.WriteString(example.Sentence[end].Fragment)
+			if text.String() == spelling {
+				return clozeContent(example, start, end+1, word.Translation), true
+			}
+		}
+	}
+
+	return db.ClozeContent{}, false
+}
+
+// clozeContent replaces the fragments from start to end with a blank.
+func clozeContent(example db.Example, start, end int, hint string) db.ClozeContent {
+	var answer, reading strings.Builder
+	for _, s := range example.Sentence[start:end] {
+		answer.WriteString(s.Fragment)
+		if s.Furigana != nil {
+			reading.WriteString(*s.Furigana)
+		} else {
+			reading.WriteString(s.Fragment)
+		}
+	}
+
+	sentence := make([]db.Sentence, 0, len(example.Sentence)-(end-start)+1)
+	sentence = append(sentence, example.Sentence[:start]...)
+	sentence = append(sentence, db.Sentence{Fragment: db.ClozeBlank})
+	sentence = append(sentence, example.Sentence[end:]...)
+
+	answers := []string{answer.String()}
+	if reading.String() != answer.String() {
+		answers = append(answers, reading.String())
+	}
+
+	return db.ClozeContent{
+		Sentence:    sentence,
+		Translation: example.Translation,
+		Hint:        hint,
+		Answers:     answers,
+	}
+}
@@ -3,8 +3,11 @@ package job
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	telegram "github.com/go-telegram/bot"
+	"io"
+	"jpbot/internal/audio"
 	"jpbot/internal/db"
 	"log"
 	"os"
@@ -17,19 +20,34 @@ type Storager interface {
 	GetExercisesByLevel(level string) ([]db.Exercise, error)
 	GetExercisesByLevelAndType(level, exType string) ([]db.Exercise, error)
 	CountUnsolvedExercisesForUser(userID int64, level string) (int, error)
-	IsExercisesInitialized() (bool, error)
 	IsWordsInitialized() (bool, error)
+	GetWordsWithoutAudio(limit int) ([]db.Word, error)
+	SetWordAudioURL(wordID int64, audioURL string) error
+	GetWordsByLevel(level string) ([]db.Word, error)
+	IsKanjiInitialized() (bool, error)
+	SaveKanjiBatch(kanji []db.Kanji) error
+}
+
+type OpenAIClient interface {
+	SpeechParams(text string) audio.Params
+	GenerateAudio(params audio.Params) (io.ReadCloser, audio.Params, error)
 }
 
 type job struct {
-	bot *telegram.Bot
-	db  Storager
+	bot          *telegram.Bot
+	db           Storager
+	openaiClient OpenAIClient
+	audioStore   audio.Store
+
+	wordAudioLimit int
 }
 
-func NewJob(db Storager, bot *telegram.Bot) *job {
+func NewJob(db Storager, bot *telegram.Bot, openaiClient OpenAIClient, audioStore audio.Store) *job {
 	return &job{
-		bot: bot,
-		db:  db,
+		bot:          bot,
+		db:           db,
+		openaiClient: openaiClient,
+		audioStore:   audioStore,
 	}
 }
 
@@ -121,6 +139,10 @@ type exerciseFile struct {
 	filePath    string
 	exType      string
 	contentType interface{}
+	// optional files may be missing for some levels
+	optional bool
+	// unusable reports content that can't be sent as an exercise
+	unusable func(content json.RawMessage) bool
 }
 
 func (j *job) processExerciseFile(level string, ef exerciseFile) ([]db.Exercise, error) {
@@ -142,6 +164,10 @@ func (j *job) processExerciseFile(level string, ef exerciseFile) ([]db.Exercise,
 			log.Printf("Failed to marshal content for %v: %v", raw, err)
 			continue
 		}
+		if ef.unusable != nil && ef.unusable(contentJSON) {
+			log.Printf("Skipping unusable %s exercise in %s", ef.exType, ef.filePath)
+			continue
+		}
 
 		exercises = append(exercises, db.Exercise{
 			Level:   strings.ToUpper(level),
@@ -194,16 +220,56 @@ func (j *job) syncExercises() error {
 				Example   string `json:"example"`
 			}{},
 		},
+		{
+			filePath: "materials/shadowing_%s.json",
+			exType:   db.ExerciseTypeShadowing,
+			contentType: struct {
+				Text    string `json:"text"`
+				Reading string `json:"reading"`
+			}{},
+			optional: true,
+		},
+		{
+			filePath: "materials/reading_%s.json",
+			exType:   db.ExerciseTypeReading,
+			contentType: struct {
+				Passage   string `json:"passage"`
+				Questions []struct {
+					Question string   `json:"question"`
+					Options  []string `json:"options"`
+					Correct  int      `json:"correct"`
+				} `json:"questions"`
+			}{},
+			optional: true,
+			unusable: func(content json.RawMessage) bool {
+				c, err := db.ContentAs[db.ReadingContent](content)
+				return err != nil || len(c.Questions) == 0
+			},
+		},
 	}
 
 	var allExercises []db.Exercise
 	for _, level := range levels {
 		for _, ef := range exerciseFiles {
+			// exercise types added later are loaded into an existing database
+			existing, err := j.db.GetExercisesByLevelAndType(level, ef.exType)
+			if err != nil {
+				return fmt.Errorf("failed to get %s exercises: %w", ef.exType, err)
+			}
+			if len(existing) > 0 {
+				continue
+			}
+
 			filePath := fmt.Sprintf(ef.filePath, strings.ToLower(level))
+			if _, err := os.Stat(filePath); ef.optional && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
 			exercises, err := j.processExerciseFile(level, exerciseFile{
 				filePath:    filePath,
 				exType:      ef.exType,
 				contentType: ef.contentType,
+				unusable:    ef.unusable,
 			})
 			if err != nil {
 				return err
@@ -215,18 +281,51 @@ func (j *job) syncExercises() error {
 	return nil
 }
 
-func (j *job) Run(ctx context.Context) {
-	isExercisesInitialized, err := j.db.IsExercisesInitialized()
-	if err != nil {
-		log.Printf("Failed to check if exercises are initialized: %v", err)
-		return
-	}
+// syncGeneratedExercises saves exercises built from the vocabulary for the
+// levels that have none of the type yet.
+func (j *job) syncGeneratedExercises(exType string, generate func(words []db.Word) []interface{}) error {
+	for _, level := range []string{db.LevelN5, db.LevelN4, db.LevelN3} {
+		existing, err := j.db.GetExercisesByLevelAndType(level, exType)
+		if err != nil {
+			return fmt.Errorf("failed to get %s exercises: %w", exType, err)
+		}
+		if len(existing) > 0 {
+			continue
+		}
 
-	if !isExercisesInitialized {
-		log.Println("Exercises not initialized. Syncing...")
-		if err := j.syncExercises(); err != nil {
-			log.Printf("Failed to sync exercises: %v", err)
+		words, err := j.db.GetWordsByLevel(level)
+		if err != nil {
+			return fmt.Errorf("failed to get words: %w", err)
 		}
+
+		var exercises []db.Exercise
+		for _, content := range generate(words) {
+			contentJSON, err := json.Marshal(content)
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s exercise: %w", exType, err)
+			}
+			exercises = append(exercises, db.Exercise{
+				Level:   level,
+				Type:    exType,
+				Content: contentJSON,
+			})
+		}
+		if len(exercises) == 0 {
+			continue
+		}
+
+		if err := j.db.SaveTasksBatch(exercises); err != nil {
+			return fmt.Errorf("failed to save %s exercises: %w", exType, err)
+		}
+		log.Printf("Saved %d %s exercises for level %s", len(exercises), exType, level)
+	}
+
+	return nil
+}
+
+func (j *job) Run(ctx context.Context) {
+	if err := j.syncExercises(); err != nil {
+		log.Printf("Failed to sync exercises: %v", err)
 	}
 
 	wordInitialized, err := j.db.IsWordsInitialized()
@@ -241,4 +340,24 @@ func (j *job) Run(ctx context.Context) {
 			log.Printf("Failed to sync words: %v", err)
 		}
 	}
+
+	if err := j.syncKanji(); err != nil {
+		log.Printf("Failed to sync kanji: %v", err)
+	}
+
+	if err := j.syncGeneratedExercises(db.ExerciseTypeMultipleChoice, multipleChoiceExercises); err != nil {
+		log.Printf("Failed to sync multiple choice exercises: %v", err)
+	}
+
+	if err := j.syncGeneratedExercises(db.ExerciseTypeCloze, clozeExercises); err != nil {
+		log.Printf("Failed to sync cloze exercises: %v", err)
+	}
+
+	if err := j.syncGeneratedExercises(db.ExerciseTypeReorder, reorderExercises); err != nil {
+		log.Printf("Failed to sync reorder exercises: %v", err)
+	}
+
+	if err := j.syncWordAudio(ctx); err != nil {
+		log.Printf("Failed to sync word audio: %v", err)
+	}
 }
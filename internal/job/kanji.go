@@ -0,0 +1,149 @@
+package job
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"jpbot/internal/db"
+	"log"
+	"os"
+)
+
+const kanjiFilePath = "materials/kanjidic2.xml"
+
+// kanjidicCharacter is a <character> entry of KANJIDIC2.
+type kanjidicCharacter struct {
+	Literal  string `xml:"literal"`
+	Radicals []struct {
+		Type  string `xml:"rad_type,attr"`
+		Value int    `xml:",chardata"`
+	} `xml:"radical>rad_value"`
+	StrokeCounts []int `xml:"misc>stroke_count"`
+	JLPT         int   `xml:"misc>jlpt"`
+	Readings     []struct {
+		Type  string `xml:"r_type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"reading_meaning>rmgroup>reading"`
+	Meanings []struct {
+		Lang  string `xml:"m_lang,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"reading_meaning>rmgroup>meaning"`
+}
+
+// kanjidicLevels maps the levels of the old four-level JLPT used by
+// KANJIDIC2 to the current ones. The old level 2 covers both N3 and N2.
+var kanjidicLevels = map[int]string{
+	4: db.LevelN5,
+	3: db.LevelN4,
+	2: db.LevelN3,
+	1: db.LevelN1,
+}
+
+func (c kanjidicCharacter) toKanji() db.Kanji {
+	k := db.Kanji{
+		Character:   c.Literal,
+		Level:       kanjidicLevels[c.JLPT],
+		OnReadings:  []string{},
+		KunReadings: []string{},
+		Meanings:    []string{},
+		Radicals:    []int{},
+	}
+	if len(c.StrokeCounts) > 0 {
+		// the first count is the accepted one, the rest are common miscounts
+		k.StrokeCount = c.StrokeCounts[0]
+	}
+	for _, r := range c.Radicals {
+		if r.Type == "classical" {
+			k.Radicals = append(k.Radicals, r.Value)
+		}
+	}
+	for _, r := range c.Readings {
+		switch r.Type {
+		case "ja_on":
+			k.OnReadings = append(k.OnReadings, r.Value)
+		case "ja_kun":
+			k.KunReadings = append(k.KunReadings, r.Value)
+		}
+	}
+
+	// Russian meanings are preferred when the file has them, English ones
+	// have no m_lang attribute
+	var english []string
+	for _, m := range c.Meanings {
+		switch m.Lang {
+		case "ru":
+			k.Meanings = append(k.Meanings, m.Value)
+		case "", "en":
+			english = append(english, m.Value)
+		}
+	}
+	if len(k.Meanings) == 0 {
+		k.Meanings = append(k.Meanings, english...)
+	}
+
+	return k
+}
+
+// parseKanjidic reads the kanji of a KANJIDIC2 file one <character> at a time.
+func parseKanjidic(r io.Reader) ([]db.Kanji, error) {
+	decoder := xml.NewDecoder(r)
+	var kanji []db.Kanji
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kanji XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "character" {
+			continue
+		}
+
+		var c kanjidicCharacter
+		if err := decoder.DecodeElement(&c, &start); err != nil {
+			return nil, fmt.Errorf("failed to decode kanji: %w", err)
+		}
+		if c.Literal == "" {
+			continue
+		}
+		kanji = append(kanji, c.toKanji())
+	}
+	return kanji, nil
+}
+
+// syncKanji imports the kanji table from KANJIDIC2 if the file is in
+// materials. The file is not required, the lookup then lists words only.
+func (j *job) syncKanji() error {
+	initialized, err := j.db.IsKanjiInitialized()
+	if err != nil {
+		return err
+	}
+	if initialized {
+		return nil
+	}
+
+	file, err := os.Open(kanjiFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No %s, skipping kanji import", kanjiFilePath)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	kanji, err := parseKanjidic(file)
+	if err != nil {
+		return err
+	}
+
+	if err := j.db.SaveKanjiBatch(kanji); err != nil {
+		return fmt.Errorf("failed to save kanji: %w", err)
+	}
+
+	log.Printf("Saved %d kanji", len(kanji))
+	return nil
+}
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"fmt"
+	"jpbot/internal/db"
+	"math/rand"
+	"strings"
+)
+
+// multipleChoiceDistractors is the number of wrong options next to the right one.
+const multipleChoiceDistractors = 3
+
+// wordSense is the first meaning of a word's translation.
+func wordSense(word db.Word) string {
+	senses := strings.FieldsFunc(word.Translation, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+	if len(senses) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(senses[0])
+}
+
+// wordSpelling is the word as it is usually written.
+func wordSpelling(word db.Word) string {
+	if word.Kanji != nil && *word.Kanji != "" {
+		return *word.Kanji
+	}
+	return word.Kana
+}
+
+// multipleChoiceExercise asks for the meaning of a word, or for the word by
+// its meaning when reverse is set. Distractors are words of the same level and
+// part of speech so the right answer can't be guessed by its form. ok is false
+// when there are not enough of them.
+func multipleChoiceExercise(word db.Word, candidates []db.Word, reverse bool) (db.MultipleChoiceContent, bool) {
+	option := wordSense
+	stem := fmt.Sprintf("Что значит «%s»?", wordSpelling(word))
+	if reverse {
+		option = wordSpelling
+		stem = fmt.Sprintf("Как сказать «%s» по-японски?", wordSense(word))
+	}
+
+	answer := option(word)
+	if answer == "" {
+		return db.MultipleChoiceContent{}, false
+	}
+	seen := map[string]bool{answer: true, wordSense(word): true, word.Kana: true}
+	options := []string{answer}
+	for _, i := range rand.Perm(len(candidates)) {
+		c := candidates[i]
+		if c.ID == word.ID || c.PartOfSpeech != word.PartOfSpeech || option(c) == "" {
+			continue
+		}
+		// a synonym or homonym would be a second right answer
+		if seen[option(c)] || seen[wordSense(c)] || seen[c.Kana] {
+			continue
+		}
+		seen[option(c)], seen[wordSense(c)], seen[c.Kana] = true, true, true
+		options = append(options, option(c))
+		if len(options) > multipleChoiceDistractors {
+			break
+		}
+	}
+	if len(options) <= multipleChoiceDistractors {
+		return db.MultipleChoiceContent{}, false
+	}
+
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	correct := 0
+	for i, o := range options {
+		if o == answer {
+			correct = i
+		}
+	}
+
+	return db.MultipleChoiceContent{
+		Stem:    stem,
+		Options: options,
+		Correct: correct,
+	}, true
+}
+
+// multipleChoiceExercises asks for the meaning of every other word of a level
+// and for the word by its meaning for the rest.
+func multipleChoiceExercises(words []db.Word) []interface{} {
+	var exercises []interface{}
+	for i, word := range words {
+		if content, ok := multipleChoiceExercise(word, words, i%2 == 1); ok {
+			exercises = append(exercises, content)
+		}
+	}
+	return exercises
+}
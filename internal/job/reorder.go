@@ -0,0 +1,57 @@
+package job
+
+import (
+	"jpbot/internal/db"
+	"strings"
+)
+
+const (
+	reorderMinChunks = 3
+	// reorderMaxChunks keeps the picked order short enough for callback data.
+	reorderMaxChunks = 8
+)
+
+// reorderChunks splits a sentence into chunks that end with a particle, the
+// way it is done in the JLPT sentence rearrangement section.
+func reorderChunks(sentence []db.Sentence) []string {
+	var chunks []string
+	var chunk strings.Builder
+	for _, s := range sentence {
+		chunk.WriteString(s.Fragment)
+		if isParticle(s) {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+// reorderExercises makes an exercise from every distinct example sentence
+// that splits into a suitable number of chunks.
+func reorderExercises(words []db.Word) []interface{} {
+	var exercises []interface{}
+	seen := make(map[string]bool)
+	for _, word := range words {
+		for _, example := range word.Examples {
+			chunks := reorderChunks(example.Sentence)
+			if len(chunks) < reorderMinChunks || len(chunks) > reorderMaxChunks {
+				continue
+			}
+
+			sentence := strings.Join(chunks, "")
+			if seen[sentence] {
+				continue
+			}
+			seen[sentence] = true
+
+			exercises = append(exercises, db.ReorderContent{
+				Chunks:      chunks,
+				Translation: example.Translation,
+			})
+		}
+	}
+	return exercises
+}
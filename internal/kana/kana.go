@@ -0,0 +1,415 @@
+// Package kana converts between hiragana, katakana and romaji.
+//
+// Romaji input may be written in Hepburn or Kunrei-shiki, with long vowels
+// as macrons (ō), circumflexes (ô) or doubled vowels, ん as "n", "n'", "nn"
+// or "m" before b, m and p, and っ as a doubled consonant or "tch". The IME
+// spellings of small kana like "xtsu" and "la" are not accepted, so that
+// latin words don't turn into kana.
+package kana
+
+import (
+	"strings"
+	"unicode"
+)
+
+const hiraganaKatakanaOffset = 'ァ' - 'ぁ'
+
+func IsHiragana(r rune) bool {
+	return r >= 'ぁ' && r <= 'ゖ'
+}
+
+func IsKatakana(r rune) bool {
+	return r >= 'ァ' && r <= 'ヺ'
+}
+
+// IsKana reports whether r is a hiragana or katakana letter or the long vowel mark.
+func IsKana(r rune) bool {
+	return IsHiragana(r) || IsKatakana(r) || r == 'ー'
+}
+
+func IsKanji(r rune) bool {
+	return unicode.Is(unicode.Han, r) || r == '々'
+}
+
+// ToHiragana replaces katakana letters with hiragana, other characters are kept.
+func ToHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - hiraganaKatakanaOffset
+		}
+		return r
+	}, s)
+}
+
+// ToKatakana replaces hiragana letters with katakana, other characters are kept.
+func ToKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + hiraganaKatakanaOffset
+		}
+		return r
+	}, s)
+}
+
+// hepburn lists the modified Hepburn spelling of every kana, it is used in both directions.
+var hepburn = map[string]string{
+	"a": "あ", "i": "い", "u": "う", "e": "え", "o": "お",
+	"ka": "か", "ki": "き", "ku": "く", "ke": "け", "ko": "こ",
+	"ga": "が", "gi": "ぎ", "gu": "ぐ", "ge": "げ", "go": "ご",
+	"sa": "さ", "shi": "し", "su": "す", "se": "せ", "so": "そ",
+	"za": "ざ", "ji": "じ", "zu": "ず", "ze": "ぜ", "zo": "ぞ",
+	"ta": "た", "chi": "ち", "tsu": "つ", "te": "て", "to": "と",
+	"da": "だ", "de": "で", "do": "ど",
+	"na": "な", "ni": "に", "nu": "ぬ", "ne": "ね", "no": "の",
+	"ha": "は", "hi": "ひ", "fu": "ふ", "he": "へ", "ho": "ほ",
+	"ba": "ば", "bi": "び", "bu": "ぶ", "be": "べ", "bo": "ぼ",
+	"pa": "ぱ", "pi": "ぴ", "pu": "ぷ", "pe": "ぺ", "po": "ぽ",
+	"ma": "ま", "mi": "み", "mu": "む", "me": "め", "mo": "も",
+	"ya": "や", "yu": "ゆ", "yo": "よ",
+	"ra": "ら", "ri": "り", "ru": "る", "re": "れ", "ro": "ろ",
+	"wa": "わ", "wo": "を",
+	"kya": "きゃ", "kyu": "きゅ", "kyo": "きょ",
+	"gya": "ぎゃ", "gyu": "ぎゅ", "gyo": "ぎょ",
+	"sha": "しゃ", "shu": "しゅ", "she": "しぇ", "sho": "しょ",
+	"ja": "じゃ", "ju": "じゅ", "je": "じぇ", "jo": "じょ",
+	"cha": "ちゃ", "chu": "ちゅ", "che": "ちぇ", "cho": "ちょ",
+	"nya": "にゃ", "nyu": "にゅ", "nyo": "にょ",
+	"hya": "ひゃ", "hyu": "ひゅ", "hyo": "ひょ",
+	"bya": "びゃ", "byu": "びゅ", "byo": "びょ",
+	"pya": "ぴゃ", "pyu": "ぴゅ", "pyo": "ぴょ",
+	"mya": "みゃ", "myu": "みゅ", "myo": "みょ",
+	"rya": "りゃ", "ryu": "りゅ", "ryo": "りょ",
+	"fa": "ふぁ", "fi": "ふぃ", "fe": "ふぇ", "fo": "ふぉ",
+	"va": "ゔぁ", "vi": "ゔぃ", "vu": "ゔ", "ve": "ゔぇ", "vo": "ゔぉ",
+}
+
+// romajiInput holds the spellings accepted on input only: Kunrei-shiki,
+// Nihon-shiki and the common IME spellings of combinations.
+var romajiInput = map[string]string{
+	"si": "し", "zi": "じ", "ti": "ち", "tu": "つ", "hu": "ふ", "di": "ぢ", "du": "づ",
+	"sya": "しゃ", "syu": "しゅ", "sye": "しぇ", "syo": "しょ",
+	"zya": "じゃ", "zyu": "じゅ", "zye": "じぇ", "zyo": "じょ",
+	"jya": "じゃ", "jyu": "じゅ", "jye": "じぇ", "jyo": "じょ",
+	"tya": "ちゃ", "tyu": "ちゅ", "tye": "ちぇ", "tyo": "ちょ",
+	"cya": "ちゃ", "cyu": "ちゅ", "cyo": "ちょ",
+	"dya": "ぢゃ", "dyu": "ぢゅ", "dyo": "ぢょ",
+	"ye": "いぇ", "wi": "うぃ", "we": "うぇ",
+	"thi": "てぃ", "dhi": "でぃ", "twu": "とぅ", "dwu": "どぅ",
+}
+
+// loanwordRomaji is the Hepburn spelling of kana combinations used in
+// loanwords. They are only used for output since "ti", "di", "tu" and "du"
+// are read as Kunrei-shiki on input.
+var loanwordRomaji = map[string]string{
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du", "でゅ": "dyu",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo", "いぇ": "ye",
+}
+
+var fromRomaji = func() map[string]string {
+	m := make(map[string]string, len(hepburn)+len(romajiInput))
+	for r, k := range hepburn {
+		m[r] = k
+	}
+	for r, k := range romajiInput {
+		m[r] = k
+	}
+	return m
+}()
+
+var toRomaji = func() map[string]string {
+	m := make(map[string]string, len(hepburn)+len(loanwordRomaji)+2)
+	for r, k := range hepburn {
+		m[k] = r
+	}
+	for k, r := range loanwordRomaji {
+		m[k] = r
+	}
+	m["ぢ"] = "ji"
+	m["づ"] = "zu"
+	return m
+}()
+
+// longVowels maps vowels with a macron or circumflex to their spelling with
+// hiragana and with katakana, where the long vowel mark is used.
+var longVowels = map[rune][2]string{
+	'ā': {"aa", "a-"}, 'â': {"aa", "a-"},
+	'ī': {"ii", "i-"}, 'î': {"ii", "i-"},
+	'ū': {"uu", "u-"}, 'û': {"uu", "u-"},
+	'ē': {"ee", "e-"}, 'ê': {"ee", "e-"},
+	'ō': {"ou", "o-"}, 'ô': {"ou", "o-"},
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aiueo", c) >= 0
+}
+
+// IsRomaji reports whether s is latin text that could be romaji: letters
+// (including long vowels), spaces, apostrophes, hyphens and punctuation.
+func IsRomaji(s string) bool {
+	hasLetter := false
+	for _, r := range strings.ToLower(s) {
+		_, long := longVowels[r]
+		switch {
+		case r >= 'a' && r <= 'z' || long:
+			hasLetter = true
+		case unicode.IsSpace(r) || r == '\'' || r == '-' || strings.ContainsRune(".,!?", r):
+		default:
+			return false
+		}
+	}
+	return hasLetter
+}
+
+func expandLongVowels(s string, katakana bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		if v, ok := longVowels[r]; ok {
+			if katakana {
+				b.WriteString(v[1])
+			} else {
+				b.WriteString(v[0])
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// RomajiToHiragana converts a single romaji word to hiragana. It returns
+// false if the input is not valid romaji.
+func RomajiToHiragana(s string) (string, bool) {
+	return convertRomaji(expandLongVowels(strings.ToLower(s), false))
+}
+
+// RomajiToKatakana converts a single romaji word to katakana, long vowels
+// written with a macron become the long vowel mark.
+func RomajiToKatakana(s string) (string, bool) {
+	hiragana, ok := convertRomaji(expandLongVowels(strings.ToLower(s), true))
+	if !ok {
+		return "", false
+	}
+	return ToKatakana(hiragana), true
+}
+
+func convertRomaji(s string) (string, bool) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		var next byte
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+
+		switch {
+		case c == '-':
+			b.WriteString("ー")
+			i++
+			continue
+		case c == 'n' && next == '\'':
+			b.WriteString("ん")
+			i += 2
+			continue
+		case c == 'n' && next == 'n' && (i+2 == len(s) || !isVowel(s[i+2]) && s[i+2] != 'y'):
+			// "nn" before a consonant or at the end is a single ん as typed with an IME
+			b.WriteString("ん")
+			i += 2
+			continue
+		case c == 'n' && !isVowel(next) && next != 'y':
+			b.WriteString("ん")
+			i++
+			continue
+		case c == 'm' && (next == 'b' || next == 'm' || next == 'p'):
+			// traditional Hepburn: shimbun
+			b.WriteString("ん")
+			i++
+			continue
+		case c == next && c >= 'a' && c <= 'z' && !isVowel(c):
+			b.WriteString("っ")
+			i++
+			continue
+		case c == 't' && next == 'c':
+			b.WriteString("っ")
+			i++
+			continue
+		}
+
+		matched := false
+		for l := 4; l > 0; l-- {
+			if i+l > len(s) {
+				continue
+			}
+			if kana, ok := fromRomaji[s[i:i+l]]; ok {
+				b.WriteString(kana)
+				i += l
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", false
+		}
+	}
+
+	return b.String(), true
+}
+
+var romajiPunctuation = map[string]string{".": "。", ",": "、", "!": "！", "?": "？"}
+
+// minTextKana is the least kana a romaji text must give: a single syllable
+// like "no" or "to" is more likely a latin word than Japanese.
+const minTextKana = 2
+
+// RomajiTextToHiragana converts a romaji sentence to hiragana like
+// RomajiWordsToHiragana. It also returns false if the text is too short to
+// tell from a latin word.
+func RomajiTextToHiragana(s string) (string, bool) {
+	hiragana, ok := RomajiWordsToHiragana(s)
+	if !ok {
+		return "", false
+	}
+	var kanaCount int
+	for _, r := range hiragana {
+		if IsKana(r) {
+			kanaCount++
+		}
+	}
+	if kanaCount < minTextKana {
+		return "", false
+	}
+	return hiragana, true
+}
+
+// RomajiWordsToHiragana converts romaji words to hiragana. Words are joined
+// without spaces, standalone "wa", "e" and "o" are treated as the particles
+// は, へ and を. It returns false if any word is not valid romaji.
+func RomajiWordsToHiragana(s string) (string, bool) {
+	var b strings.Builder
+	for _, field := range strings.Fields(strings.ToLower(s)) {
+		word := strings.TrimRight(field, ".,!?")
+		punct := field[len(word):]
+
+		var hiragana string
+		switch word {
+		case "wa":
+			hiragana = "は"
+		case "e":
+			hiragana = "へ"
+		case "o":
+			hiragana = "を"
+		default:
+			var ok bool
+			if hiragana, ok = RomajiToHiragana(word); !ok {
+				return "", false
+			}
+		}
+		b.WriteString(hiragana)
+
+		for _, p := range punct {
+			b.WriteString(romajiPunctuation[string(p)])
+		}
+	}
+	return b.String(), true
+}
+
+// particleRomaji are the particles read unlike their kana.
+var particleRomaji = map[string]string{"は": "wa", "へ": "e", "を": "o"}
+
+// ParticleToRomaji returns the romaji of a particle as it is pronounced: は
+// is wa, へ is e and を is o. ok is false for any other text.
+func ParticleToRomaji(s string) (string, bool) {
+	romaji, ok := particleRomaji[s]
+	return romaji, ok
+}
+
+var macrons = map[byte]string{'a': "ā", 'i': "ī", 'u': "ū", 'e': "ē", 'o': "ō"}
+
+// ToRomaji converts hiragana and katakana to modified Hepburn romaji.
+// Long vowels spelled with kana are kept as written (とうきょう → toukyou),
+// the long vowel mark becomes a macron (コーヒー → kōhī) or a hyphen where
+// there is no vowel to lengthen. A っ that doesn't double a consonant, as
+// in あっ, becomes an apostrophe. Other characters are kept. Particles are
+// not special-cased, the text has no word boundaries to tell は the particle
+// from は in はな: callers that know them use ParticleToRomaji.
+func ToRomaji(s string) string {
+	runes := []rune(ToHiragana(s))
+	var b strings.Builder
+	sokuon := false
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch r {
+		case 'っ':
+			sokuon = true
+			i++
+			continue
+		case 'ん':
+			b.WriteByte('n')
+			if i+1 < len(runes) {
+				if next, _ := syllableRomaji(runes[i+1:]); next != "" && (isVowel(next[0]) || next[0] == 'y') {
+					b.WriteByte('\'')
+				}
+			}
+			i++
+			continue
+		case 'ー':
+			if sokuon {
+				b.WriteByte('\'')
+				sokuon = false
+			}
+			out := b.String()
+			m, ok := "", false
+			if out != "" {
+				m, ok = macrons[out[len(out)-1]]
+			}
+			if ok {
+				b.Reset()
+				b.WriteString(out[:len(out)-1] + m)
+			} else {
+				b.WriteByte('-')
+			}
+			i++
+			continue
+		}
+
+		romaji, n := syllableRomaji(runes[i:])
+		if sokuon {
+			switch {
+			case strings.HasPrefix(romaji, "ch"):
+				b.WriteByte('t')
+			case romaji != "" && !isVowel(romaji[0]):
+				b.WriteByte(romaji[0])
+			default:
+				b.WriteByte('\'')
+			}
+			sokuon = false
+		}
+		if romaji == "" {
+			b.WriteRune(r)
+			i++
+			continue
+		}
+		b.WriteString(romaji)
+		i += n
+	}
+	if sokuon {
+		b.WriteByte('\'')
+	}
+
+	return b.String()
+}
+
+// syllableRomaji returns the romaji of the longest kana syllable at the
+// start of runes and the number of kana it takes.
+func syllableRomaji(runes []rune) (string, int) {
+	for l := 2; l > 0; l-- {
+		if l > len(runes) {
+			continue
+		}
+		if romaji, ok := toRomaji[string(runes[:l])]; ok {
+			return romaji, l
+		}
+	}
+	return "", 0
+}
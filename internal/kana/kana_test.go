@@ -0,0 +1,89 @@
+package kana
+
+import "testing"
+
+func TestRomajiToHiragana(t *testing.T) {
+	tests := []struct {
+		romaji string
+		want   string
+		ok     bool
+	}{
+		{"sakana", "さかな", true},
+		{"kitte", "きって", true},
+		{"matcha", "まっちゃ", true},
+		{"shimbun", "しんぶん", true},
+		{"kon'ya", "こんや", true},
+		{"konnichiwa", "こんにちわ", true},
+		{"tōkyō", "とうきょう", true},
+		{"tyotto", "ちょっと", true},
+		{"hello", "", false},
+		{"xtsu", "", false},
+		{"la", "", false},
+		{"k", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := RomajiToHiragana(tt.romaji)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("RomajiToHiragana(%q) = %q, %v, want %q, %v", tt.romaji, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRomajiToKatakana(t *testing.T) {
+	if got, ok := RomajiToKatakana("kōhī"); !ok || got != "コーヒー" {
+		t.Errorf("RomajiToKatakana(kōhī) = %q, %v, want コーヒー", got, ok)
+	}
+}
+
+func TestRomajiTextToHiragana(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"watashi wa gakusei desu.", "わたしはがくせいです。", true},
+		{"Sushi o tabemasu", "すしをたべます", true},
+		{"gakkou e ikimasu!", "がっこうへいきます！", true},
+		{"ki", "", false},
+		{"no", "", false},
+		{"no.", "", false},
+		{"hello", "", false},
+		{"hello world", "", false},
+		{"I like sushi", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := RomajiTextToHiragana(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("RomajiTextToHiragana(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestToRomaji(t *testing.T) {
+	tests := []struct {
+		kana string
+		want string
+	}{
+		{"すし", "sushi"},
+		{"きって", "kitte"},
+		{"まっちゃ", "matcha"},
+		{"こんや", "kon'ya"},
+		{"しんぶん", "shinbun"},
+		{"とうきょう", "toukyou"},
+		{"コーヒー", "kōhī"},
+		{"パーティー", "pātī"},
+		{"あっ", "a'"},
+		{"えっ！", "e'！"},
+		{"ー", "-"},
+		{"ンー", "n-"},
+		{"日本", "日本"},
+	}
+
+	for _, tt := range tests {
+		if got := ToRomaji(tt.kana); got != tt.want {
+			t.Errorf("ToRomaji(%q) = %q, want %q", tt.kana, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package kanji splits the reading of a word between its kanji.
+package kanji
+
+import (
+	"jpbot/internal/kana"
+	"strings"
+)
+
+// Segment is a character of a word with the part of the reading it stands for.
+type Segment struct {
+	Text    string
+	Reading string
+}
+
+// IsKanji reports whether the segment is a kanji rather than kana.
+func (s Segment) IsKanji() bool {
+	r := []rune(s.Text)
+	return len(r) == 1 && kana.IsKanji(r[0])
+}
+
+var (
+	voiced = map[rune][]rune{
+		'か': {'が'}, 'き': {'ぎ'}, 'く': {'ぐ'}, 'け': {'げ'}, 'こ': {'ご'},
+		'さ': {'ざ'}, 'し': {'じ'}, 'す': {'ず'}, 'せ': {'ぜ'}, 'そ': {'ぞ'},
+		'た': {'だ'}, 'ち': {'ぢ', 'じ'}, 'つ': {'づ', 'ず'}, 'て': {'で'}, 'と': {'ど'},
+		'は': {'ば', 'ぱ'}, 'ひ': {'び', 'ぴ'}, 'ふ': {'ぶ', 'ぷ'}, 'へ': {'べ', 'ぺ'}, 'ほ': {'ぼ', 'ぽ'},
+	}
+	// geminated are the final kana that become っ before another kanji, as in 学校.
+	geminated = map[rune]bool{'く': true, 'き': true, 'つ': true, 'ち': true}
+)
+
+// Normalize converts a KANJIDIC reading to the hiragana of the kanji alone:
+// on readings are in katakana, kun readings mark okurigana with a dot and
+// affixes with a dash.
+func Normalize(reading string) string {
+	reading = strings.Trim(reading, "-")
+	if i := strings.Index(reading, "."); i >= 0 {
+		reading = reading[:i]
+	}
+	return kana.ToHiragana(reading)
+}
+
+// variants returns the reading with the sound changes it can have inside a
+// word: a voiced first kana (rendaku) and a small っ at the end.
+func variants(reading string) []string {
+	out := []string{reading}
+	runes := []rune(reading)
+	if len(runes) == 0 {
+		return out
+	}
+
+	for _, v := range voiced[runes[0]] {
+		out = append(out, string(v)+string(runes[1:]))
+	}
+	if len(runes) > 1 && geminated[runes[len(runes)-1]] {
+		for _, r := range out {
+			rr := []rune(r)
+			out = append(out, string(rr[:len(rr)-1])+"っ")
+		}
+	}
+	return out
+}
+
+// Align splits the reading of a word between its characters. readings gives
+// the known readings of a kanji in any KANJIDIC form. A kanji without them
+// takes whatever part of the reading is left over, but only if it is the
+// only kanji in the word, otherwise there is no telling where its reading
+// ends. 々 repeats the previous kanji. ok is false if the reading can't be
+// split.
+func Align(word, reading string, readings func(rune) []string) ([]Segment, bool) {
+	chars := []rune(word)
+	reading = kana.ToHiragana(reading)
+
+	var kanjiCount int
+	for _, c := range chars {
+		if kana.IsKanji(c) || c == '々' {
+			kanjiCount++
+		}
+	}
+
+	var align func(i int, rest string) ([]Segment, bool)
+	align = func(i int, rest string) ([]Segment, bool) {
+		if i == len(chars) {
+			return nil, rest == ""
+		}
+
+		c := chars[i]
+		if !kana.IsKanji(c) && c != '々' {
+			k := kana.ToHiragana(string(c))
+			if !strings.HasPrefix(rest, k) {
+				return nil, false
+			}
+			tail, ok := align(i+1, rest[len(k):])
+			return append([]Segment{{Text: string(c), Reading: k}}, tail...), ok
+		}
+
+		var candidates []string
+		source := c
+		if c == '々' && i > 0 {
+			source = chars[i-1]
+		}
+		for _, r := range readings(source) {
+			candidates = append(candidates, variants(Normalize(r))...)
+		}
+		if len(candidates) == 0 {
+			if kanjiCount > 1 {
+				return nil, false
+			}
+			// the only kanji takes one or more kana
+			restRunes := []rune(rest)
+			for n := 1; n <= len(restRunes); n++ {
+				candidates = append(candidates, string(restRunes[:n]))
+			}
+		}
+
+		for _, candidate := range candidates {
+			if candidate == "" || !strings.HasPrefix(rest, candidate) {
+				continue
+			}
+			if tail, ok := align(i+1, rest[len(candidate):]); ok {
+				return append([]Segment{{Text: string(c), Reading: candidate}}, tail...), true
+			}
+		}
+		return nil, false
+	}
+
+	return align(0, reading)
+}
@@ -0,0 +1,55 @@
+package kanji
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testReadings = map[rune][]string{
+	'食': {"ショク", "ジキ", "く.う", "た.べる"},
+	'学': {"ガク", "まな.ぶ"},
+	'校': {"コウ"},
+	'日': {"ニチ", "ジツ", "ひ", "-び", "-か"},
+	'本': {"ホン", "もと"},
+	'人': {"ジン", "ニン", "ひと"},
+	'時': {"ジ", "とき"},
+	'々': nil,
+}
+
+func readings(r rune) []string {
+	return testReadings[r]
+}
+
+func TestAlign(t *testing.T) {
+	tests := []struct {
+		word, reading string
+		want          []Segment
+	}{
+		{"食べる", "たべる", []Segment{{"食", "た"}, {"べ", "べ"}, {"る", "る"}}},
+		{"学校", "がっこう", []Segment{{"学", "がっ"}, {"校", "こう"}}},
+		{"本日", "ほんじつ", []Segment{{"本", "ほん"}, {"日", "じつ"}}},
+		{"日本人", "にっぽんじん", []Segment{{"日", "にっ"}, {"本", "ぽん"}, {"人", "じん"}}},
+		{"人々", "ひとびと", []Segment{{"人", "ひと"}, {"々", "びと"}}},
+		{"時々", "ときどき", []Segment{{"時", "とき"}, {"々", "どき"}}},
+		// 猫 is not in the table and takes the rest of the reading
+		{"猫", "ねこ", []Segment{{"猫", "ねこ"}}},
+	}
+
+	for _, tt := range tests {
+		got, ok := Align(tt.word, tt.reading, readings)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Align(%s, %s) = %v, %v, want %v", tt.word, tt.reading, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := Align("日本", "にっぽんご", readings); ok {
+		t.Error("Align should fail when the reading doesn't fit")
+	}
+	// 語 is not in the table, so the rest of the reading can't be trusted to be its own
+	if got, ok := Align("日本語", "にほんご", readings); ok {
+		t.Errorf("Align should fail for a kanji without readings next to others, got %v", got)
+	}
+	if got, ok := Align("電車", "でんしゃ", readings); ok {
+		t.Errorf("Align should fail for several kanji without readings, got %v", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Package matcher checks vocab answers locally. It only recognizes answers
+// that are certainly correct, everything else has to be evaluated by the LLM.
+package matcher
+
+import (
+	"jpbot/internal/db"
+	"jpbot/internal/kana"
+	"strings"
+	"unicode"
+)
+
+// Match reports whether the user input is a correct answer for the card.
+func Match(card db.Card, input string) bool {
+	input = normalize(input)
+	if input == "" {
+		return false
+	}
+
+	switch card.Direction {
+	case db.CardDirectionRecognition:
+		return matchTranslation(card.Word.Translation, input)
+	case db.CardDirectionReading:
+		return matchReading(card.Word.Kana, input)
+	default:
+		return matchWord(card.Word, input)
+	}
+}
+
+// Mismatch reports whether the user input is certainly a wrong answer for
+// the card: a reading written in kana that is none of the readings. Readings
+// are those of all words spelled like the card's word, since the same
+// spelling can have several valid readings (明日 is あした and あす).
+// There is nothing left for the LLM to judge in such an answer.
+func Mismatch(card db.Card, input string, readings []string) bool {
+	if card.Direction != db.CardDirectionReading {
+		return false
+	}
+	input = normalize(input)
+	if input == "" {
+		return false
+	}
+	for _, r := range input {
+		if !kana.IsKana(r) {
+			return false
+		}
+	}
+	if matchReading(card.Word.Kana, input) {
+		return false
+	}
+	for _, reading := range readings {
+		if matchReading(reading, input) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(s string) string {
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(".,!?。、！？", r)
+	})
+	return strings.ToLower(s)
+}
+
+// matchReading compares kana or romaji input with the reading of a word,
+// katakana and hiragana are considered equal.
+func matchReading(reading, input string) bool {
+	if kana.IsRomaji(input) {
+		hiragana, ok := kana.RomajiToHiragana(strings.Join(strings.Fields(input), ""))
+		if !ok {
+			return false
+		}
+		input = hiragana
+	}
+
+	return kana.ToHiragana(input) == kana.ToHiragana(reading)
+}
+
+func matchWord(word db.Word, input string) bool {
+	if matchReading(word.Kana, input) {
+		return true
+	}
+
+	if word.Kanji == nil || *word.Kanji == "" {
+		return false
+	}
+
+	return input == *word.Kanji || matchOkurigana(*word.Kanji, word.Kana, input)
+}
+
+type kanjiRun struct {
+	kanji rune
+	kana  string // okurigana following the kanji
+}
+
+// splitKanji splits a word into its leading kana and kanji with the kana following each of them.
+func splitKanji(s string) (string, []kanjiRun) {
+	var prefix strings.Builder
+	var runs []kanjiRun
+	for _, r := range kana.ToHiragana(s) {
+		switch {
+		case kana.IsKanji(r):
+			runs = append(runs, kanjiRun{kanji: r})
+		case len(runs) == 0:
+			prefix.WriteRune(r)
+		default:
+			runs[len(runs)-1].kana += string(r)
+		}
+	}
+	return prefix.String(), runs
+}
+
+// matchOkurigana accepts spelling variants of a word written with the same
+// kanji but different okurigana: okurigana between kanji may be omitted
+// (申し込み → 申込み) and the trailing okurigana may be extended as long as it
+// still ends the reading (青 → 青い for あおい). Omitting trailing okurigana is
+// not accepted because it can't be told apart from a wrong conjugation.
+func matchOkurigana(written, reading, input string) bool {
+	wordPrefix, wordRuns := splitKanji(written)
+	inputPrefix, inputRuns := splitKanji(input)
+	if len(wordRuns) == 0 || wordPrefix != inputPrefix || len(wordRuns) != len(inputRuns) {
+		return false
+	}
+
+	last := len(wordRuns) - 1
+	for i := range wordRuns {
+		w, in := wordRuns[i], inputRuns[i]
+		if w.kanji != in.kanji {
+			return false
+		}
+		if i < last && in.kana != "" && in.kana != w.kana {
+			return false
+		}
+	}
+
+	w, in := wordRuns[last], inputRuns[last]
+	if in.kana == w.kana {
+		return true
+	}
+
+	return strings.HasSuffix(in.kana, w.kana) && strings.HasSuffix(kana.ToHiragana(reading), in.kana)
+}
+
+// matchTranslation accepts any of the comma separated translations of a word,
+// with or without the explanation in parentheses.
+func matchTranslation(translation, input string) bool {
+	input = normalizeRussian(input)
+	for _, t := range strings.FieldsFunc(translation, func(r rune) bool { return r == ',' || r == ';' }) {
+		t = normalizeRussian(t)
+		if t == "" {
+			continue
+		}
+		if sameRussian(t, input) {
+			return true
+		}
+		if i := strings.Index(t, "("); i > 0 && sameRussian(strings.TrimSpace(t[:i]), input) {
+			return true
+		}
+	}
+	return false
+}
+
+// yoAmbiguous are words that become other words when ё is written as е,
+// keyed by the spelling with е.
+var yoAmbiguous = map[string]bool{
+	"все": true, "небо": true, "осел": true, "мел": true, "падеж": true,
+	"берет": true, "узнаем": true, "совершенный": true, "слез": true,
+}
+
+// sameRussian compares a translation with the input, ё and е are the same
+// letter unless that makes the word ambiguous: всё is not все.
+func sameRussian(translation, input string) bool {
+	if translation == input {
+		return true
+	}
+	folded := strings.ReplaceAll(translation, "ё", "е")
+	if folded != strings.ReplaceAll(input, "ё", "е") {
+		return false
+	}
+	for _, w := range strings.Fields(folded) {
+		if yoAmbiguous[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeRussian(s string) string {
+	s = strings.TrimFunc(strings.ToLower(s), func(r rune) bool {
+		return unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '(' && r != ')')
+	})
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,129 @@
+package matcher
+
+import (
+	"jpbot/internal/db"
+	"testing"
+)
+
+func word(kanji, kana, translation string) db.Word {
+	w := db.Word{Kana: kana, Translation: translation}
+	if kanji != "" {
+		w.Kanji = &kanji
+	}
+	return w
+}
+
+func TestMatch(t *testing.T) {
+	mizu := word("水", "みず", "вода")
+	aoi := word("青", "あおい", "синий цвет, синий, голубой")
+	moushikomi := word("申し込み", "もうしこみ", "заявка")
+	taberu := word("食べる", "たべる", "есть, кушать")
+	okonau := word("行う", "おこなう", "проводить, совершать")
+	baito := word("", "アルバイト", "подработка")
+	kitte := word("切手", "きって", "почтовая марка")
+	konnichiwa := word("", "こんにちは", "здравствуйте")
+	hon := word("本", "ほん", "книга")
+	kinyoubi := word("金曜日", "きんようび", "пятница")
+	medicine := word("医学", "いがく", "медицина (наука)")
+	zenbu := word("全部", "ぜんぶ", "всё, целиком")
+	kuroi := word("黒い", "くろい", "чёрный")
+	minna := word("皆", "みんな", "все")
+
+	tests := []struct {
+		name      string
+		word      db.Word
+		direction string
+		input     string
+		want      bool
+	}{
+		{"exact kanji", mizu, db.CardDirectionProduction, "水", true},
+		{"exact kana", mizu, db.CardDirectionProduction, "みず", true},
+		{"surrounding spaces and punctuation", mizu, db.CardDirectionProduction, " 水。", true},
+		{"katakana for hiragana", mizu, db.CardDirectionProduction, "ミズ", true},
+		{"hiragana for katakana", baito, db.CardDirectionProduction, "あるばいと", true},
+		{"romaji", mizu, db.CardDirectionProduction, "mizu", true},
+		{"romaji upper case", mizu, db.CardDirectionProduction, "MIZU", true},
+		{"romaji sokuon", kitte, db.CardDirectionProduction, "kitte", true},
+		{"romaji n at the end", hon, db.CardDirectionProduction, "hon", true},
+		{"romaji n before consonant", kinyoubi, db.CardDirectionProduction, "kin'youbi", true},
+		{"romaji nn", konnichiwa, db.CardDirectionProduction, "konnichiha", true},
+		{"romaji with spaces", taberu, db.CardDirectionProduction, "ta beru", true},
+		{"kunrei shi", word("写真", "しゃしん", "фотография"), db.CardDirectionProduction, "syasin", true},
+		{"romaji macron", word("東京", "とうきょう", "Токио"), db.CardDirectionProduction, "Tōkyō", true},
+		{"romaji long vowel omitted", word("東京", "とうきょう", "Токио"), db.CardDirectionProduction, "tokyo", false},
+		{"romaji m before b", word("新聞", "しんぶん", "газета"), db.CardDirectionProduction, "shimbun", true},
+		{"romaji tch", word("", "マッチ", "спичка"), db.CardDirectionProduction, "matchi", true},
+		{"invalid romaji", mizu, db.CardDirectionProduction, "mizx", false},
+		{"wrong kana", mizu, db.CardDirectionProduction, "みそ", false},
+		{"wrong kanji", mizu, db.CardDirectionProduction, "氷", false},
+		{"russian in production", mizu, db.CardDirectionProduction, "вода", false},
+		{"empty input", mizu, db.CardDirectionProduction, "  ", false},
+		{"okurigana added", aoi, db.CardDirectionProduction, "青い", true},
+		{"inner okurigana omitted", moushikomi, db.CardDirectionProduction, "申込み", true},
+		{"all okurigana omitted", moushikomi, db.CardDirectionProduction, "申込", false},
+		{"trailing okurigana extended", okonau, db.CardDirectionProduction, "行なう", true},
+		{"trailing okurigana shortened", taberu, db.CardDirectionProduction, "食る", false},
+		{"different okurigana", taberu, db.CardDirectionProduction, "食たる", false},
+		{"okurigana not in reading", aoi, db.CardDirectionProduction, "青く", false},
+		{"reading kana", mizu, db.CardDirectionReading, "みず", true},
+		{"reading romaji", kinyoubi, db.CardDirectionReading, "kinyoubi", false},
+		{"reading romaji apostrophe", kinyoubi, db.CardDirectionReading, "kin'youbi", true},
+		{"reading katakana", mizu, db.CardDirectionReading, "ミズ", true},
+		{"reading kanji is not a reading", mizu, db.CardDirectionReading, "水", false},
+		{"translation exact", mizu, db.CardDirectionRecognition, "вода", true},
+		{"translation case and punctuation", mizu, db.CardDirectionRecognition, "Вода!", true},
+		{"translation alternative", aoi, db.CardDirectionRecognition, "голубой", true},
+		{"translation second word", taberu, db.CardDirectionRecognition, "кушать", true},
+		{"translation ё", zenbu, db.CardDirectionRecognition, "всё", true},
+		{"translation ё is not е in an ambiguous word", zenbu, db.CardDirectionRecognition, "все", false},
+		{"translation е for ё", kuroi, db.CardDirectionRecognition, "черный", true},
+		{"translation е is not ё in an ambiguous word", minna, db.CardDirectionRecognition, "всё", false},
+		{"translation without parentheses", medicine, db.CardDirectionRecognition, "медицина", true},
+		{"translation with parentheses", medicine, db.CardDirectionRecognition, "медицина (наука)", true},
+		{"translation synonym", mizu, db.CardDirectionRecognition, "жидкость", false},
+		{"translation japanese", mizu, db.CardDirectionRecognition, "水", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := db.Card{Word: tt.word, Direction: tt.direction}
+			if got := Match(card, tt.input); got != tt.want {
+				t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.word.GetKanji(), tt.direction, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	mizu := word("水", "みず", "вода")
+	ashita := word("明日", "あした", "завтра")
+
+	tests := []struct {
+		name      string
+		word      db.Word
+		readings  []string
+		direction string
+		input     string
+		want      bool
+	}{
+		{"other kana reading", mizu, []string{"みず"}, db.CardDirectionReading, "すい", true},
+		{"other katakana reading", mizu, []string{"みず"}, db.CardDirectionReading, "スイ。", true},
+		{"right reading", mizu, []string{"みず"}, db.CardDirectionReading, "みず", false},
+		{"kanji is left to the LLM", mizu, []string{"みず"}, db.CardDirectionReading, "水", false},
+		{"romaji is left to the LLM", mizu, []string{"みず"}, db.CardDirectionReading, "sui", false},
+		{"mixed text is left to the LLM", mizu, []string{"みず"}, db.CardDirectionReading, "みず, наверное", false},
+		{"production is left to the LLM", mizu, []string{"みず"}, db.CardDirectionProduction, "すい", false},
+		{"reading of another word with the spelling", ashita, []string{"あした", "あす"}, db.CardDirectionReading, "あす", false},
+		{"none of the spelling readings", ashita, []string{"あした", "あす"}, db.CardDirectionReading, "みょうにち", true},
+		{"no readings looked up", ashita, nil, db.CardDirectionReading, "あした", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := db.Card{Word: tt.word, Direction: tt.direction}
+			if got := Mismatch(card, tt.input, tt.readings); got != tt.want {
+				t.Errorf("Mismatch(%q, %q) = %v, want %v", tt.direction, tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"jpbot/internal/db"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+const optionCount = 4
+
+// ErrNoExercises is returned when a section of the test has no exercises of
+// the level to draw from.
+var ErrNoExercises = errors.New("not enough exercises for a mock test")
+
+// particleOptions are the distractors of grammar questions.
+var particleOptions = []string{"は", "が", "を", "に", "で", "へ", "と", "も", "の", "から", "まで", "より", "や"}
+
+// interchangeable are the particles that often fit the same blank, like
+// 学校に行く and 学校へ行く. They are never distractors of each other, so
+// that a question has one right option.
+var interchangeable = [][]string{
+	{"は", "が", "も"},
+	{"が", "を"},
+	{"に", "へ", "で"},
+	{"に", "まで"},
+	{"から", "で", "より"},
+	{"と", "や"},
+}
+
+// fitsInstead reports whether the particle may fit the blank of the answer.
+func fitsInstead(answer, particle string) bool {
+	for _, set := range interchangeable {
+		if slices.Contains(set, answer) && slices.Contains(set, particle) {
+			return true
+		}
+	}
+	return false
+}
+
+type Source interface {
+	GetRandomExercises(level, exType string, limit int) ([]db.Exercise, error)
+}
+
+// Start assembles a new attempt for the level. All questions are multiple
+// choice so the test is graded without AI:
+//   - vocabulary comes from the multiple-choice exercises;
+//   - grammar from the particle cloze exercises;
+//   - reading asks about the reading passages, topped up with the
+//     translation of a sentence when there are too few;
+//   - listening plays the text of an audio exercise and asks which of the
+//     texts was said.
+func Start(userID int64, level string, source Source, now time.Time) (db.MockAttempt, error) {
+	plan, ok := PlanFor(level)
+	if !ok {
+		return db.MockAttempt{}, fmt.Errorf("no mock test for level %s", level)
+	}
+
+	counts := make(map[string]int)
+	for _, s := range plan.Sections {
+		counts[s.Section] = s.Count
+	}
+
+	vocabulary, err := vocabularyQuestions(level, source, counts[SectionVocabulary])
+	if err != nil {
+		return db.MockAttempt{}, err
+	}
+	grammar, err := grammarQuestions(level, source, counts[SectionGrammar])
+	if err != nil {
+		return db.MockAttempt{}, err
+	}
+	passages, err := passageQuestions(level, source, counts[SectionReading])
+	if err != nil {
+		return db.MockAttempt{}, err
+	}
+	reading, err := sentenceQuestions(level, source, counts[SectionReading]-len(passages))
+	if err != nil {
+		return db.MockAttempt{}, err
+	}
+	reading = append(passages, reading...)
+	listening, err := listeningQuestions(level, source, counts[SectionListening])
+	if err != nil {
+		return db.MockAttempt{}, err
+	}
+
+	bySection := map[string][]db.MockQuestion{
+		SectionVocabulary: vocabulary,
+		SectionGrammar:    grammar,
+		SectionReading:    reading,
+		SectionListening:  listening,
+	}
+	var questions []db.MockQuestion
+	for _, s := range plan.Sections {
+		if len(bySection[s.Section]) == 0 {
+			return db.MockAttempt{}, ErrNoExercises
+		}
+		questions = append(questions, bySection[s.Section]...)
+	}
+
+	return db.MockAttempt{
+		UserID:    userID,
+		Level:     level,
+		Questions: questions,
+		Answers:   []int{},
+		StartedAt: now,
+		Deadline:  now.Add(plan.Duration),
+	}, nil
+}
+
+func vocabularyQuestions(level string, source Source, n int) ([]db.MockQuestion, error) {
+	exercises, err := source.GetRandomExercises(level, db.ExerciseTypeMultipleChoice, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []db.MockQuestion
+	for _, e := range exercises {
+		c, err := db.ContentAs[db.MultipleChoiceContent](e.Content)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, db.MockQuestion{
+			Section:    SectionVocabulary,
+			ExerciseID: e.ID,
+			Stem:       c.Stem,
+			Options:    c.Options,
+			Correct:    c.Correct,
+		})
+	}
+	return questions, nil
+}
+
+// grammarQuestions asks for the particle blanked out of a sentence. About
+// half of the cloze exercises blank out a word, so more are drawn.
+func grammarQuestions(level string, source Source, n int) ([]db.MockQuestion, error) {
+	exercises, err := source.GetRandomExercises(level, db.ExerciseTypeCloze, n*4)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []db.MockQuestion
+	for _, e := range exercises {
+		if len(questions) == n {
+			break
+		}
+		c, err := db.ContentAs[db.ClozeContent](e.Content)
+		if err != nil {
+			return nil, err
+		}
+		if c.Hint != db.ClozeHintParticle || len(c.Answers) == 0 {
+			continue
+		}
+
+		var sentence strings.Builder
+		for _, s := range c.Sentence {
+			sentence.WriteString(s.Fragment)
+		}
+		distractors := make([]string, 0, len(particleOptions))
+		for _, p := range particleOptions {
+			if p != c.Answers[0] && !fitsInstead(c.Answers[0], p) {
+				distractors = append(distractors, p)
+			}
+		}
+
+		options, correct := shuffleOptions(c.Answers[0], distractors)
+		questions = append(questions, db.MockQuestion{
+			Section:    SectionGrammar,
+			ExerciseID: e.ID,
+			Stem:       fmt.Sprintf("Выбери частицу:\n\n%s\n(%s)", sentence.String(), c.Translation),
+			Options:    options,
+			Correct:    correct,
+		})
+	}
+	return questions, nil
+}
+
+// passageQuestions takes up to n questions from random reading passages.
+func passageQuestions(level string, source Source, n int) ([]db.MockQuestion, error) {
+	exercises, err := source.GetRandomExercises(level, db.ExerciseTypeReading, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []db.MockQuestion
+	for _, e := range exercises {
+		c, err := db.ContentAs[db.ReadingContent](e.Content)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range c.Questions {
+			if len(questions) == n {
+				return questions, nil
+			}
+			questions = append(questions, db.MockQuestion{
+				Section:    SectionReading,
+				ExerciseID: e.ID,
+				Stem:       fmt.Sprintf("Прочитай текст и ответь на вопрос:\n\n%s\n\n%s", c.Passage, q.Question),
+				Options:    q.Options,
+				Correct:    q.Correct,
+			})
+		}
+	}
+	return questions, nil
+}
+
+// sentenceQuestions makes reading questions from the translation
+// exercises, the translations of the other drawn sentences are the
+// distractors.
+func sentenceQuestions(level string, source Source, n int) ([]db.MockQuestion, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	exercises, err := source.GetRandomExercises(level, db.ExerciseTypeTranslation, n+optionCount)
+	if err != nil {
+		return nil, err
+	}
+	if len(exercises) < optionCount {
+		return nil, nil
+	}
+
+	sentences := make([]db.SentenceContent, len(exercises))
+	translations := make([]string, len(exercises))
+	for i, e := range exercises {
+		if sentences[i], err = db.ContentAs[db.SentenceContent](e.Content); err != nil {
+			return nil, err
+		}
+		translations[i] = sentences[i].Russian
+	}
+
+	var questions []db.MockQuestion
+	for i := 0; i < n && i < len(exercises); i++ {
+		options, correct := shuffleOptions(translations[i], otherOptions(translations, translations[i]))
+		questions = append(questions, db.MockQuestion{
+			Section:    SectionReading,
+			ExerciseID: exercises[i].ID,
+			Stem:       fmt.Sprintf("Прочитай и выбери перевод:\n\n%s", sentences[i].Japanese),
+			Options:    options,
+			Correct:    correct,
+		})
+	}
+	return questions, nil
+}
+
+// listeningQuestions plays the texts of audio exercises, the other drawn
+// texts are the distractors.
+func listeningQuestions(level string, source Source, n int) ([]db.MockQuestion, error) {
+	exercises, err := source.GetRandomExercises(level, db.ExerciseTypeAudio, n+optionCount)
+	if err != nil {
+		return nil, err
+	}
+	if len(exercises) < optionCount {
+		return nil, nil
+	}
+
+	texts := make([]string, len(exercises))
+	for i, e := range exercises {
+		c, err := db.ContentAs[db.AudioContent](e.Content)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = c.Text
+	}
+
+	var questions []db.MockQuestion
+	for i := 0; i < n && i < len(exercises); i++ {
+		options, correct := shuffleOptions(texts[i], otherOptions(texts, texts[i]))
+		questions = append(questions, db.MockQuestion{
+			Section:    SectionListening,
+			ExerciseID: exercises[i].ID,
+			Stem:       "Прослушай и выбери, что прозвучало.",
+			Options:    options,
+			Correct:    correct,
+			Audio:      texts[i],
+		})
+	}
+	return questions, nil
+}
+
+// otherOptions returns the options other than the answer without repeats.
+func otherOptions(options []string, answer string) []string {
+	var others []string
+	seen := map[string]bool{answer: true}
+	for _, o := range options {
+		if !seen[o] {
+			seen[o] = true
+			others = append(others, o)
+		}
+	}
+	return others
+}
+
+// shuffleOptions mixes the answer with random distractors and returns the
+// options with the index of the answer.
+func shuffleOptions(answer string, distractors []string) ([]string, int) {
+	rand.Shuffle(len(distractors), func(i, j int) {
+		distractors[i], distractors[j] = distractors[j], distractors[i]
+	})
+	if len(distractors) > optionCount-1 {
+		distractors = distractors[:optionCount-1]
+	}
+
+	options := append([]string{answer}, distractors...)
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	for i, o := range options {
+		if o == answer {
+			return options, i
+		}
+	}
+	return options, 0
+}
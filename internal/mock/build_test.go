@@ -0,0 +1,76 @@
+package mock
+
+import (
+	"encoding/json"
+	"jpbot/internal/db"
+	"slices"
+	"testing"
+)
+
+// source returns the exercises of every type in the order they were added.
+type source map[string][]db.Exercise
+
+func (s source) add(t *testing.T, exType string, content interface{}) {
+	t.Helper()
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s[exType] = append(s[exType], db.Exercise{ID: int64(len(s[exType]) + 1), Level: db.LevelN5, Type: exType, Content: data})
+}
+
+func (s source) GetRandomExercises(level, exType string, limit int) ([]db.Exercise, error) {
+	return s[exType][:min(limit, len(s[exType]))], nil
+}
+
+func TestGrammarQuestionsHaveOneRightOption(t *testing.T) {
+	s := source{}
+	for _, particle := range []string{"に", "は", "で"} {
+		s.add(t, db.ExerciseTypeCloze, db.ClozeContent{
+			Sentence: []db.Sentence{{Fragment: "学校"}, {Fragment: db.ClozeBlank}, {Fragment: "行きます。"}},
+			Hint:     db.ClozeHintParticle,
+			Answers:  []string{particle},
+		})
+	}
+
+	for i := 0; i < 20; i++ {
+		questions, err := grammarQuestions(db.LevelN5, s, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range questions {
+			answer := q.Options[q.Correct]
+			for _, o := range q.Options {
+				if o != answer && fitsInstead(answer, o) {
+					t.Fatalf("options %v of the answer %s have %s, which may fit too", q.Options, answer, o)
+				}
+			}
+		}
+	}
+}
+
+func TestListeningQuestionsPlayAudioExercises(t *testing.T) {
+	s := source{}
+	texts := []string{"りんごを買いました。", "猫が好きです。", "公園へ行きました。", "パンを食べました。", "雨が降っています。"}
+	for _, text := range texts {
+		s.add(t, db.ExerciseTypeAudio, db.AudioContent{Text: text, Question: "何ですか？"})
+	}
+	s.add(t, db.ExerciseTypeTranslation, db.SentenceContent{Japanese: "魚を食べます。", Russian: "Я ем рыбу."})
+
+	questions, err := listeningQuestions(db.LevelN5, s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 1 {
+		t.Fatalf("got %d questions, want 1", len(questions))
+	}
+	q := questions[0]
+	if q.Audio != texts[0] || q.Options[q.Correct] != texts[0] || len(q.Options) != optionCount {
+		t.Errorf("got %+v, want the first audio text played and among %d options", q, optionCount)
+	}
+	for _, o := range q.Options {
+		if !slices.Contains(texts, o) {
+			t.Errorf("option %q is not an audio text", o)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package mock assembles timed JLPT-style tests from the exercises and scores
+// them the way the real exam does.
+package mock
+
+import (
+	"errors"
+	"jpbot/internal/db"
+	"math"
+	"time"
+)
+
+// Sections of a mock test in the order of the exam.
+const (
+	SectionVocabulary = "vocabulary"
+	SectionGrammar    = "grammar"
+	SectionReading    = "reading"
+	SectionListening  = "listening"
+)
+
+// Scored sections of the JLPT. N4 and N5 score reading together with the
+// language knowledge.
+const (
+	ScoreLanguage  = "language"
+	ScoreReading   = "reading"
+	ScoreListening = "listening"
+)
+
+var (
+	ErrFinished      = errors.New("mock attempt is finished")
+	ErrExpired       = errors.New("mock attempt time is over")
+	ErrNotCurrent    = errors.New("not the current question")
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+type SectionCount struct {
+	Section string
+	Count   int
+}
+
+type scoredSection struct {
+	name     string
+	sections []string
+	max, min int
+}
+
+// Plan is the shape of a mock test for a level: the number of questions of
+// every section, roughly in the proportions of the real exam, the time limit
+// and the scoring.
+type Plan struct {
+	Sections []SectionCount
+	Duration time.Duration
+	PassMark int
+	scored   []scoredSection
+}
+
+var (
+	scoredN4N5 = []scoredSection{
+		{ScoreLanguage, []string{SectionVocabulary, SectionGrammar, SectionReading}, 120, 38},
+		{ScoreListening, []string{SectionListening}, 60, 19},
+	}
+	scoredN1N3 = []scoredSection{
+		{ScoreLanguage, []string{SectionVocabulary, SectionGrammar}, 60, 19},
+		{ScoreReading, []string{SectionReading}, 60, 19},
+		{ScoreListening, []string{SectionListening}, 60, 19},
+	}
+)
+
+var plans = map[string]Plan{
+	db.LevelN5: {
+		Sections: []SectionCount{{SectionVocabulary, 8}, {SectionGrammar, 4}, {SectionReading, 2}, {SectionListening, 6}},
+		Duration: 20 * time.Minute,
+		PassMark: 80,
+		scored:   scoredN4N5,
+	},
+	db.LevelN4: {
+		Sections: []SectionCount{{SectionVocabulary, 8}, {SectionGrammar, 5}, {SectionReading, 2}, {SectionListening, 7}},
+		Duration: 25 * time.Minute,
+		PassMark: 90,
+		scored:   scoredN4N5,
+	},
+	db.LevelN3: {
+		Sections: []SectionCount{{SectionVocabulary, 8}, {SectionGrammar, 5}, {SectionReading, 4}, {SectionListening, 7}},
+		Duration: 30 * time.Minute,
+		PassMark: 95,
+		scored:   scoredN1N3,
+	},
+	db.LevelN2: {
+		Sections: []SectionCount{{SectionVocabulary, 8}, {SectionGrammar, 5}, {SectionReading, 5}, {SectionListening, 7}},
+		Duration: 35 * time.Minute,
+		PassMark: 90,
+		scored:   scoredN1N3,
+	},
+	db.LevelN1: {
+		Sections: []SectionCount{{SectionVocabulary, 7}, {SectionGrammar, 5}, {SectionReading, 6}, {SectionListening, 7}},
+		Duration: 40 * time.Minute,
+		PassMark: 100,
+		scored:   scoredN1N3,
+	},
+}
+
+// PlanFor returns the plan of a level.
+func PlanFor(level string) (Plan, bool) {
+	p, ok := plans[level]
+	return p, ok
+}
+
+// Score scales the correct answers of every scored section to its points,
+// linearly, as the exam's equating can't be reproduced. Unanswered questions
+// count as wrong. The test is passed with the total pass mark and every
+// sectional one.
+func Score(level string, questions []db.MockQuestion, answers []int) db.MockResult {
+	plan, _ := PlanFor(level)
+	result := db.MockResult{PassMark: plan.PassMark, Passed: true}
+
+	for _, s := range plan.scored {
+		score := db.MockSectionScore{Section: s.name, Max: s.max, Min: s.min}
+		for i, q := range questions {
+			if !contains(s.sections, q.Section) {
+				continue
+			}
+			score.Total++
+			if i < len(answers) && answers[i] == q.Correct {
+				score.Correct++
+			}
+		}
+		if score.Total > 0 {
+			score.Score = int(math.Round(float64(score.Correct) / float64(score.Total) * float64(s.max)))
+		}
+		if score.Score < s.min {
+			result.Passed = false
+		}
+
+		result.Sections = append(result.Sections, score)
+		result.Total += score.Score
+		result.Max += s.max
+	}
+
+	if result.Total < result.PassMark {
+		result.Passed = false
+	}
+	return result
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the time of an unfinished attempt is over.
+func Expired(attempt *db.MockAttempt, now time.Time) bool {
+	return attempt.FinishedAt == nil && now.After(attempt.Deadline)
+}
+
+// Finish scores the attempt, the questions left unanswered are marked with -1.
+func Finish(attempt *db.MockAttempt, now time.Time) {
+	for len(attempt.Answers) < len(attempt.Questions) {
+		attempt.Answers = append(attempt.Answers, -1)
+	}
+	result := Score(attempt.Level, attempt.Questions, attempt.Answers)
+	attempt.Result = &result
+	attempt.FinishedAt = &now
+}
+
+// Answer records the option picked for a question. Questions are answered in
+// order, so question must be the first unanswered one. The attempt is
+// finished after the last question and, with ErrExpired, when the time is
+// over; the caller saves it either way.
+func Answer(attempt *db.MockAttempt, question, option int, now time.Time) error {
+	if attempt.FinishedAt != nil {
+		return ErrFinished
+	}
+	if Expired(attempt, now) {
+		Finish(attempt, now)
+		return ErrExpired
+	}
+	if question != len(attempt.Answers) {
+		return ErrNotCurrent
+	}
+	if option < 0 || option >= len(attempt.Questions[question].Options) {
+		return ErrInvalidOption
+	}
+
+	attempt.Answers = append(attempt.Answers, option)
+	if len(attempt.Answers) == len(attempt.Questions) {
+		Finish(attempt, now)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package mock
+
+import (
+	"errors"
+	"jpbot/internal/db"
+	"testing"
+	"time"
+)
+
+// questions makes a test of the level with every question's right option 0.
+func questions(level string) []db.MockQuestion {
+	plan, _ := PlanFor(level)
+	var qs []db.MockQuestion
+	for _, s := range plan.Sections {
+		for i := 0; i < s.Count; i++ {
+			qs = append(qs, db.MockQuestion{Section: s.Section, Options: []string{"a", "b", "c", "d"}})
+		}
+	}
+	return qs
+}
+
+// answers picks the right option for as many questions of every section as
+// given in right and a wrong one for the rest.
+func answers(qs []db.MockQuestion, right map[string]int) []int {
+	out := make([]int, len(qs))
+	seen := make(map[string]int)
+	for i, q := range qs {
+		seen[q.Section]++
+		if seen[q.Section] > right[q.Section] {
+			out[i] = 1
+		}
+	}
+	return out
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		right      map[string]int
+		wantTotal  int
+		wantPassed bool
+	}{
+		{"N5 all right", db.LevelN5, map[string]int{SectionVocabulary: 8, SectionGrammar: 4, SectionReading: 2, SectionListening: 6}, 180, true},
+		{"N5 all wrong", db.LevelN5, nil, 0, false},
+		// 120 for language knowledge, but listening is below its sectional pass mark
+		{"N5 no listening", db.LevelN5, map[string]int{SectionVocabulary: 8, SectionGrammar: 4, SectionReading: 2, SectionListening: 1}, 130, false},
+		// 7/14 * 120 = 60 and 3/6 * 60 = 30
+		{"N5 half", db.LevelN5, map[string]int{SectionVocabulary: 4, SectionGrammar: 2, SectionReading: 1, SectionListening: 3}, 90, true},
+		// 0 for reading fails N3 even with a high total
+		{"N3 no reading", db.LevelN3, map[string]int{SectionVocabulary: 8, SectionGrammar: 5, SectionListening: 7}, 120, false},
+	}
+
+	for _, tt := range tests {
+		qs := questions(tt.level)
+		result := Score(tt.level, qs, answers(qs, tt.right))
+		if result.Total != tt.wantTotal || result.Passed != tt.wantPassed {
+			t.Errorf("%s: got total %d passed %v, want %d %v", tt.name, result.Total, result.Passed, tt.wantTotal, tt.wantPassed)
+		}
+		if result.Max != 180 {
+			t.Errorf("%s: got max %d, want 180", tt.name, result.Max)
+		}
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	now := time.Now()
+	attempt := db.MockAttempt{
+		Level:     db.LevelN5,
+		Questions: questions(db.LevelN5)[:2],
+		Deadline:  now.Add(time.Minute),
+	}
+
+	if err := Answer(&attempt, 1, 0, now); !errors.Is(err, ErrNotCurrent) {
+		t.Errorf("answering ahead: got %v, want ErrNotCurrent", err)
+	}
+	if err := Answer(&attempt, 0, 4, now); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("invalid option: got %v, want ErrInvalidOption", err)
+	}
+	if err := Answer(&attempt, 0, 0, now); err != nil || attempt.FinishedAt != nil {
+		t.Fatalf("first answer: got %v, finished %v", err, attempt.FinishedAt != nil)
+	}
+	if err := Answer(&attempt, 1, 1, now); err != nil || attempt.Result == nil {
+		t.Fatalf("last answer: got %v, result %v", err, attempt.Result)
+	}
+	if err := Answer(&attempt, 1, 1, now); !errors.Is(err, ErrFinished) {
+		t.Errorf("after the end: got %v, want ErrFinished", err)
+	}
+}
+
+func TestAnswerExpired(t *testing.T) {
+	now := time.Now()
+	attempt := db.MockAttempt{
+		Level:     db.LevelN5,
+		Questions: questions(db.LevelN5),
+		Deadline:  now.Add(-time.Second),
+	}
+
+	if err := Answer(&attempt, 0, 0, now); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got %v, want ErrExpired", err)
+	}
+	if attempt.FinishedAt == nil || len(attempt.Answers) != len(attempt.Questions) || attempt.Answers[0] != -1 {
+		t.Errorf("expired attempt is not finished with unanswered questions: %+v", attempt.Answers)
+	}
+}
@@ -0,0 +1,468 @@
+// Package telegramtest is a stand-in for the Telegram Bot API in tests. It
+// records the calls the bot makes, answers them as Telegram would and
+// delivers updates to the webhook the bot set or, without one, through
+// getUpdates.
+package telegramtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	telegram "github.com/go-telegram/bot"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is the bot token the server accepts.
+const Token = "123456:test-token"
+
+// BotID is the ID of the bot returned by getMe.
+const BotID = 123456
+
+// maxMemory is the part of a multipart request kept in memory.
+const maxMemory = 10 << 20
+
+// User is a Telegram user sending updates.
+type User struct {
+	ID        int64
+	FirstName string
+	UserName  string
+}
+
+func (u User) tgUser() *tgbotapi.User {
+	return &tgbotapi.User{ID: u.ID, FirstName: u.FirstName, UserName: u.UserName}
+}
+
+func (u User) chat() *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: u.ID, Type: "private", FirstName: u.FirstName, UserName: u.UserName}
+}
+
+// Button is a button of an inline keyboard.
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"callback_data"`
+}
+
+// Call is a Bot API method called by the bot.
+type Call struct {
+	Method string
+	Params map[string]string
+	Files  map[string][]byte
+	// MessageID is the message sent or edited by the call, 0 for other methods.
+	MessageID int
+}
+
+// ChatID is the chat the call is for, 0 if it has none.
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params["chat_id"], 10, 64)
+	return id
+}
+
+// Text is the text of a message or of a callback query answer.
+func (c Call) Text() string {
+	return c.Params["text"]
+}
+
+// Buttons are the buttons of the inline keyboard of a message, row by row.
+func (c Call) Buttons() []Button {
+	var markup struct {
+		InlineKeyboard [][]Button `json:"inline_keyboard"`
+	}
+	if err := json.Unmarshal([]byte(c.Params["reply_markup"]), &markup); err != nil {
+		return nil
+	}
+	var buttons []Button
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+	return buttons
+}
+
+// Server is the stand-in, make the bot with Bot.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	calls      []Call
+	changed    chan struct{}
+	files      map[string][]byte
+	messageID  int
+	updateID   int
+	webhookURL string
+	secret     string
+	// pending are the updates waiting for getUpdates
+	pending []tgbotapi.Update
+	arrived chan struct{}
+}
+
+// NewServer starts a server, close it when done.
+func NewServer() *Server {
+	s := &Server{
+		changed: make(chan struct{}),
+		arrived: make(chan struct{}),
+		files:   make(map[string][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+Token+"/{method}", s.handleMethod)
+	mux.HandleFunc("GET /file/bot"+Token+"/{path...}", s.handleFile)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Bot makes a bot talking to the server.
+func (s *Server) Bot() (*telegram.Bot, error) {
+	return telegram.New(Token, telegram.WithServerURL(s.URL))
+}
+
+// Calls returns the calls made so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsOf returns the calls of the method made so far.
+func (s *Server) CallsOf(method string) []Call {
+	var calls []Call
+	for _, c := range s.Calls() {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Wait returns the first call starting from the index from that matches,
+// waiting up to the timeout for it to be made. The index of the call is -1
+// if it is not made in time.
+func (s *Server) Wait(from int, timeout time.Duration, match func(Call) bool) (int, Call) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		for ; from < len(s.calls); from++ {
+			if match(s.calls[from]) {
+				c := s.calls[from]
+				s.mu.Unlock()
+				return from, c
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return -1, Call{}
+		}
+	}
+}
+
+// WebhookURL is the URL set by the bot with setWebhook.
+func (s *Server) WebhookURL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.webhookURL
+}
+
+// Message makes an update with a text message from the user. Text starting
+// with a slash is a command.
+func (s *Server) Message(from User, text string) tgbotapi.Update {
+	message := s.message(from)
+	message.Text = text
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len([]rune(command))}}
+	}
+	return tgbotapi.Update{UpdateID: s.nextUpdateID(), Message: message}
+}
+
+// Voice makes an update with a voice message from the user, the bot can
+// download the recording with getFile.
+func (s *Server) Voice(from User, data []byte) tgbotapi.Update {
+	message := s.message(from)
+	fileID := fmt.Sprintf("voice-%d", message.MessageID)
+
+	s.mu.Lock()
+	s.files[fileID] = data
+	s.mu.Unlock()
+
+	message.Voice = &tgbotapi.Voice{FileID: fileID, FileUniqueID: fileID, Duration: 1, FileSize: len(data)}
+	return tgbotapi.Update{UpdateID: s.nextUpdateID(), Message: message}
+}
+
+// Callback makes an update with the user pressing a button with the data
+// under the bot's message.
+func (s *Server) Callback(from User, messageID int, data string) tgbotapi.Update {
+	id := s.nextUpdateID()
+	return tgbotapi.Update{
+		UpdateID: id,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      strconv.Itoa(id),
+			From:    from.tgUser(),
+			Message: &tgbotapi.Message{MessageID: messageID, Chat: from.chat(), Date: int(time.Now().Unix())},
+			Data:    data,
+		},
+	}
+}
+
+func (s *Server) message(from User) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: s.nextMessageID(),
+		From:      from.tgUser(),
+		Chat:      from.chat(),
+		Date:      int(time.Now().Unix()),
+	}
+}
+
+// Inject posts the update to the webhook like Telegram does. Without a
+// webhook the update waits for the bot to get it with getUpdates.
+func (s *Server) Inject(update tgbotapi.Update) error {
+	s.mu.Lock()
+	url, secret := s.webhookURL, s.secret
+	if url == "" {
+		s.pending = append(s.pending, update)
+		close(s.arrived)
+		s.arrived = make(chan struct{})
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to encode update: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post update: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Server) nextMessageID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messageID++
+	return s.messageID
+}
+
+func (s *Server) nextUpdateID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateID++
+	return s.updateID
+}
+
+func (s *Server) handleMethod(w http.ResponseWriter, r *http.Request) {
+	call := Call{
+		Method: r.PathValue("method"),
+		Params: make(map[string]string),
+		Files:  make(map[string][]byte),
+	}
+	if err := readParams(r, &call); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var result interface{} = true
+	switch call.Method {
+	case "getUpdates":
+		s.record(call)
+		s.handleGetUpdates(w, r, call)
+		return
+	case "getMe":
+		result = tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Test Bot", UserName: "test_bot"}
+	case "setWebhook":
+		s.mu.Lock()
+		s.webhookURL = call.Params["url"]
+		s.secret = call.Params["secret_token"]
+		s.mu.Unlock()
+	case "deleteWebhook":
+		s.mu.Lock()
+		s.webhookURL, s.secret = "", ""
+		if call.Params["drop_pending_updates"] == "true" {
+			s.pending = nil
+		}
+		s.mu.Unlock()
+	case "sendMessage", "sendVoice":
+		call.MessageID = s.nextMessageID()
+		result = s.sentMessage(call)
+	case "editMessageText":
+		call.MessageID, _ = strconv.Atoi(call.Params["message_id"])
+		result = s.sentMessage(call)
+	case "getFile":
+		fileID := call.Params["file_id"]
+		s.mu.Lock()
+		data, ok := s.files[fileID]
+		s.mu.Unlock()
+		if !ok {
+			s.record(call)
+			writeError(w, http.StatusBadRequest, "Bad Request: invalid file_id")
+			return
+		}
+		result = tgbotapi.File{FileID: fileID, FileUniqueID: fileID, FileSize: len(data), FilePath: fileID}
+	}
+
+	s.record(call)
+	writeJSON(w, map[string]interface{}{"ok": true, "result": result})
+}
+
+// handleGetUpdates answers with the pending updates starting from the
+// offset, holding the request for up to the timeout until there are some.
+func (s *Server) handleGetUpdates(w http.ResponseWriter, r *http.Request, call Call) {
+	offset, _ := strconv.Atoi(call.Params["offset"])
+	timeout, _ := strconv.Atoi(call.Params["timeout"])
+	deadline := time.After(time.Duration(timeout) * time.Second)
+
+	for {
+		s.mu.Lock()
+		if s.webhookURL != "" {
+			s.mu.Unlock()
+			writeError(w, http.StatusConflict, "Conflict: can't use getUpdates method while webhook is active")
+			return
+		}
+		// updates before the offset are confirmed by the bot
+		for len(s.pending) > 0 && s.pending[0].UpdateID < offset {
+			s.pending = s.pending[1:]
+		}
+		updates := append([]tgbotapi.Update{}, s.pending...)
+		arrived := s.arrived
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeJSON(w, map[string]interface{}{"ok": true, "result": updates})
+			return
+		}
+
+		select {
+		case <-arrived:
+		case <-deadline:
+			writeJSON(w, map[string]interface{}{"ok": true, "result": updates})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// sentMessage is the message Telegram returns for a call sending one.
+func (s *Server) sentMessage(call Call) tgbotapi.Message {
+	message := tgbotapi.Message{
+		MessageID: call.MessageID,
+		From:      &tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Test Bot"},
+		Chat:      &tgbotapi.Chat{ID: call.ChatID(), Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      call.Text(),
+	}
+	if data, ok := call.Files["voice"]; ok || call.Params["voice"] != "" {
+		fileID := call.Params["voice"]
+		if ok {
+			fileID = fmt.Sprintf("sent-voice-%d", call.MessageID)
+			s.mu.Lock()
+			s.files[fileID] = data
+			s.mu.Unlock()
+		}
+		message.Voice = &tgbotapi.Voice{FileID: fileID, FileUniqueID: fileID, Duration: 1}
+	}
+	return message
+}
+
+func (s *Server) record(call Call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.files[r.PathValue("path")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+// readParams reads the parameters of a call sent as a multipart form, the
+// way the bot library sends them, or as JSON.
+func readParams(r *http.Request, call *Call) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var params map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		for name, raw := range params {
+			var text string
+			if err := json.Unmarshal(raw, &text); err == nil {
+				call.Params[name] = text
+			} else {
+				call.Params[name] = string(raw)
+			}
+		}
+		return nil
+	}
+
+	// methods without parameters have no body
+	if r.ContentLength == 0 || r.Header.Get("Content-Type") == "" {
+		return nil
+	}
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return fmt.Errorf("invalid form: %w", err)
+	}
+	for name, values := range r.MultipartForm.Value {
+		call.Params[name] = values[0]
+	}
+	for name, headers := range r.MultipartForm.File {
+		f, err := headers[0].Open()
+		if err != nil {
+			return fmt.Errorf("failed to open file %s: %w", name, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", name, err)
+		}
+		call.Files[name] = data
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":          false,
+		"error_code":  status,
+		"description": description,
+	})
+}